@@ -0,0 +1,262 @@
+// Command shardmap-skew reports how evenly a snapshot file written by SaveTo
+// spreads its entries over its shards.
+//
+//	shardmap-skew [-key string] [-value varint] [-top 5] [-reshard 16 -router jump] map.snap
+//
+// Snapshots do not record accesses, so unlike AnalyzeSkew it reports sizes
+// only, and -top lists the largest entries of every shard rather than the
+// hottest. -reshard shows how the keys would spread over another number of
+// shards; it needs string keys hashed with CityHasher, as the other hashers are
+// seeded per process.
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"flag"
+	"fmt"
+	shardmap "github.com/Aashil0828/shardmap/src"
+	"log"
+	"os"
+	"slices"
+	"text/tabwriter"
+)
+
+// anyCodec lets the codecs be picked by flag.
+type anyCodec[T any] struct {
+	codec shardmap.Codec[T]
+}
+
+type sizedKey struct {
+	key any
+
+	size int
+}
+
+func main() {
+
+	keyCodec := flag.String("key", "string", "key codec: string, varint or uvarint")
+
+	valueCodec := flag.String("value", "varint", "value codec: string, varint or uvarint")
+
+	top := flag.Int("top", 0, "list the n largest entries of every shard")
+
+	reshard := flag.Int("reshard", 0, "also report the spread over this many shards")
+
+	routerName := flag.String("router", "modulo", "router for -reshard: modulo, jump, ring or rendezvous")
+
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+
+		flag.Usage()
+
+		os.Exit(2)
+
+	}
+
+	keys, err := codec(*keyCodec)
+
+	if err != nil {
+
+		log.Fatal(err)
+
+	}
+
+	values, err := codec(*valueCodec)
+
+	if err != nil {
+
+		log.Fatal(err)
+
+	}
+
+	router, err := newRouter(*routerName)
+
+	if err != nil {
+
+		log.Fatal(err)
+
+	}
+
+	file, err := os.Open(flag.Arg(0))
+
+	if err != nil {
+
+		log.Fatal(err)
+
+	}
+
+	defer file.Close()
+
+	largest := map[int][]sizedKey{}
+
+	resharded := make([]float64, max(*reshard, 0))
+
+	var visit func(shardIndex int, key any, value any, size int)
+
+	if *top > 0 || *reshard > 0 {
+
+		visit = func(shardIndex int, key any, value any, size int) {
+
+			if *top > 0 {
+
+				largest[shardIndex] = keepLargest(largest[shardIndex], sizedKey{key: key, size: size}, *top)
+
+			}
+
+			if *reshard > 0 {
+
+				resharded[router.Route(shardmap.CityHasher(key.(string)), *reshard)]++
+
+			}
+
+		}
+
+	}
+
+	if *reshard > 0 && *keyCodec != "string" {
+
+		log.Fatal("-reshard needs string keys")
+
+	}
+
+	info, err := shardmap.InspectSnapshot(bufio.NewReader(file), keys, values, visit)
+
+	if err != nil {
+
+		log.Fatal(err)
+
+	}
+
+	if *reshard > 0 && info.HasherID != shardmap.HasherCity {
+
+		log.Fatalf("-reshard needs keys hashed with city, the snapshot used %v", info.HasherID)
+
+	}
+
+	sizes, entries, bytes := make([]float64, len(info.Entries)), 0, 0
+
+	for shardIndex := range sizes {
+
+		sizes[shardIndex] = float64(info.Entries[shardIndex])
+
+		entries += info.Entries[shardIndex]
+
+		bytes += info.Bytes[shardIndex]
+
+	}
+
+	fmt.Printf("%v shards, hasher %v, %v entries, %v bytes\n\n", info.NumShards, info.HasherID, entries, bytes)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintln(writer, "shard\tentries\tbytes\tshare\t")
+
+	for shardIndex := range sizes {
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%.2f%%\t\n", shardIndex, info.Entries[shardIndex], info.Bytes[shardIndex], 100*sizes[shardIndex]/float64(max(entries, 1)))
+
+	}
+
+	writer.Flush()
+
+	fmt.Println()
+
+	report("sizes", shardmap.MeasureUniformity(sizes))
+
+	if *reshard > 0 {
+
+		report(fmt.Sprintf("resharded into %v with %v", *reshard, *routerName), shardmap.MeasureUniformity(resharded))
+
+	}
+
+	for shardIndex := 0; shardIndex < info.NumShards && *top > 0; shardIndex++ {
+
+		fmt.Printf("\nlargest entries of shard %v:\n", shardIndex)
+
+		for _, entry := range largest[shardIndex] {
+
+			fmt.Printf("  %v (%v bytes)\n", entry.key, entry.size)
+
+		}
+
+	}
+}
+
+func report(name string, uniformity shardmap.Uniformity) {
+
+	fmt.Printf("%v: chi-squared %.2f, p-value %.4g, largest/mean %.2f\n", name, uniformity.ChiSquared, uniformity.PValue, uniformity.MaxRatio)
+
+}
+
+func keepLargest(entries []sizedKey, entry sizedKey, n int) []sizedKey {
+
+	entries = append(entries, entry)
+
+	slices.SortStableFunc(entries, func(a, b sizedKey) int {
+
+		return cmp.Compare(b.size, a.size)
+
+	})
+
+	return entries[:min(len(entries), n)]
+}
+
+func codec(name string) (shardmap.Codec[any], error) {
+
+	switch name {
+
+	case "string":
+
+		return anyCodec[string]{shardmap.StringCodec{}}, nil
+
+	case "varint":
+
+		return anyCodec[int64]{shardmap.VarintCodec[int64]{}}, nil
+
+	case "uvarint":
+
+		return anyCodec[uint64]{shardmap.UvarintCodec[uint64]{}}, nil
+
+	}
+
+	return nil, fmt.Errorf("unknown codec %q", name)
+}
+
+func newRouter(name string) (shardmap.Router, error) {
+
+	switch name {
+
+	case "modulo":
+
+		return shardmap.ModuloRouter{}, nil
+
+	case "jump":
+
+		return shardmap.JumpRouter{}, nil
+
+	case "ring":
+
+		return shardmap.NewRingRouter(shardmap.DefaultVirtualNodes), nil
+
+	case "rendezvous":
+
+		return shardmap.RendezvousRouter{}, nil
+
+	}
+
+	return nil, fmt.Errorf("unknown router %q", name)
+}
+
+func (codec anyCodec[T]) Append(dst []byte, value any) []byte {
+
+	return codec.codec.Append(dst, value.(T))
+
+}
+
+func (codec anyCodec[T]) Decode(src []byte) (any, int, error) {
+
+	return codec.codec.Decode(src)
+
+}
@@ -0,0 +1,130 @@
+// Command shardmapd serves a sharded map of strings over the Redis protocol.
+//
+//	shardmapd -addr :6380 -shards 64 -dir /var/lib/shardmapd
+//
+// With -dir the map is durable: it is recovered from the directory on start and
+// every write is logged to it, see OpenShardMap. With -http the map is also
+// served as JSON, see NewHTTPHandler, next to Prometheus metrics on /metrics.
+package main
+
+import (
+	"errors"
+	"flag"
+	"github.com/Aashil0828/shardmap/server"
+	shardmap "github.com/Aashil0828/shardmap/src"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+
+	address := flag.String("addr", ":6380", "address to listen on")
+
+	numShards := flag.Int("shards", 64, "number of shards")
+
+	dir := flag.String("dir", "", "directory to keep the map in, in memory only if empty")
+
+	sync := flag.Duration("sync", 0, "interval between fsyncs of the write-ahead log, 0 for every write, negative for never")
+
+	compactAt := flag.Int64("compact-at", 64<<20, "log size in bytes that triggers a compaction, 0 to disable")
+
+	httpAddress := flag.String("http", "", "address to serve the HTTP API on, disabled if empty")
+
+	janitor := flag.Duration("janitor", time.Second, "interval between sweeps of expired keys")
+
+	flag.Parse()
+
+	opts := []shardmap.Option[string, string]{
+
+		shardmap.WithCodecs[string, string](shardmap.StringCodec{}, shardmap.StringCodec{}),
+
+		shardmap.WithJanitor[string, string](*janitor),
+
+		shardmap.WithMetrics[string, string](),
+	}
+
+	var store *shardmap.ShardMap[string, string]
+
+	if *dir == "" {
+
+		store = shardmap.NewShardMapOf[string, string](*numShards, opts...)
+
+	} else {
+
+		policy := shardmap.SyncEvery(*sync)
+
+		if *sync < 0 {
+
+			policy = shardmap.SyncNever
+
+		}
+
+		opts = append(opts, shardmap.WithSyncPolicy[string, string](policy), shardmap.WithCompactAt[string, string](*compactAt))
+
+		var err error
+
+		if store, err = shardmap.OpenShardMap[string, string](*dir, *numShards, opts...); err != nil {
+
+			log.Fatalf("open %v: %v", *dir, err)
+
+		}
+
+	}
+
+	listener, err := net.Listen("tcp", *address)
+
+	if err != nil {
+
+		log.Fatal(err)
+
+	}
+
+	respServer := server.New(store)
+
+	if *httpAddress != "" {
+
+		mux := http.NewServeMux()
+
+		mux.Handle("/", server.NewHTTPHandler[string](store))
+
+		mux.Handle("GET /metrics", store.PrometheusHandler("shardmapd"))
+
+		go func() {
+
+			log.Fatal(http.ListenAndServe(*httpAddress, mux))
+
+		}()
+
+	}
+
+	signals := make(chan os.Signal, 1)
+
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+
+		<-signals
+
+		respServer.Close()
+
+	}()
+
+	log.Printf("listening on %v", listener.Addr())
+
+	if err = respServer.Serve(listener); !errors.Is(err, server.ErrorServerClosed) {
+
+		log.Print(err)
+
+	}
+
+	if err = store.Close(); err != nil {
+
+		log.Fatal(err)
+
+	}
+}
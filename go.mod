@@ -1,6 +1,6 @@
 module github.com/Aashil0828/shardmap
 
-go 1.22.9
+go 1.23.0
 
 require (
 	github.com/dolthub/maphash v0.1.0
@@ -0,0 +1,302 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	shardmap "github.com/Aashil0828/shardmap/src"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxBody = 32 << 20
+
+type httpHandler[V any] struct {
+	store shardmap.ShardedMap[string, V]
+}
+
+type entry[V any] struct {
+	Key string `json:"key"`
+
+	Value V `json:"value"`
+
+	// TTL is the time left to live in milliseconds, omitted for keys without
+	// one.
+	TTL int64 `json:"ttl_ms,omitempty"`
+}
+
+// batchRequest is applied in field order: sets, then removes, then gets.
+type batchRequest[V any] struct {
+	Set []entry[V] `json:"set"`
+
+	Remove []string `json:"remove"`
+
+	Get []string `json:"get"`
+}
+
+type batchResponse[V any] struct {
+	Removed int `json:"removed"`
+
+	// Values holds the keys of Get that were found.
+	Values map[string]V `json:"values"`
+}
+
+type stats struct {
+	Entries int `json:"entries"`
+
+	Shards int `json:"shards"`
+
+	Resizing bool `json:"resizing"`
+}
+
+// NewHTTPHandler serves store as JSON:
+//
+//	GET    /keys/{key}  the entry, 404 if missing
+//	PUT    /keys/{key}  store the JSON body, for ?ttl=<duration> if given
+//	DELETE /keys/{key}  remove the entry, 404 if missing
+//	DELETE /keys        remove every entry
+//	POST   /batch       {"set": [entries], "remove": [keys], "get": [keys]}
+//	GET    /shards/{i}  every entry of shard i as NDJSON, 404 if no such shard
+//	GET    /stats       entry and shard counts
+//
+// Errors are returned as {"error": message}. Mount it under a prefix with
+// http.StripPrefix.
+func NewHTTPHandler[V any](store shardmap.ShardedMap[string, V]) http.Handler {
+
+	handler := &httpHandler[V]{store: store}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /keys/{key...}", handler.get)
+
+	mux.HandleFunc("PUT /keys/{key...}", handler.put)
+
+	mux.HandleFunc("DELETE /keys/{key...}", handler.remove)
+
+	mux.HandleFunc("DELETE /keys", handler.removeAll)
+
+	mux.HandleFunc("POST /batch", handler.batch)
+
+	mux.HandleFunc("GET /shards/{shard}", handler.shard)
+
+	mux.HandleFunc("GET /stats", handler.stats)
+
+	return mux
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func (handler *httpHandler[V]) get(w http.ResponseWriter, r *http.Request) {
+
+	key := r.PathValue("key")
+
+	value, ok := handler.store.Get(key)
+
+	if !ok {
+
+		writeError(w, http.StatusNotFound, "key not found")
+
+		return
+	}
+
+	response := entry[V]{Key: key, Value: value}
+
+	if ttl, ok := handler.store.TTL(key); ok && ttl != shardmap.NoExpiration {
+
+		response.TTL = max(ttl.Milliseconds(), 1)
+
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (handler *httpHandler[V]) put(w http.ResponseWriter, r *http.Request) {
+
+	var ttl time.Duration
+
+	if query := r.URL.Query().Get("ttl"); query != "" {
+
+		var err error
+
+		if ttl, err = time.ParseDuration(query); err != nil || ttl <= 0 {
+
+			writeError(w, http.StatusBadRequest, "invalid ttl")
+
+			return
+		}
+
+	}
+
+	var value V
+
+	if !readJSON(w, r, &value) {
+
+		return
+	}
+
+	handler.store.SetWithTTL(r.PathValue("key"), value, ttl)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (handler *httpHandler[V]) remove(w http.ResponseWriter, r *http.Request) {
+
+	if _, loaded := handler.store.LoadAndDelete(r.PathValue("key")); !loaded {
+
+		writeError(w, http.StatusNotFound, "key not found")
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (handler *httpHandler[V]) removeAll(w http.ResponseWriter, r *http.Request) {
+
+	handler.store.RemoveAll()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (handler *httpHandler[V]) batch(w http.ResponseWriter, r *http.Request) {
+
+	var request batchRequest[V]
+
+	if !readJSON(w, r, &request) {
+
+		return
+	}
+
+	entries := make([]shardmap.KV[string, V], len(request.Set))
+
+	for i, entry := range request.Set {
+
+		entries[i] = shardmap.KV[string, V]{Key: entry.Key, Value: entry.Value}
+
+	}
+
+	handler.store.SetMany(entries)
+
+	response := batchResponse[V]{Values: make(map[string]V)}
+
+	response.Removed = handler.store.RemoveMany(request.Remove)
+
+	values, found := handler.store.GetMany(request.Get)
+
+	for i, key := range request.Get {
+
+		if found[i] {
+
+			response.Values[key] = values[i]
+
+		}
+
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// shard streams one entry per line from a snapshot of the shard alone, so that
+// a slow client never holds a shard lock and writes to other shards do not copy
+// them meanwhile.
+func (handler *httpHandler[V]) shard(w http.ResponseWriter, r *http.Request) {
+
+	shardIndex, err := strconv.Atoi(r.PathValue("shard"))
+
+	if err != nil {
+
+		writeError(w, http.StatusBadRequest, "invalid shard index")
+
+		return
+	}
+
+	snapshot, err := handler.store.SnapshotShard(shardIndex)
+
+	if err != nil {
+
+		writeError(w, http.StatusNotFound, err.Error())
+
+		return
+	}
+
+	defer snapshot.Release()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	flusher, _ := w.(http.Flusher)
+
+	encoder := json.NewEncoder(w)
+
+	written := 0
+
+	snapshot.IterShard(func(key string, value V) bool {
+
+		if encoder.Encode(entry[V]{Key: key, Value: value}) != nil {
+
+			return true
+		}
+
+		if written++; written%1024 == 0 && flusher != nil {
+
+			flusher.Flush()
+
+		}
+
+		return r.Context().Err() != nil
+
+	}, shardIndex)
+}
+
+func (handler *httpHandler[V]) stats(w http.ResponseWriter, r *http.Request) {
+
+	writeJSON(w, http.StatusOK, stats{
+
+		Entries: handler.store.Len(),
+
+		Shards: handler.store.NumShards(),
+
+		Resizing: handler.store.Resizing(),
+	})
+}
+
+// readJSON decodes the body into value or answers 400 or 413 and returns false.
+func readJSON(w http.ResponseWriter, r *http.Request, value any) bool {
+
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(value)
+
+	if err == nil {
+
+		return true
+
+	}
+
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) {
+
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+
+	} else {
+
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+
+	}
+
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(value)
+
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+
+	writeJSON(w, status, map[string]string{"error": message})
+
+}
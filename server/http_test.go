@@ -0,0 +1,190 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	shardmap "github.com/Aashil0828/shardmap/src"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPHandler(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	store := shardmap.NewShardMap(4)
+
+	server := httptest.NewServer(NewHTTPHandler[int](store))
+
+	defer server.Close()
+
+	status, body := request(t, server, "GET", "/keys/missing", "")
+
+	assertions.Equal(http.StatusNotFound, status)
+
+	assertions.JSONEq(`{"error": "key not found"}`, body)
+
+	status, _ = request(t, server, "PUT", "/keys/a/b", "42")
+
+	assertions.Equal(http.StatusNoContent, status)
+
+	status, body = request(t, server, "GET", "/keys/a/b", "")
+
+	assertions.Equal(http.StatusOK, status)
+
+	assertions.JSONEq(`{"key": "a/b", "value": 42}`, body)
+
+	status, _ = request(t, server, "PUT", "/keys/ttl?ttl=1h", "1")
+
+	assertions.Equal(http.StatusNoContent, status)
+
+	_, body = request(t, server, "GET", "/keys/ttl", "")
+
+	var response entry[int]
+
+	assertions.NoError(json.Unmarshal([]byte(body), &response))
+
+	assertions.InDelta(3600000, response.TTL, 1000)
+
+	status, _ = request(t, server, "PUT", "/keys/bad", `"text"`)
+
+	assertions.Equal(http.StatusBadRequest, status)
+
+	status, _ = request(t, server, "PUT", "/keys/bad?ttl=soon", "1")
+
+	assertions.Equal(http.StatusBadRequest, status)
+
+	status, _ = request(t, server, "DELETE", "/keys/a/b", "")
+
+	assertions.Equal(http.StatusNoContent, status)
+
+	status, _ = request(t, server, "DELETE", "/keys/a/b", "")
+
+	assertions.Equal(http.StatusNotFound, status)
+
+	status, _ = request(t, server, "DELETE", "/keys", "")
+
+	assertions.Equal(http.StatusNoContent, status)
+
+	assertions.Equal(0, store.Len())
+
+	status, _ = request(t, server, "POST", "/keys/a", "1")
+
+	assertions.Equal(http.StatusMethodNotAllowed, status)
+}
+
+func TestHTTPHandlerBatch(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	store := shardmap.NewShardMap(4)
+
+	store.Set("old", 1)
+
+	server := httptest.NewServer(NewHTTPHandler[int](store))
+
+	defer server.Close()
+
+	status, body := request(t, server, "POST", "/batch", `{
+		"set": [{"key": "a", "value": 1}, {"key": "b", "value": 2}],
+		"remove": ["old", "missing"],
+		"get": ["a", "b", "old"]
+	}`)
+
+	assertions.Equal(http.StatusOK, status)
+
+	assertions.JSONEq(`{"removed": 1, "values": {"a": 1, "b": 2}}`, body)
+
+	status, _ = request(t, server, "POST", "/batch", `{"set": [`)
+
+	assertions.Equal(http.StatusBadRequest, status)
+}
+
+func TestHTTPHandlerShard(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	store := shardmap.NewShardMap(4)
+
+	for i := 0; i < 5000; i++ {
+
+		store.Set(fmt.Sprintf("test%v", i), i)
+
+	}
+
+	server := httptest.NewServer(NewHTTPHandler[int](store))
+
+	defer server.Close()
+
+	total := 0
+
+	for shardIndex := 0; shardIndex < 4; shardIndex++ {
+
+		response, err := http.Get(fmt.Sprintf("%v/shards/%v", server.URL, shardIndex))
+
+		assertions.NoError(err)
+
+		assertions.Equal(http.StatusOK, response.StatusCode)
+
+		assertions.Equal("application/x-ndjson", response.Header.Get("Content-Type"))
+
+		scanner := bufio.NewScanner(response.Body)
+
+		for scanner.Scan() {
+
+			var line entry[int]
+
+			assertions.NoError(json.Unmarshal(scanner.Bytes(), &line))
+
+			assertions.Equal(uint32(shardIndex), store.GetShardIndex(line.Key))
+
+			assertions.Equal(fmt.Sprintf("test%v", line.Value), line.Key)
+
+			total++
+
+		}
+
+		response.Body.Close()
+
+	}
+
+	assertions.Equal(5000, total)
+
+	status, body := request(t, server, "GET", "/shards/4", "")
+
+	assertions.Equal(http.StatusNotFound, status)
+
+	assertions.Contains(body, "shard 4 does not exist")
+
+	status, _ = request(t, server, "GET", "/shards/x", "")
+
+	assertions.Equal(http.StatusBadRequest, status)
+
+	status, body = request(t, server, "GET", "/stats", "")
+
+	assertions.Equal(http.StatusOK, status)
+
+	assertions.JSONEq(`{"entries": 5000, "shards": 4, "resizing": false}`, body)
+}
+
+func request(t *testing.T, server *httptest.Server, method, path, body string) (int, string) {
+
+	request, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+
+	assert.NoError(t, err)
+
+	response, err := http.DefaultClient.Do(request)
+
+	assert.NoError(t, err)
+
+	defer response.Body.Close()
+
+	data, _ := io.ReadAll(response.Body)
+
+	return response.StatusCode, string(data)
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxArgs = 1 << 20
+
+	maxBulk = 512 << 20
+
+	maxInline = 64 << 10
+)
+
+var ErrorProtocol = errors.New("protocol error")
+
+// writer encodes replies in RESP2, or RESP3 once a client switched with HELLO.
+// Only nulls and maps differ between the two for the replies the server sends.
+type writer struct {
+	*bufio.Writer
+
+	resp3 bool
+}
+
+// readCommand reads one command, either a RESP array of bulk strings or an
+// inline command as typed into telnet.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+
+	prefix, err := reader.Peek(1)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	if prefix[0] != '*' {
+
+		line, err := readLine(reader, maxInline)
+
+		if err != nil {
+
+			return nil, err
+
+		}
+
+		return strings.Fields(line), nil
+	}
+
+	count, err := readLength(reader, '*', maxArgs)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	args := make([]string, 0, min(count, 1024))
+
+	for i := 0; i < count; i++ {
+
+		length, err := readLength(reader, '$', maxBulk)
+
+		if err != nil {
+
+			return nil, err
+
+		}
+
+		arg := make([]byte, length+2)
+
+		if _, err = io.ReadFull(reader, arg); err != nil {
+
+			return nil, err
+
+		}
+
+		if arg[length] != '\r' || arg[length+1] != '\n' {
+
+			return nil, ErrorProtocol
+
+		}
+
+		args = append(args, string(arg[:length]))
+
+	}
+
+	return args, nil
+}
+
+// readLength reads a "<prefix><n>\r\n" line and checks 0 <= n <= limit.
+func readLength(reader *bufio.Reader, prefix byte, limit int) (int, error) {
+
+	line, err := readLine(reader, 32)
+
+	if err != nil {
+
+		return 0, err
+
+	}
+
+	if len(line) < 2 || line[0] != prefix {
+
+		return 0, ErrorProtocol
+
+	}
+
+	length, err := strconv.Atoi(line[1:])
+
+	if err != nil || length < 0 || length > limit {
+
+		return 0, ErrorProtocol
+
+	}
+
+	return length, nil
+}
+
+func readLine(reader *bufio.Reader, limit int) (string, error) {
+
+	var line []byte
+
+	for {
+
+		chunk, err := reader.ReadSlice('\n')
+
+		line = append(line, chunk...)
+
+		if len(line) > limit {
+
+			return "", ErrorProtocol
+
+		}
+
+		if err == bufio.ErrBufferFull {
+
+			continue
+		}
+
+		if err != nil {
+
+			if err == io.EOF && len(line) > 0 {
+
+				return "", io.ErrUnexpectedEOF
+
+			}
+
+			return "", err
+
+		}
+
+		return strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"), nil
+	}
+}
+
+func (writer *writer) simple(s string) {
+
+	writer.WriteByte('+')
+
+	writer.WriteString(s)
+
+	writer.WriteString("\r\n")
+
+}
+
+func (writer *writer) error(message string) {
+
+	writer.WriteByte('-')
+
+	writer.WriteString(message)
+
+	writer.WriteString("\r\n")
+
+}
+
+func (writer *writer) integer(n int64) {
+
+	writer.WriteByte(':')
+
+	writer.WriteString(strconv.FormatInt(n, 10))
+
+	writer.WriteString("\r\n")
+
+}
+
+func (writer *writer) bulk(s string) {
+
+	writer.WriteByte('$')
+
+	writer.WriteString(strconv.Itoa(len(s)))
+
+	writer.WriteString("\r\n")
+
+	writer.WriteString(s)
+
+	writer.WriteString("\r\n")
+
+}
+
+func (writer *writer) null() {
+
+	if writer.resp3 {
+
+		writer.WriteString("_\r\n")
+
+		return
+	}
+
+	writer.WriteString("$-1\r\n")
+
+}
+
+func (writer *writer) array(length int) {
+
+	writer.WriteByte('*')
+
+	writer.WriteString(strconv.Itoa(length))
+
+	writer.WriteString("\r\n")
+
+}
+
+// mapHeader starts a map of length pairs, which RESP2 sends as a flat array.
+func (writer *writer) mapHeader(length int) {
+
+	if writer.resp3 {
+
+		writer.WriteByte('%')
+
+		writer.WriteString(strconv.Itoa(length))
+
+		writer.WriteString("\r\n")
+
+		return
+	}
+
+	writer.array(2 * length)
+
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestReadCommand(t *testing.T) {
+
+	tests := map[string]struct {
+		input string
+
+		args []string
+
+		err bool
+	}{
+		"Array": {"*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", []string{"GET", "key"}, false},
+
+		"Binary": {"*1\r\n$4\r\na\r\nb\r\n", []string{"a\r\nb"}, false},
+
+		"Empty": {"*1\r\n$0\r\n\r\n", []string{""}, false},
+
+		"Inline": {"SET key  value\r\n", []string{"SET", "key", "value"}, false},
+
+		"InlineNewline": {"PING\n", []string{"PING"}, false},
+
+		"BadLength": {"*1\r\n$x\r\n", nil, true},
+
+		"NegativeCount": {"*-2\r\n", nil, true},
+
+		"MissingCRLF": {"*1\r\n$3\r\nGETXX", nil, true},
+
+		"NotBulk": {"*1\r\n:1\r\n", nil, true},
+
+		"Truncated": {"*2\r\n$3\r\nGET\r\n", nil, true},
+	}
+
+	for name, test := range tests {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			args, err := readCommand(bufio.NewReader(strings.NewReader(test.input)))
+
+			if test.err {
+
+				assertions.Error(err)
+
+				return
+			}
+
+			assertions.NoError(err)
+
+			assertions.Equal(test.args, args)
+
+		})
+
+	}
+}
+
+func TestWriter(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	var output strings.Builder
+
+	writer := &writer{Writer: bufio.NewWriter(&output)}
+
+	writer.simple("OK")
+
+	writer.error("ERR bad")
+
+	writer.integer(-3)
+
+	writer.bulk("value")
+
+	writer.null()
+
+	writer.mapHeader(1)
+
+	writer.resp3 = true
+
+	writer.null()
+
+	writer.mapHeader(1)
+
+	writer.Flush()
+
+	assertions.Equal("+OK\r\n-ERR bad\r\n:-3\r\n$5\r\nvalue\r\n$-1\r\n*2\r\n_\r\n%1\r\n", output.String())
+}
@@ -0,0 +1,987 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	shardmap "github.com/Aashil0828/shardmap/src"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server serves a map of strings over the Redis protocol, RESP2 by default and
+// RESP3 for clients that ask for it with HELLO.
+type Server struct {
+	store shardmap.ShardedMap[string, string]
+
+	mu sync.Mutex
+
+	listeners map[net.Listener]struct{}
+
+	conns map[net.Conn]struct{}
+
+	closed bool
+
+	wg sync.WaitGroup
+}
+
+type command func(server *Server, writer *writer, args []string)
+
+const (
+	DefaultScanCount = 10
+
+	errorSyntax = "ERR syntax error"
+
+	errorInteger = "ERR value is not an integer or out of range"
+)
+
+var ErrorServerClosed = errors.New("server closed")
+
+var commands map[string]command
+
+func init() {
+
+	commands = map[string]command{
+
+		"PING": (*Server).ping,
+
+		"ECHO": (*Server).echo,
+
+		"HELLO": (*Server).hello,
+
+		"COMMAND": (*Server).command,
+
+		"GET": (*Server).get,
+
+		"SET": (*Server).set,
+
+		"DEL": (*Server).del,
+
+		"EXISTS": (*Server).exists,
+
+		"INCR": (*Server).incr,
+
+		"INCRBY": (*Server).incrBy,
+
+		"MGET": (*Server).mget,
+
+		"MSET": (*Server).mset,
+
+		"DBSIZE": (*Server).dbSize,
+
+		"FLUSHALL": (*Server).flushAll,
+
+		"SCAN": (*Server).scan,
+
+		"EXPIRE": (*Server).expire,
+	}
+}
+
+func New(store shardmap.ShardedMap[string, string]) *Server {
+
+	return &Server{
+
+		store: store,
+
+		listeners: make(map[net.Listener]struct{}),
+
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+func (server *Server) ListenAndServe(address string) error {
+
+	listener, err := net.Listen("tcp", address)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	return server.Serve(listener)
+}
+
+// Serve accepts connections on listener until Close is called and serves each
+// one on its own goroutine. It returns ErrorServerClosed after Close.
+func (server *Server) Serve(listener net.Listener) error {
+
+	server.mu.Lock()
+
+	if server.closed {
+
+		server.mu.Unlock()
+
+		listener.Close()
+
+		return ErrorServerClosed
+	}
+
+	server.listeners[listener] = struct{}{}
+
+	server.mu.Unlock()
+
+	for {
+
+		conn, err := listener.Accept()
+
+		if err != nil {
+
+			server.mu.Lock()
+
+			closed := server.closed
+
+			delete(server.listeners, listener)
+
+			server.mu.Unlock()
+
+			if closed {
+
+				return ErrorServerClosed
+
+			}
+
+			return err
+		}
+
+		if !server.track(conn) {
+
+			conn.Close()
+
+			return ErrorServerClosed
+
+		}
+
+		go server.serveConn(conn)
+
+	}
+}
+
+// Close stops every listener, closes every connection and waits for their
+// goroutines to return. It does not close the map.
+func (server *Server) Close() error {
+
+	server.mu.Lock()
+
+	server.closed = true
+
+	for listener := range server.listeners {
+
+		listener.Close()
+
+	}
+
+	for conn := range server.conns {
+
+		conn.Close()
+
+	}
+
+	server.mu.Unlock()
+
+	server.wg.Wait()
+
+	return nil
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func (server *Server) track(conn net.Conn) bool {
+
+	server.mu.Lock()
+
+	defer server.mu.Unlock()
+
+	if server.closed {
+
+		return false
+
+	}
+
+	server.conns[conn] = struct{}{}
+
+	server.wg.Add(1)
+
+	return true
+}
+
+// serveConn answers the commands of a connection in order. Replies to
+// pipelined commands are flushed together once no more input is buffered.
+func (server *Server) serveConn(conn net.Conn) {
+
+	defer func() {
+
+		conn.Close()
+
+		server.mu.Lock()
+
+		delete(server.conns, conn)
+
+		server.mu.Unlock()
+
+		server.wg.Done()
+
+	}()
+
+	reader := bufio.NewReader(conn)
+
+	writer := &writer{Writer: bufio.NewWriter(conn)}
+
+	for {
+
+		args, err := readCommand(reader)
+
+		if err != nil {
+
+			if errors.Is(err, ErrorProtocol) {
+
+				writer.error("ERR " + err.Error())
+
+				writer.Flush()
+
+			}
+
+			return
+		}
+
+		if len(args) == 0 {
+
+			continue
+		}
+
+		name := strings.ToUpper(args[0])
+
+		if name == "QUIT" {
+
+			writer.simple("OK")
+
+			writer.Flush()
+
+			return
+		}
+
+		if handler, found := commands[name]; found {
+
+			handler(server, writer, args[1:])
+
+		} else {
+
+			writer.error("ERR unknown command '" + args[0] + "'")
+
+		}
+
+		if reader.Buffered() == 0 {
+
+			if writer.Flush() != nil {
+
+				return
+			}
+
+		}
+
+	}
+}
+
+func (server *Server) ping(writer *writer, args []string) {
+
+	switch len(args) {
+
+	case 0:
+
+		writer.simple("PONG")
+
+	case 1:
+
+		writer.bulk(args[0])
+
+	default:
+
+		writer.error(errorArgs("ping"))
+
+	}
+}
+
+func (server *Server) echo(writer *writer, args []string) {
+
+	if len(args) != 1 {
+
+		writer.error(errorArgs("echo"))
+
+		return
+	}
+
+	writer.bulk(args[0])
+}
+
+// hello switches the protocol version of the connection and describes the
+// server. Authentication is not supported.
+func (server *Server) hello(writer *writer, args []string) {
+
+	if len(args) > 0 {
+
+		if args[0] != "2" && args[0] != "3" {
+
+			writer.error("NOPROTO unsupported protocol version")
+
+			return
+		}
+
+		// The version only changes once the whole command is known to be valid.
+		if len(args) > 1 {
+
+			writer.error(errorSyntax)
+
+			return
+		}
+
+		writer.resp3 = args[0] == "3"
+
+	}
+
+	protocol := int64(2)
+
+	if writer.resp3 {
+
+		protocol = 3
+
+	}
+
+	writer.mapHeader(3)
+
+	writer.bulk("server")
+
+	writer.bulk("shardmapd")
+
+	writer.bulk("proto")
+
+	writer.integer(protocol)
+
+	writer.bulk("mode")
+
+	writer.bulk("standalone")
+
+}
+
+// command answers the COMMAND and COMMAND DOCS calls clients make on connect
+// with an empty list.
+func (server *Server) command(writer *writer, args []string) {
+
+	writer.array(0)
+
+}
+
+func (server *Server) get(writer *writer, args []string) {
+
+	if len(args) != 1 {
+
+		writer.error(errorArgs("get"))
+
+		return
+	}
+
+	if value, ok := server.store.Get(args[0]); ok {
+
+		writer.bulk(value)
+
+	} else {
+
+		writer.null()
+
+	}
+}
+
+// set supports the EX, PX, NX and XX options.
+func (server *Server) set(writer *writer, args []string) {
+
+	if len(args) < 2 {
+
+		writer.error(errorArgs("set"))
+
+		return
+	}
+
+	key, value := args[0], args[1]
+
+	var ttl time.Duration
+
+	var nx, xx bool
+
+	for i := 2; i < len(args); i++ {
+
+		switch option := strings.ToUpper(args[i]); option {
+
+		case "NX":
+
+			nx = true
+
+		case "XX":
+
+			xx = true
+
+		case "EX", "PX":
+
+			if i+1 == len(args) || ttl != 0 {
+
+				writer.error(errorSyntax)
+
+				return
+			}
+
+			i++
+
+			amount, err := strconv.ParseInt(args[i], 10, 64)
+
+			unit := time.Second
+
+			if option == "PX" {
+
+				unit = time.Millisecond
+
+			}
+
+			if err != nil || amount <= 0 || amount > math.MaxInt64/int64(unit) {
+
+				writer.error("ERR invalid expire time in 'set' command")
+
+				return
+			}
+
+			ttl = time.Duration(amount) * unit
+
+		default:
+
+			writer.error(errorSyntax)
+
+			return
+		}
+
+	}
+
+	if nx && xx {
+
+		writer.error(errorSyntax)
+
+		return
+	}
+
+	if !nx && !xx {
+
+		server.store.SetWithTTL(key, value, ttl)
+
+		writer.simple("OK")
+
+		return
+	}
+
+	var stored bool
+
+	// Compute would keep the TTL of a key it updates, SET replaces it.
+	server.store.ComputeWithTTL(key, ttl, func(old string, ok bool) (string, shardmap.ComputeOp) {
+
+		if ok != xx {
+
+			return old, shardmap.CancelOp
+
+		}
+
+		stored = true
+
+		return value, shardmap.UpdateOp
+
+	})
+
+	if !stored {
+
+		writer.null()
+
+		return
+	}
+
+	writer.simple("OK")
+}
+
+func (server *Server) del(writer *writer, args []string) {
+
+	if len(args) == 0 {
+
+		writer.error(errorArgs("del"))
+
+		return
+	}
+
+	writer.integer(int64(server.store.RemoveMany(args)))
+}
+
+// exists counts a key as often as it is given, like Redis.
+func (server *Server) exists(writer *writer, args []string) {
+
+	if len(args) == 0 {
+
+		writer.error(errorArgs("exists"))
+
+		return
+	}
+
+	count := int64(0)
+
+	for _, found := range server.store.ContainsMany(args) {
+
+		if found {
+
+			count++
+
+		}
+
+	}
+
+	writer.integer(count)
+}
+
+func (server *Server) incr(writer *writer, args []string) {
+
+	if len(args) != 1 {
+
+		writer.error(errorArgs("incr"))
+
+		return
+	}
+
+	server.incrementBy(writer, args[0], 1)
+}
+
+func (server *Server) incrBy(writer *writer, args []string) {
+
+	if len(args) != 2 {
+
+		writer.error(errorArgs("incrby"))
+
+		return
+	}
+
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+
+	if err != nil {
+
+		writer.error(errorInteger)
+
+		return
+	}
+
+	server.incrementBy(writer, args[0], delta)
+}
+
+// incrementBy adds delta to the integer stored under key, keeping its TTL.
+func (server *Server) incrementBy(writer *writer, key string, delta int64) {
+
+	var result int64
+
+	var failed bool
+
+	server.store.Compute(key, func(old string, ok bool) (string, shardmap.ComputeOp) {
+
+		current := int64(0)
+
+		if ok {
+
+			var err error
+
+			if current, err = strconv.ParseInt(old, 10, 64); err != nil {
+
+				failed = true
+
+				return old, shardmap.CancelOp
+
+			}
+
+		}
+
+		if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+
+			failed = true
+
+			return old, shardmap.CancelOp
+
+		}
+
+		result = current + delta
+
+		return strconv.FormatInt(result, 10), shardmap.UpdateOp
+
+	})
+
+	if failed {
+
+		writer.error(errorInteger)
+
+		return
+	}
+
+	writer.integer(result)
+}
+
+func (server *Server) mget(writer *writer, args []string) {
+
+	if len(args) == 0 {
+
+		writer.error(errorArgs("mget"))
+
+		return
+	}
+
+	values, found := server.store.GetMany(args)
+
+	writer.array(len(values))
+
+	for i, value := range values {
+
+		if found[i] {
+
+			writer.bulk(value)
+
+		} else {
+
+			writer.null()
+
+		}
+
+	}
+}
+
+func (server *Server) mset(writer *writer, args []string) {
+
+	if len(args) == 0 || len(args)%2 != 0 {
+
+		writer.error(errorArgs("mset"))
+
+		return
+	}
+
+	entries := make([]shardmap.KV[string, string], 0, len(args)/2)
+
+	for i := 0; i < len(args); i += 2 {
+
+		entries = append(entries, shardmap.KV[string, string]{Key: args[i], Value: args[i+1]})
+
+	}
+
+	server.store.SetMany(entries)
+
+	writer.simple("OK")
+}
+
+func (server *Server) dbSize(writer *writer, args []string) {
+
+	writer.integer(int64(server.store.Len()))
+
+}
+
+func (server *Server) flushAll(writer *writer, args []string) {
+
+	server.store.RemoveAll()
+
+	writer.simple("OK")
+}
+
+// scan walks the map one shard at a time and the cursor is the index of the
+// next shard. Shards are not ordered, so a page always covers whole shards and
+// COUNT is only the number of keys after which it stops taking more of them.
+// Keys written or removed while a scan is running may be missed or returned
+// twice, and so may every key if the map is resized.
+func (server *Server) scan(writer *writer, args []string) {
+
+	if len(args) == 0 {
+
+		writer.error(errorArgs("scan"))
+
+		return
+	}
+
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+
+	if err != nil {
+
+		writer.error("ERR invalid cursor")
+
+		return
+	}
+
+	pattern, count := "*", DefaultScanCount
+
+	for i := 1; i < len(args); i += 2 {
+
+		if i+1 == len(args) {
+
+			writer.error(errorSyntax)
+
+			return
+		}
+
+		switch strings.ToUpper(args[i]) {
+
+		case "MATCH":
+
+			pattern = args[i+1]
+
+			if !validGlob(pattern) {
+
+				writer.error(errorSyntax)
+
+				return
+			}
+
+		case "COUNT":
+
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 1 {
+
+				writer.error(errorInteger)
+
+				return
+			}
+
+		default:
+
+			writer.error(errorSyntax)
+
+			return
+		}
+
+	}
+
+	numShards := server.store.NumShards()
+
+	shardIndex := numShards
+
+	if cursor < uint64(numShards) {
+
+		shardIndex = int(cursor)
+
+	}
+
+	var keys []string
+
+	for visited := 0; shardIndex < numShards && visited < count; shardIndex++ {
+
+		err := server.store.IterShard(func(key string, value string) bool {
+
+			visited++
+
+			if matchGlob(pattern, key) {
+
+				keys = append(keys, key)
+
+			}
+
+			return false
+
+		}, shardIndex)
+
+		// The map shrank since the cursor was handed out, so the scan is over.
+		if err != nil {
+
+			shardIndex = numShards
+
+			break
+		}
+
+	}
+
+	next := uint64(0)
+
+	if shardIndex < numShards {
+
+		next = uint64(shardIndex)
+
+	}
+
+	writer.array(2)
+
+	writer.bulk(strconv.FormatUint(next, 10))
+
+	writer.array(len(keys))
+
+	for _, key := range keys {
+
+		writer.bulk(key)
+
+	}
+}
+
+func (server *Server) expire(writer *writer, args []string) {
+
+	if len(args) != 2 {
+
+		writer.error(errorArgs("expire"))
+
+		return
+	}
+
+	seconds, err := strconv.ParseInt(args[1], 10, 64)
+
+	if err != nil || seconds > math.MaxInt64/int64(time.Second) || seconds < math.MinInt64/int64(time.Second) {
+
+		writer.error(errorInteger)
+
+		return
+	}
+
+	if server.store.Expire(args[0], time.Duration(seconds)*time.Second) {
+
+		writer.integer(1)
+
+	} else {
+
+		writer.integer(0)
+
+	}
+}
+
+func errorArgs(command string) string {
+
+	return "ERR wrong number of arguments for '" + command + "' command"
+
+}
+
+// validGlob reports whether pattern is a well formed glob for matchGlob, that is
+// whether every class is closed and no escape is left dangling.
+func validGlob(pattern string) bool {
+
+	for p := 0; p < len(pattern); p++ {
+
+		switch pattern[p] {
+
+		case '\\':
+
+			if p++; p == len(pattern) {
+
+				return false
+
+			}
+
+		case '[':
+
+			for p++; p < len(pattern) && pattern[p] != ']'; p++ {
+
+				if pattern[p] == '\\' {
+
+					p++
+
+				}
+
+			}
+
+			if p >= len(pattern) {
+
+				return false
+
+			}
+
+		}
+
+	}
+
+	return true
+}
+
+// matchGlob matches key against a pattern validated by validGlob the way Redis
+// does: * matches any run of bytes, slashes included, ? any single byte, [...]
+// any byte of a class that may hold ranges and start with ^ to negate it, and \
+// escapes the byte after it.
+func matchGlob(pattern, key string) bool {
+
+	star, resume := -1, 0
+
+	for p, k := 0, 0; p < len(pattern) || k < len(key); {
+
+		if p < len(pattern) && pattern[p] == '*' {
+
+			star, resume = p, k
+
+			p++
+
+			continue
+		}
+
+		if p < len(pattern) && k < len(key) {
+
+			if width, matched := matchGlobByte(pattern[p:], key[k]); matched {
+
+				p, k = p+width, k+1
+
+				continue
+			}
+
+		}
+
+		// Let the last * swallow one more byte and try again from there.
+		if star < 0 || resume == len(key) {
+
+			return false
+
+		}
+
+		resume++
+
+		p, k = star+1, resume
+
+	}
+
+	return true
+}
+
+// matchGlobByte reports whether b matches the element pattern starts with and
+// how many bytes of pattern that element spans.
+func matchGlobByte(pattern string, b byte) (width int, matched bool) {
+
+	switch pattern[0] {
+
+	case '?':
+
+		return 1, true
+
+	case '\\':
+
+		return 2, pattern[1] == b
+
+	case '[':
+
+		p, negate := 1, false
+
+		if pattern[p] == '^' {
+
+			p, negate = p+1, true
+
+		}
+
+		for ; pattern[p] != ']'; p++ {
+
+			if pattern[p] == '\\' {
+
+				p++
+
+				matched = matched || pattern[p] == b
+
+			} else if p+2 < len(pattern) && pattern[p+1] == '-' && pattern[p+2] != ']' {
+
+				low, high := min(pattern[p], pattern[p+2]), max(pattern[p], pattern[p+2])
+
+				matched = matched || low <= b && b <= high
+
+				p += 2
+
+			} else {
+
+				matched = matched || pattern[p] == b
+
+			}
+
+		}
+
+		return p + 1, matched != negate
+
+	}
+
+	return 1, pattern[0] == b
+}
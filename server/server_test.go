@@ -0,0 +1,548 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	shardmap "github.com/Aashil0828/shardmap/src"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// client is a minimal RESP client that returns replies as Go values: string
+// for simple and bulk strings, int64, error, nil, []any and map[string]any.
+type client struct {
+	conn net.Conn
+
+	reader *bufio.Reader
+}
+
+func TestServer(t *testing.T) {
+
+	store, client := serve(t)
+
+	assertions := assert.New(t)
+
+	assertions.Equal("PONG", client.do("PING"))
+
+	assertions.Equal("hello", client.do("ECHO", "hello"))
+
+	assertions.Nil(client.do("GET", "key"))
+
+	assertions.Equal("OK", client.do("SET", "key", "value"))
+
+	assertions.Equal("value", client.do("get", "key"))
+
+	assertions.Equal(int64(2), client.do("EXISTS", "key", "key", "missing"))
+
+	assertions.Equal(int64(1), client.do("DEL", "key", "missing"))
+
+	assertions.Equal(int64(1), client.do("INCR", "counter"))
+
+	assertions.Equal(int64(11), client.do("INCRBY", "counter", "10"))
+
+	assertions.Equal(int64(-9), client.do("INCRBY", "counter", "-20"))
+
+	assertions.Equal("OK", client.do("SET", "text", "abc"))
+
+	assertions.ErrorContains(client.doError("INCR", "text"), "not an integer")
+
+	assertions.Equal("OK", client.do("SET", "max", strconv.FormatInt(math.MaxInt64, 10)))
+
+	assertions.ErrorContains(client.doError("INCR", "max"), "out of range")
+
+	assertions.Equal("OK", client.do("MSET", "a", "1", "b", "2"))
+
+	assertions.Equal([]any{"1", nil, "2"}, client.do("MGET", "a", "missing", "b"))
+
+	assertions.Equal(int64(5), client.do("DBSIZE"))
+
+	assertions.Equal("OK", client.do("FLUSHALL"))
+
+	assertions.Equal(int64(0), client.do("DBSIZE"))
+
+	assertions.Equal(0, store.Len())
+
+	assertions.ErrorContains(client.doError("NOPE"), "unknown command")
+
+	assertions.ErrorContains(client.doError("GET"), "wrong number of arguments")
+
+	assertions.ErrorContains(client.doError("MSET", "a"), "wrong number of arguments")
+
+}
+
+func TestServerSet(t *testing.T) {
+
+	store, client := serve(t)
+
+	assertions := assert.New(t)
+
+	assertions.Equal("OK", client.do("SET", "key", "1", "EX", "100"))
+
+	ttl, _ := store.TTL("key")
+
+	assertions.InDelta(100*time.Second, ttl, float64(time.Second))
+
+	assertions.Equal("OK", client.do("SET", "key", "2", "PX", "5000"))
+
+	ttl, _ = store.TTL("key")
+
+	assertions.InDelta(5*time.Second, ttl, float64(time.Second))
+
+	assertions.Nil(client.do("SET", "key", "3", "NX"))
+
+	assertions.Equal("OK", client.do("SET", "key", "3", "XX"))
+
+	ttl, _ = store.TTL("key")
+
+	assertions.Equal(shardmap.NoExpiration, ttl)
+
+	assertions.Nil(client.do("SET", "missing", "1", "XX"))
+
+	assertions.Equal("OK", client.do("SET", "new", "1", "NX", "EX", "10"))
+
+	ttl, _ = store.TTL("new")
+
+	assertions.InDelta(10*time.Second, ttl, float64(time.Second))
+
+	assertions.ErrorContains(client.doError("SET", "key", "1", "NX", "XX"), "syntax")
+
+	assertions.ErrorContains(client.doError("SET", "key", "1", "EX"), "syntax")
+
+	assertions.ErrorContains(client.doError("SET", "key", "1", "EX", "0"), "invalid expire")
+
+	assertions.Equal(int64(1), client.do("EXPIRE", "key", "100"))
+
+	ttl, _ = store.TTL("key")
+
+	assertions.InDelta(100*time.Second, ttl, float64(time.Second))
+
+	assertions.Equal(int64(0), client.do("EXPIRE", "missing", "100"))
+
+	assertions.Equal(int64(1), client.do("EXPIRE", "key", "0"))
+
+	assertions.False(store.Contains("key"))
+
+}
+
+func TestServerScan(t *testing.T) {
+
+	store, client := serve(t)
+
+	assertions := assert.New(t)
+
+	for i := 0; i < 1000; i++ {
+
+		store.Set(fmt.Sprintf("key:%v", i), "")
+
+		store.Set(fmt.Sprintf("other:%v", i), "")
+
+	}
+
+	for _, count := range []string{"1", "7", "10000"} {
+
+		seen := map[string]int{}
+
+		cursor, pages := "0", 0
+
+		for ; ; pages++ {
+
+			reply := client.do("SCAN", cursor, "MATCH", "key:*", "COUNT", count).([]any)
+
+			for _, key := range reply[1].([]any) {
+
+				seen[key.(string)]++
+
+			}
+
+			if cursor = reply[0].(string); cursor == "0" {
+
+				break
+			}
+
+		}
+
+		assertions.Len(seen, 1000)
+
+		// Pages are made of whole shards.
+		assertions.Less(pages, store.NumShards())
+
+		for key, times := range seen {
+
+			assertions.Equal(1, times, key)
+
+			assertions.True(strings.HasPrefix(key, "key:"))
+
+		}
+
+	}
+
+	assertions.ErrorContains(client.doError("SCAN", "x"), "invalid cursor")
+
+	assertions.ErrorContains(client.doError("SCAN", "0", "COUNT", "0"), "not an integer")
+
+	assertions.ErrorContains(client.doError("SCAN", "0", "MATCH", "["), "syntax")
+
+	t.Run("Slashes", func(t *testing.T) {
+
+		store.RemoveAll()
+
+		for _, key := range []string{"a/b", "a/b/c", "/", "plain"} {
+
+			store.Set(key, "")
+
+		}
+
+		scanAll := func(args ...string) []string {
+
+			var keys []string
+
+			for cursor := "0"; ; {
+
+				reply := client.do(append([]string{"SCAN", cursor}, args...)...).([]any)
+
+				for _, key := range reply[1].([]any) {
+
+					keys = append(keys, key.(string))
+
+				}
+
+				if cursor = reply[0].(string); cursor == "0" {
+
+					return keys
+				}
+
+			}
+
+		}
+
+		assertions.ElementsMatch([]string{"a/b", "a/b/c", "/", "plain"}, scanAll())
+
+		assertions.ElementsMatch([]string{"a/b", "a/b/c"}, scanAll("MATCH", "a/*"))
+
+		assertions.ElementsMatch([]string{"a/b/c"}, scanAll("MATCH", "*/c"))
+
+		assertions.ElementsMatch([]string{"/"}, scanAll("MATCH", "?"))
+
+	})
+
+}
+
+func TestMatchGlob(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	for _, test := range []struct {
+		pattern string
+
+		key string
+
+		matched bool
+	}{
+		{"*", "", true},
+
+		{"*", "a/b/c", true},
+
+		{"a*c", "a/b/c", true},
+
+		{"a*c", "a/b/d", false},
+
+		{"*b*", "abc", true},
+
+		{"a?c", "a/c", true},
+
+		{"a?c", "ac", false},
+
+		{"[a-c]x", "bx", true},
+
+		{"[c-a]x", "bx", true},
+
+		{"[^a-c]x", "bx", false},
+
+		{"[^a-c]x", "dx", true},
+
+		{"[\\]]", "]", true},
+
+		{"\\*", "*", true},
+
+		{"\\*", "a", false},
+
+		{"key:*:end", "key:1:2:end", true},
+
+		{"key:*:end", "key:1:2:end:", false},
+	} {
+
+		assertions.Equal(test.matched, matchGlob(test.pattern, test.key), "%q %q", test.pattern, test.key)
+
+	}
+
+	for _, pattern := range []string{"[", "[a", "a\\", "[\\]"} {
+
+		assertions.False(validGlob(pattern), pattern)
+
+	}
+
+}
+
+func TestServerProtocol(t *testing.T) {
+
+	_, client := serve(t)
+
+	assertions := assert.New(t)
+
+	assertions.Equal([]any{"server", "shardmapd", "proto", int64(2), "mode", "standalone"}, client.do("HELLO"))
+
+	reply := client.do("HELLO", "3")
+
+	assertions.Equal(map[string]any{"server": "shardmapd", "proto": int64(3), "mode": "standalone"}, reply)
+
+	assertions.Nil(client.do("GET", "missing"))
+
+	assertions.ErrorContains(client.doError("HELLO", "4"), "NOPROTO")
+
+	// A rejected HELLO leaves the protocol as it was.
+	assertions.ErrorContains(client.doError("HELLO", "2", "AUTH", "user", "password"), "syntax")
+
+	assertions.Equal(map[string]any{"server": "shardmapd", "proto": int64(3), "mode": "standalone"}, client.do("HELLO"))
+
+	// Pipelined and inline commands.
+	fmt.Fprint(client.conn, "SET a 1\r\nGET a\r\n*1\r\n$4\r\nPING\r\n")
+
+	assertions.Equal("OK", client.read())
+
+	assertions.Equal("1", client.read())
+
+	assertions.Equal("PONG", client.read())
+
+	assertions.Equal("OK", client.do("QUIT"))
+
+	_, err := client.reader.ReadByte()
+
+	assertions.Error(err)
+
+}
+
+func TestServerConcurrent(t *testing.T) {
+
+	store, first := serve(t)
+
+	address := first.conn.RemoteAddr().String()
+
+	var wg sync.WaitGroup
+
+	for worker := 0; worker < 8; worker++ {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			client := dial(t, address)
+
+			for i := 0; i < 100; i++ {
+
+				client.do("INCR", "counter")
+
+			}
+
+		}()
+
+	}
+
+	wg.Wait()
+
+	value, _ := store.Get("counter")
+
+	assert.Equal(t, "800", value)
+}
+
+func TestServerClose(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	assertions.NoError(err)
+
+	server := New(shardmap.NewShardMapOf[string, string](4))
+
+	served := make(chan error)
+
+	go func() {
+
+		served <- server.Serve(listener)
+
+	}()
+
+	client := dial(t, listener.Addr().String())
+
+	assertions.Equal("PONG", client.do("PING"))
+
+	assertions.NoError(server.Close())
+
+	assertions.ErrorIs(<-served, ErrorServerClosed)
+
+	_, err = client.reader.ReadByte()
+
+	assertions.Error(err)
+
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func serve(t *testing.T) (*shardmap.ShardMap[string, string], *client) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	assert.NoError(t, err)
+
+	store := shardmap.NewShardMapOf[string, string](8)
+
+	server := New(store)
+
+	go server.Serve(listener)
+
+	t.Cleanup(func() {
+
+		server.Close()
+
+	})
+
+	return store, dial(t, listener.Addr().String())
+}
+
+func dial(t *testing.T, address string) *client {
+
+	conn, err := net.Dial("tcp", address)
+
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+
+		conn.Close()
+
+	})
+
+	return &client{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (client *client) do(args ...string) any {
+
+	command := fmt.Sprintf("*%v\r\n", len(args))
+
+	for _, arg := range args {
+
+		command += fmt.Sprintf("$%v\r\n%v\r\n", len(arg), arg)
+
+	}
+
+	if _, err := client.conn.Write([]byte(command)); err != nil {
+
+		return err
+
+	}
+
+	return client.read()
+}
+
+// doError is do for commands expected to fail.
+func (client *client) doError(args ...string) error {
+
+	err, _ := client.do(args...).(error)
+
+	return err
+}
+
+func (client *client) read() any {
+
+	line, err := client.reader.ReadString('\n')
+
+	if err != nil {
+
+		return err
+
+	}
+
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch line[0] {
+
+	case '+':
+
+		return line[1:]
+
+	case '-':
+
+		return fmt.Errorf("%v", line[1:])
+
+	case ':':
+
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+
+		return n
+
+	case '_':
+
+		return nil
+
+	case '$':
+
+		length, _ := strconv.Atoi(line[1:])
+
+		if length < 0 {
+
+			return nil
+
+		}
+
+		data := make([]byte, length+2)
+
+		if _, err = io.ReadFull(client.reader, data); err != nil {
+
+			return err
+
+		}
+
+		return string(data[:length])
+
+	case '*':
+
+		length, _ := strconv.Atoi(line[1:])
+
+		values := make([]any, length)
+
+		for i := range values {
+
+			values[i] = client.read()
+
+		}
+
+		return values
+
+	case '%':
+
+		length, _ := strconv.Atoi(line[1:])
+
+		values := make(map[string]any, length)
+
+		for i := 0; i < length; i++ {
+
+			key := client.read().(string)
+
+			values[key] = client.read()
+
+		}
+
+		return values
+
+	}
+
+	return fmt.Errorf("unexpected reply %q", line)
+}
@@ -0,0 +1,341 @@
+package src
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/maphash"
+	"maps"
+	"math"
+)
+
+// arenaCompactMin is the smallest arena worth compacting, so that small shards
+// do not copy themselves over and over.
+const arenaCompactMin = 1 << 16
+
+// arenaMaxBytes bounds an arena by what its uint32 offsets can address, or by
+// the largest slice an int can index on 32-bit platforms.
+var arenaMaxBytes = min(math.MaxUint32, math.MaxInt)
+
+// arenaBackend keeps encoded entries back to back in one []byte and finds them
+// through a map from key hash to offset. Neither holds pointers, so the garbage
+// collector never scans the entries however many there are.
+//
+// An entry is a uvarint key length, a uvarint value length, then the encoded
+// key and value. Removing or overwriting an entry leaves its bytes behind as
+// garbage until the arena is compacted.
+type arenaBackend[K comparable, V any] struct {
+	keyCodec Codec[K]
+
+	valueCodec Codec[V]
+
+	seed maphash.Seed
+
+	arena []byte
+
+	index map[uint64]uint32
+
+	// collisions holds the rare keys whose hash is already taken in index by
+	// another key, by encoded key.
+	collisions map[string]uint32
+
+	garbage int
+}
+
+// ArenaBackend returns a BackendFactory for shards that store entries encoded
+// with keyCodec and valueCodec in a per-shard byte arena instead of a map of
+// keys and values, which keeps garbage collection cheap for very large maps.
+// Equal keys must encode to equal bytes. Values are decoded on every Get, so
+// reads cost more than with MapBackend. A shard holds at most 4GiB of entries;
+// writes past that are dropped like those over WithMaxBytes.
+func ArenaBackend[K comparable, V any](keyCodec Codec[K], valueCodec Codec[V]) BackendFactory[K, V] {
+
+	seed := maphash.MakeSeed()
+
+	return func(capacity int) Backend[K, V] {
+
+		return &arenaBackend[K, V]{
+			keyCodec: keyCodec,
+
+			valueCodec: valueCodec,
+
+			seed: seed,
+
+			index: make(map[uint64]uint32, capacity),
+		}
+
+	}
+}
+
+func (backend *arenaBackend[K, V]) Get(key K) (value V, ok bool) {
+
+	offset, ok := backend.find(backend.keyCodec.Append(nil, key))
+
+	if !ok {
+
+		return value, false
+
+	}
+
+	return backend.value(offset), true
+}
+
+// Put panics if the arena is full. Shards use tryPut instead.
+func (backend *arenaBackend[K, V]) Put(key K, value V) {
+
+	if !backend.tryPut(key, value) {
+
+		panic("shardmap: arena shard is over 4GiB")
+
+	}
+}
+
+func (backend *arenaBackend[K, V]) Delete(key K) (ok bool) {
+
+	encoded := backend.keyCodec.Append(nil, key)
+
+	hash := maphash.Bytes(backend.seed, encoded)
+
+	offset, ok := backend.index[hash]
+
+	if ok && bytes.Equal(backend.key(offset), encoded) {
+
+		delete(backend.index, hash)
+
+	} else if offset, ok = backend.collisions[string(encoded)]; ok {
+
+		delete(backend.collisions, string(encoded))
+
+	}
+
+	if ok {
+
+		backend.release(offset)
+
+	}
+
+	return ok
+}
+
+func (backend *arenaBackend[K, V]) Clear() {
+
+	backend.arena, backend.garbage, backend.collisions = nil, 0, nil
+
+	clear(backend.index)
+
+}
+
+func (backend *arenaBackend[K, V]) Len() int {
+
+	return len(backend.index) + len(backend.collisions)
+
+}
+
+func (backend *arenaBackend[K, V]) Iter(callback func(key K, value V) bool) {
+
+	for _, offset := range backend.index {
+
+		if callback(backend.entry(offset)) {
+
+			return
+		}
+	}
+
+	for _, offset := range backend.collisions {
+
+		if callback(backend.entry(offset)) {
+
+			return
+		}
+	}
+}
+
+func (backend *arenaBackend[K, V]) Clone() Backend[K, V] {
+
+	clone := *backend
+
+	clone.arena, clone.index, clone.collisions = nil, maps.Clone(backend.index), maps.Clone(backend.collisions)
+
+	clone.arena = append(clone.arena, backend.arena...)
+
+	return &clone
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+// tryPut stores key and value unless that would take the arena past
+// arenaMaxBytes even once compacted, and reports whether it did.
+func (backend *arenaBackend[K, V]) tryPut(key K, value V) bool {
+
+	encoded := backend.keyCodec.Append(nil, key)
+
+	encodedValue := backend.valueCodec.Append(nil, value)
+
+	size := 2*binary.MaxVarintLen64 + len(encoded) + len(encodedValue)
+
+	if len(backend.arena)+size > arenaMaxBytes {
+
+		if len(backend.arena)-backend.garbage+size > arenaMaxBytes {
+
+			return false
+
+		}
+
+		backend.compact()
+
+	}
+
+	offset := uint32(len(backend.arena))
+
+	backend.arena = binary.AppendUvarint(backend.arena, uint64(len(encoded)))
+
+	backend.arena = binary.AppendUvarint(backend.arena, uint64(len(encodedValue)))
+
+	backend.arena = append(append(backend.arena, encoded...), encodedValue...)
+
+	hash := maphash.Bytes(backend.seed, encoded)
+
+	previous, found := backend.index[hash]
+
+	if found && bytes.Equal(backend.key(previous), encoded) {
+
+		backend.index[hash] = offset
+
+		backend.release(previous)
+
+	} else if previous, collided := backend.collisions[string(encoded)]; collided {
+
+		backend.collisions[string(encoded)] = offset
+
+		backend.release(previous)
+
+	} else if !found {
+
+		backend.index[hash] = offset
+
+	} else {
+
+		if backend.collisions == nil {
+
+			backend.collisions = make(map[string]uint32)
+
+		}
+
+		backend.collisions[string(encoded)] = offset
+
+	}
+
+	return true
+}
+
+func (backend *arenaBackend[K, V]) find(encoded []byte) (offset uint32, ok bool) {
+
+	if offset, ok = backend.index[maphash.Bytes(backend.seed, encoded)]; ok && bytes.Equal(backend.key(offset), encoded) {
+
+		return offset, true
+
+	}
+
+	offset, ok = backend.collisions[string(encoded)]
+
+	return offset, ok
+}
+
+// header returns where the key of the entry at offset starts and the lengths
+// of its key and value.
+func (backend *arenaBackend[K, V]) header(offset uint32) (start, keyLength, valueLength int) {
+
+	key, n := binary.Uvarint(backend.arena[offset:])
+
+	value, m := binary.Uvarint(backend.arena[int(offset)+n:])
+
+	return int(offset) + n + m, int(key), int(value)
+}
+
+func (backend *arenaBackend[K, V]) key(offset uint32) []byte {
+
+	start, keyLength, _ := backend.header(offset)
+
+	return backend.arena[start : start+keyLength]
+}
+
+func (backend *arenaBackend[K, V]) value(offset uint32) V {
+
+	start, keyLength, valueLength := backend.header(offset)
+
+	return backend.decodeValue(backend.arena[start+keyLength : start+keyLength+valueLength])
+}
+
+func (backend *arenaBackend[K, V]) entry(offset uint32) (K, V) {
+
+	start, keyLength, valueLength := backend.header(offset)
+
+	key, _, err := backend.keyCodec.Decode(backend.arena[start : start+keyLength])
+
+	if err != nil {
+
+		panic("shardmap: corrupt arena key: " + err.Error())
+
+	}
+
+	return key, backend.decodeValue(backend.arena[start+keyLength : start+keyLength+valueLength])
+}
+
+func (backend *arenaBackend[K, V]) decodeValue(encoded []byte) V {
+
+	value, _, err := backend.valueCodec.Decode(encoded)
+
+	if err != nil {
+
+		panic("shardmap: corrupt arena value: " + err.Error())
+
+	}
+
+	return value
+}
+
+// release marks the entry at offset as garbage and compacts the arena once
+// garbage makes up most of it.
+func (backend *arenaBackend[K, V]) release(offset uint32) {
+
+	start, keyLength, valueLength := backend.header(offset)
+
+	backend.garbage += start - int(offset) + keyLength + valueLength
+
+	if len(backend.arena) >= arenaCompactMin && backend.garbage > len(backend.arena)/2 {
+
+		backend.compact()
+
+	}
+}
+
+// compact copies the live entries into a new arena sized for them.
+func (backend *arenaBackend[K, V]) compact() {
+
+	arena := make([]byte, 0, len(backend.arena)-backend.garbage)
+
+	move := func(offset uint32) uint32 {
+
+		start, keyLength, valueLength := backend.header(offset)
+
+		moved := uint32(len(arena))
+
+		arena = append(arena, backend.arena[offset:start+keyLength+valueLength]...)
+
+		return moved
+	}
+
+	for hash, offset := range backend.index {
+
+		backend.index[hash] = move(offset)
+
+	}
+
+	for key, offset := range backend.collisions {
+
+		backend.collisions[key] = move(offset)
+
+	}
+
+	backend.arena, backend.garbage = arena, 0
+
+}
@@ -0,0 +1,123 @@
+package src
+
+import (
+	"github.com/dolthub/swiss"
+	"maps"
+)
+
+// Backend is the store behind a single shard. Backends are never shared between
+// shards and are only ever accessed under their shard's lock, so they need no
+// synchronization of their own.
+type Backend[K comparable, V any] interface {
+	Get(key K) (value V, ok bool)
+
+	Put(key K, value V)
+
+	Delete(key K) (ok bool)
+
+	Clear()
+
+	Len() int
+
+	Iter(callback func(key K, value V) bool)
+
+	// Clone returns an independent copy, used to copy a shard on write after
+	// a Snapshot.
+	Clone() Backend[K, V]
+}
+
+// BackendFactory builds the Backend of one shard, pre-sized for capacity
+// entries.
+type BackendFactory[K comparable, V any] func(capacity int) Backend[K, V]
+
+type mapBackend[K comparable, V any] map[K]V
+
+type swissBackend[K comparable, V any] struct {
+	*swiss.Map[K, V]
+}
+
+func MapBackend[K comparable, V any](capacity int) Backend[K, V] {
+
+	return make(mapBackend[K, V], capacity)
+
+}
+
+func SwissBackend[K comparable, V any](capacity int) Backend[K, V] {
+
+	return swissBackend[K, V]{swiss.NewMap[K, V](uint32(capacity))}
+
+}
+
+func (backend mapBackend[K, V]) Get(key K) (value V, ok bool) {
+
+	value, ok = backend[key]
+
+	return value, ok
+}
+
+func (backend mapBackend[K, V]) Put(key K, value V) {
+
+	backend[key] = value
+
+}
+
+func (backend mapBackend[K, V]) Delete(key K) (ok bool) {
+
+	if _, ok = backend[key]; ok {
+
+		delete(backend, key)
+
+	}
+
+	return ok
+}
+
+func (backend mapBackend[K, V]) Clear() {
+
+	clear(backend)
+
+}
+
+func (backend mapBackend[K, V]) Len() int {
+
+	return len(backend)
+
+}
+
+func (backend mapBackend[K, V]) Iter(callback func(key K, value V) bool) {
+
+	for key, value := range backend {
+
+		if callback(key, value) {
+
+			break
+		}
+	}
+}
+
+func (backend mapBackend[K, V]) Clone() Backend[K, V] {
+
+	return maps.Clone(backend)
+
+}
+
+func (backend swissBackend[K, V]) Clone() Backend[K, V] {
+
+	clone := swiss.NewMap[K, V](uint32(backend.Count()))
+
+	backend.Iter(func(key K, value V) bool {
+
+		clone.Put(key, value)
+
+		return false
+
+	})
+
+	return swissBackend[K, V]{clone}
+}
+
+func (backend swissBackend[K, V]) Len() int {
+
+	return backend.Count()
+
+}
@@ -0,0 +1,249 @@
+package src
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"hash/maphash"
+	"strings"
+	"testing"
+)
+
+var backends = map[string]BackendFactory[string, int]{
+
+	"Map": MapBackend[string, int],
+
+	"Swiss": SwissBackend[string, int],
+
+	"Arena": ArenaBackend[string, int](StringCodec{}, VarintCodec[int]{}),
+
+	"Sorted": SortedBackend[string, int],
+}
+
+func TestBackends(t *testing.T) {
+
+	for name, factory := range backends {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			backend := factory(4)
+
+			assertions.Zero(backend.Len())
+
+			for i := 0; i < 10; i++ {
+
+				backend.Put(fmt.Sprintf("test%v", i), i)
+
+			}
+
+			assertions.Equal(10, backend.Len())
+
+			value, ok := backend.Get("test3")
+
+			assertions.True(ok)
+
+			assertions.Equal(3, value)
+
+			assertions.True(backend.Delete("test3"))
+
+			assertions.False(backend.Delete("test3"))
+
+			_, ok = backend.Get("test3")
+
+			assertions.False(ok)
+
+			visited := 0
+
+			backend.Iter(func(key string, value int) bool {
+
+				visited++
+
+				return visited == 5
+
+			})
+
+			assertions.Equal(5, visited)
+
+			clone := backend.Clone()
+
+			backend.Put("test0", 100)
+
+			backend.Clear()
+
+			assertions.Zero(backend.Len())
+
+			assertions.Equal(9, clone.Len())
+
+			value, ok = clone.Get("test0")
+
+			assertions.True(ok)
+
+			assertions.Equal(0, value)
+
+		})
+
+	}
+}
+
+func TestWithBackend(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	for name, factory := range backends {
+
+		t.Run(name, func(t *testing.T) {
+
+			var shardedMap ShardedMap[string, int] = NewShardMapOf[string, int](4, WithBackend(factory))
+
+			shardedMap.Set("test", 1)
+
+			value, ok := shardedMap.Get("test")
+
+			assertions.True(ok)
+
+			assertions.Equal(1, value)
+
+			assertions.Equal(4, shardedMap.NumShards())
+
+		})
+
+	}
+}
+
+func TestArenaBackend(t *testing.T) {
+
+	t.Run("Compaction", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		backend := ArenaBackend[string, int](StringCodec{}, VarintCodec[int]{})(0).(*arenaBackend[string, int])
+
+		for round := 0; round < 20; round++ {
+
+			for i := 0; i < 1000; i++ {
+
+				backend.Put(fmt.Sprintf("test%v", i), round*i)
+
+			}
+
+		}
+
+		for i := 0; i < 1000; i += 2 {
+
+			assertions.True(backend.Delete(fmt.Sprintf("test%v", i)))
+
+		}
+
+		assertions.Equal(500, backend.Len())
+
+		assertions.Less(len(backend.arena), 2*arenaCompactMin)
+
+		for i := 0; i < 1000; i++ {
+
+			value, ok := backend.Get(fmt.Sprintf("test%v", i))
+
+			assertions.Equal(i%2 == 1, ok)
+
+			if ok {
+
+				assertions.Equal(19*i, value)
+
+			}
+
+		}
+
+	})
+
+	t.Run("Collisions", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		backend := ArenaBackend[string, int](StringCodec{}, VarintCodec[int]{})(0).(*arenaBackend[string, int])
+
+		backend.Put("a", 1)
+
+		// Point the hash of "b" at the entry of "a" as if the two collided.
+		backend.index[maphash.Bytes(backend.seed, StringCodec{}.Append(nil, "b"))] = backend.index[maphash.Bytes(backend.seed, StringCodec{}.Append(nil, "a"))]
+
+		backend.Put("b", 2)
+
+		backend.Put("b", 3)
+
+		assertions.Len(backend.collisions, 1)
+
+		value, ok := backend.Get("b")
+
+		assertions.True(ok)
+
+		assertions.Equal(3, value)
+
+		value, _ = backend.Get("a")
+
+		assertions.Equal(1, value)
+
+		assertions.True(backend.Delete("b"))
+
+		_, ok = backend.Get("b")
+
+		assertions.False(ok)
+
+		assertions.Empty(backend.collisions)
+
+	})
+
+	t.Run("Full", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		previous := arenaMaxBytes
+
+		arenaMaxBytes = 1024
+
+		defer func() { arenaMaxBytes = previous }()
+
+		var refused []string
+
+		shardMap := NewShardMapOf[string, string](1, WithBackend(ArenaBackend[string, string](StringCodec{}, StringCodec{})), WithMaxEntries[string, string](100), WithOnEvict(func(key string, value string) {
+
+			refused = append(refused, key)
+
+		}))
+
+		large := strings.Repeat("x", 600)
+
+		shardMap.Set("test1", large)
+
+		// A full shard drops the write instead of panicking, and the eviction
+		// policy does not keep tracking the key.
+		shardMap.Set("test2", large)
+
+		assertions.Equal([]string{"test2"}, refused)
+
+		assertions.False(shardMap.Contains("test2"))
+
+		assertions.Equal(1, shardMap.Len())
+
+		assertions.Equal(1, shardMap.currentShards()[0].policy.(*lruPolicy[string]).order.Len())
+
+		// The garbage an overwrite leaves is compacted away to make room.
+		shardMap.Set("test1", "small")
+
+		shardMap.Set("test2", large)
+
+		value, _ := shardMap.Get("test2")
+
+		assertions.Equal(large, value)
+
+		backend := ArenaBackend[string, string](StringCodec{}, StringCodec{})(0)
+
+		// Put on its own has no way to refuse.
+		assertions.Panics(func() {
+
+			backend.Put("test", strings.Repeat("x", 2000))
+
+		})
+
+	})
+
+}
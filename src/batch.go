@@ -0,0 +1,208 @@
+package src
+
+import (
+	"sync"
+)
+
+// SetMany stores every entry, hashing each key once and locking each shard
+// once for all of its keys. Entries later in the slice win over earlier ones
+// with the same key.
+func (shardMap *ShardMap[K, V]) SetMany(entries []KV[K, V]) {
+
+	keys := make([]K, len(entries))
+
+	for i, entry := range entries {
+
+		keys[i] = entry.Key
+
+	}
+
+	shardMap.forEachBucket(keys, true, func(shard *shard[K, V], positions []int) {
+
+		for _, i := range positions {
+
+			shard.put(entries[i].Key, entries[i].Value)
+
+		}
+
+	})
+}
+
+func (shardMap *ShardMap[K, V]) SetManyMap(entries map[K]V) {
+
+	batch := make([]KV[K, V], 0, len(entries))
+
+	for key, value := range entries {
+
+		batch = append(batch, KV[K, V]{Key: key, Value: value})
+
+	}
+
+	shardMap.SetMany(batch)
+}
+
+// GetMany looks up every key and returns the values and whether they were
+// found in the same order as keys.
+func (shardMap *ShardMap[K, V]) GetMany(keys []K) (values []V, found []bool) {
+
+	values, found = make([]V, len(keys)), make([]bool, len(keys))
+
+	shardMap.forEachBucket(keys, false, func(shard *shard[K, V], positions []int) {
+
+		for _, i := range positions {
+
+			if values[i], found[i] = shard.get(keys[i]); found[i] {
+
+				shard.touch(keys[i])
+
+			}
+
+			shard.metrics.lookup(found[i])
+
+		}
+
+	})
+
+	return values, found
+}
+
+// RemoveMany removes every key and returns how many were present.
+func (shardMap *ShardMap[K, V]) RemoveMany(keys []K) int {
+
+	var removed int
+
+	var mu sync.Mutex
+
+	shardMap.forEachBucket(keys, true, func(shard *shard[K, V], positions []int) {
+
+		count := 0
+
+		for _, i := range positions {
+
+			if _, ok := shard.get(keys[i]); shard.delete(keys[i]) && ok {
+
+				count++
+
+			}
+
+		}
+
+		mu.Lock()
+
+		removed += count
+
+		mu.Unlock()
+
+	})
+
+	return removed
+}
+
+func (shardMap *ShardMap[K, V]) ContainsMany(keys []K) []bool {
+
+	_, found := shardMap.GetMany(keys)
+
+	return found
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+// forEachBucket groups keys by the shard that owns them and calls fn once per
+// shard with its lock held, the write lock if write is set, and the positions
+// of its keys in order. With WithBatchWorkers the shards are processed in
+// parallel. A Resize is handled like lockShard does for a single key.
+func (shardMap *ShardMap[K, V]) forEachBucket(keys []K, write bool, fn func(shard *shard[K, V], positions []int)) {
+
+	lock, unlock := (*shard[K, V]).RLock, (*shard[K, V]).RUnlock
+
+	if write {
+
+		lock, unlock = (*shard[K, V]).Lock, (*shard[K, V]).Unlock
+
+	}
+
+	hashes := make([]uint64, len(keys))
+
+	pending := make([]int, len(keys))
+
+	for i, key := range keys {
+
+		hashes[i], pending[i] = shardMap.hasher(key), i
+
+	}
+
+	for len(pending) > 0 {
+
+		table := shardMap.table.Load()
+
+		buckets := make([][]int, len(table.shards))
+
+		for _, i := range pending {
+
+			shardIndex := shardMap.route(hashes[i], len(table.shards))
+
+			buckets[shardIndex] = append(buckets[shardIndex], i)
+
+		}
+
+		var retry []int
+
+		var mu sync.Mutex
+
+		apply := func(shardIndex int) {
+
+			positions, shard := buckets[shardIndex], table.shards[shardIndex]
+
+			if len(positions) == 0 {
+
+				return
+			}
+
+			for _, i := range positions {
+
+				if table.previous != nil {
+
+					shardMap.migrateKey(table, hashes[i], keys[i], shard)
+
+				}
+
+				shard.hot.record(keys[i], hashes[i])
+
+			}
+
+			shard.lock(lock)
+
+			defer unlock(shard)
+
+			if shardMap.table.Load() != table {
+
+				mu.Lock()
+
+				retry = append(retry, positions...)
+
+				mu.Unlock()
+
+				return
+			}
+
+			fn(shard, positions)
+		}
+
+		if workers := shardMap.options.batchWorkers; workers > 1 {
+
+			forEachShardParallel(len(buckets), workers, apply)
+
+		} else {
+
+			for shardIndex := range buckets {
+
+				apply(shardIndex)
+
+			}
+
+		}
+
+		pending = retry
+
+	}
+}
@@ -0,0 +1,137 @@
+package src
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(8)
+
+			entries := make([]KV[string, int], 100)
+
+			for i := range entries {
+
+				entries[i] = KV[string, int]{Key: fmt.Sprintf("test%v", i), Value: i}
+
+			}
+
+			shardedMap.SetMany(append(entries, KV[string, int]{Key: "test0", Value: 1000}))
+
+			assertions.Equal(100, shardedMap.Len())
+
+			shardedMap.SetManyMap(map[string]int{"new": 1})
+
+			values, found := shardedMap.GetMany([]string{"test0", "missing", "test99", "new"})
+
+			assertions.Equal([]int{1000, 0, 99, 1}, values)
+
+			assertions.Equal([]bool{true, false, true, true}, found)
+
+			assertions.Equal(2, shardedMap.RemoveMany([]string{"test0", "test1", "missing", "test1"}))
+
+			assertions.Equal([]bool{false, false, true}, shardedMap.ContainsMany([]string{"test0", "test1", "test2"}))
+
+			assertions.Equal(99, shardedMap.Len())
+
+		})
+
+	}
+
+	t.Run("Parallel", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMapOf[int, int](16, WithBatchWorkers[int, int](4))
+
+		entries := make([]KV[int, int], 10000)
+
+		keys := make([]int, len(entries))
+
+		for i := range entries {
+
+			entries[i], keys[i] = KV[int, int]{Key: i, Value: i * 2}, i
+
+		}
+
+		shardMap.SetMany(entries)
+
+		values, _ := shardMap.GetMany(keys)
+
+		for i, value := range values {
+
+			assertions.Equal(i*2, value)
+
+		}
+
+		assertions.Equal(10000, shardMap.RemoveMany(keys))
+
+		assertions.Equal(0, shardMap.Len())
+
+	})
+
+	t.Run("Resize", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(2)
+
+		keys := make([]string, 1000)
+
+		for i := range keys {
+
+			keys[i] = fmt.Sprintf("test%v", i)
+
+			shardMap.Set(keys[i], i)
+
+		}
+
+		assertions.NoError(shardMap.Resize(7))
+
+		values, found := shardMap.GetMany(keys)
+
+		for i := range keys {
+
+			assertions.True(found[i])
+
+			assertions.Equal(i, values[i])
+
+		}
+
+		shardMap.WaitResize()
+
+		assertions.Equal(1000, shardMap.RemoveMany(keys))
+
+	})
+
+	t.Run("Touch", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		var evicted []string
+
+		shardMap := NewShardMapOf[string, int](1, WithMaxEntries[string, int](3), WithOnEvict(func(key string, value int) {
+
+			evicted = append(evicted, key)
+
+		}))
+
+		shardMap.SetMany([]KV[string, int]{{"test1", 1}, {"test2", 2}, {"test3", 3}})
+
+		// Hits count as uses for the eviction policy, as they do for Get.
+		shardMap.GetMany([]string{"test1", "missing"})
+
+		shardMap.Set("test4", 4)
+
+		assertions.Equal([]string{"test2"}, evicted)
+
+	})
+}
@@ -0,0 +1,483 @@
+package src
+
+import (
+	"cmp"
+	"slices"
+)
+
+// btreeDegree bounds the nodes of a SortedBackend to between btreeDegree-1 and
+// 2*btreeDegree-1 entries, the root excepted.
+const btreeDegree = 16
+
+// sortedBackend is an in-memory B-tree in the style of CLRS, splitting full
+// nodes on the way down an insert and growing thin nodes on the way down a
+// delete so that neither ever has to walk back up.
+type sortedBackend[K cmp.Ordered, V any] struct {
+	root *btreeNode[K, V]
+
+	length int
+}
+
+type btreeNode[K cmp.Ordered, V any] struct {
+	entries []btreeEntry[K, V]
+
+	// children is empty for leaves and otherwise has one more element than
+	// entries, children[i] holding the keys less than entries[i].
+	children []*btreeNode[K, V]
+}
+
+type btreeEntry[K cmp.Ordered, V any] struct {
+	key K
+
+	value V
+}
+
+// orderedBackend is implemented by backends that can visit their entries in key
+// order, which ShardSortedMap merges across shards. If seek is set the visit
+// starts at from, inclusive. Visits stop when yield returns false.
+type orderedBackend[K comparable, V any] interface {
+	ascend(from K, seek bool, yield func(key K, value V) bool)
+
+	descend(from K, seek bool, yield func(key K, value V) bool)
+}
+
+// SortedBackend keeps each shard in a B-tree ordered by key. Lookups are
+// slower than with the hash backends, but it lets a ShardSortedMap answer
+// range and prefix queries without visiting every entry.
+func SortedBackend[K cmp.Ordered, V any](capacity int) Backend[K, V] {
+
+	return &sortedBackend[K, V]{}
+
+}
+
+func (backend *sortedBackend[K, V]) Get(key K) (value V, ok bool) {
+
+	for node := backend.root; node != nil; {
+
+		i, found := node.search(key)
+
+		if found {
+
+			return node.entries[i].value, true
+
+		}
+
+		if node.leaf() {
+
+			break
+		}
+
+		node = node.children[i]
+
+	}
+
+	return value, false
+}
+
+func (backend *sortedBackend[K, V]) Put(key K, value V) {
+
+	if backend.root == nil {
+
+		backend.root = &btreeNode[K, V]{}
+
+	}
+
+	if len(backend.root.entries) == 2*btreeDegree-1 {
+
+		backend.root = &btreeNode[K, V]{children: []*btreeNode[K, V]{backend.root}}
+
+		backend.root.split(0)
+
+	}
+
+	if backend.root.insert(key, value) {
+
+		backend.length++
+
+	}
+}
+
+func (backend *sortedBackend[K, V]) Delete(key K) (ok bool) {
+
+	if backend.root == nil {
+
+		return false
+
+	}
+
+	_, ok = backend.root.remove(key, false)
+
+	if len(backend.root.entries) == 0 {
+
+		if backend.root.leaf() {
+
+			backend.root = nil
+
+		} else {
+
+			backend.root = backend.root.children[0]
+
+		}
+
+	}
+
+	if ok {
+
+		backend.length--
+
+	}
+
+	return ok
+}
+
+func (backend *sortedBackend[K, V]) Clear() {
+
+	backend.root, backend.length = nil, 0
+
+}
+
+func (backend *sortedBackend[K, V]) Len() int {
+
+	return backend.length
+
+}
+
+// Iter visits the entries in ascending key order.
+func (backend *sortedBackend[K, V]) Iter(callback func(key K, value V) bool) {
+
+	backend.ascend(*new(K), false, func(key K, value V) bool {
+
+		return !callback(key, value)
+
+	})
+
+}
+
+func (backend *sortedBackend[K, V]) Clone() Backend[K, V] {
+
+	return &sortedBackend[K, V]{root: backend.root.clone(), length: backend.length}
+
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func (backend *sortedBackend[K, V]) ascend(from K, seek bool, yield func(key K, value V) bool) {
+
+	if backend.root != nil {
+
+		backend.root.ascend(from, seek, yield)
+
+	}
+}
+
+func (backend *sortedBackend[K, V]) descend(from K, seek bool, yield func(key K, value V) bool) {
+
+	if backend.root != nil {
+
+		backend.root.descend(from, seek, yield)
+
+	}
+}
+
+func (node *btreeNode[K, V]) leaf() bool {
+
+	return len(node.children) == 0
+
+}
+
+// search returns the index of the first entry not less than key and whether
+// it is key.
+func (node *btreeNode[K, V]) search(key K) (int, bool) {
+
+	return slices.BinarySearchFunc(node.entries, key, func(entry btreeEntry[K, V], key K) int {
+
+		return cmp.Compare(entry.key, key)
+
+	})
+}
+
+// split moves the upper half of the full child i into a new sibling and its
+// median up into node.
+func (node *btreeNode[K, V]) split(i int) {
+
+	child := node.children[i]
+
+	median := child.entries[btreeDegree-1]
+
+	sibling := &btreeNode[K, V]{entries: slices.Clone(child.entries[btreeDegree:])}
+
+	clear(child.entries[btreeDegree-1:])
+
+	child.entries = child.entries[:btreeDegree-1]
+
+	if !child.leaf() {
+
+		sibling.children = slices.Clone(child.children[btreeDegree:])
+
+		clear(child.children[btreeDegree:])
+
+		child.children = child.children[:btreeDegree]
+
+	}
+
+	node.entries = slices.Insert(node.entries, i, median)
+
+	node.children = slices.Insert(node.children, i+1, sibling)
+
+}
+
+// insert stores key in the subtree of node, which must not be full, and reports
+// whether the key is new.
+func (node *btreeNode[K, V]) insert(key K, value V) bool {
+
+	for {
+
+		i, found := node.search(key)
+
+		if found {
+
+			node.entries[i].value = value
+
+			return false
+		}
+
+		if node.leaf() {
+
+			node.entries = slices.Insert(node.entries, i, btreeEntry[K, V]{key: key, value: value})
+
+			return true
+		}
+
+		if len(node.children[i].entries) == 2*btreeDegree-1 {
+
+			node.split(i)
+
+			continue
+		}
+
+		node = node.children[i]
+
+	}
+}
+
+// remove deletes key from the subtree of node, or its largest entry if max is
+// set, and returns the removed entry. Every child is grown to at least
+// btreeDegree entries before remove descends into it, so that it can lose one.
+func (node *btreeNode[K, V]) remove(key K, max bool) (entry btreeEntry[K, V], ok bool) {
+
+	i, found := len(node.entries), false
+
+	if !max {
+
+		i, found = node.search(key)
+
+	}
+
+	if node.leaf() {
+
+		if max && len(node.entries) > 0 {
+
+			found, i = true, len(node.entries)-1
+
+		}
+
+		if !found {
+
+			return entry, false
+
+		}
+
+		entry = node.entries[i]
+
+		node.entries = slices.Delete(node.entries, i, i+1)
+
+		return entry, true
+	}
+
+	if len(node.children[i].entries) < btreeDegree {
+
+		node.grow(i)
+
+		return node.remove(key, max)
+	}
+
+	if found {
+
+		// Replace key with its predecessor, the largest entry to its left.
+		entry = node.entries[i]
+
+		node.entries[i], _ = node.children[i].remove(key, true)
+
+		return entry, true
+	}
+
+	return node.children[i].remove(key, max)
+}
+
+// grow gives child i another entry, taken from a sibling that can spare one or
+// else by merging it with a sibling.
+func (node *btreeNode[K, V]) grow(i int) {
+
+	child := node.children[i]
+
+	switch {
+
+	case i > 0 && len(node.children[i-1].entries) >= btreeDegree:
+
+		left := node.children[i-1]
+
+		child.entries = slices.Insert(child.entries, 0, node.entries[i-1])
+
+		node.entries[i-1] = left.entries[len(left.entries)-1]
+
+		left.entries = slices.Delete(left.entries, len(left.entries)-1, len(left.entries))
+
+		if !left.leaf() {
+
+			child.children = slices.Insert(child.children, 0, left.children[len(left.children)-1])
+
+			left.children = slices.Delete(left.children, len(left.children)-1, len(left.children))
+
+		}
+
+	case i < len(node.entries) && len(node.children[i+1].entries) >= btreeDegree:
+
+		right := node.children[i+1]
+
+		child.entries = append(child.entries, node.entries[i])
+
+		node.entries[i] = right.entries[0]
+
+		right.entries = slices.Delete(right.entries, 0, 1)
+
+		if !right.leaf() {
+
+			child.children = append(child.children, right.children[0])
+
+			right.children = slices.Delete(right.children, 0, 1)
+
+		}
+
+	default:
+
+		if i == len(node.entries) {
+
+			i--
+
+		}
+
+		left, right := node.children[i], node.children[i+1]
+
+		left.entries = append(append(left.entries, node.entries[i]), right.entries...)
+
+		left.children = append(left.children, right.children...)
+
+		node.entries = slices.Delete(node.entries, i, i+1)
+
+		node.children = slices.Delete(node.children, i+1, i+2)
+
+	}
+}
+
+// ascend visits the subtree of node from the first key not less than from, or
+// from its smallest key if seek is unset, and reports whether yield asked to go
+// on.
+func (node *btreeNode[K, V]) ascend(from K, seek bool, yield func(key K, value V) bool) bool {
+
+	i, found := 0, false
+
+	if seek {
+
+		i, found = node.search(from)
+
+	}
+
+	for ; i < len(node.entries); i++ {
+
+		// Everything left of an entry equal to from is less than from.
+		if !node.leaf() && !found && !node.children[i].ascend(from, seek, yield) {
+
+			return false
+
+		}
+
+		if !yield(node.entries[i].key, node.entries[i].value) {
+
+			return false
+
+		}
+
+		seek, found = false, false
+
+	}
+
+	return node.leaf() || node.children[i].ascend(from, seek, yield)
+}
+
+// descend visits the subtree of node from the last key not greater than from,
+// or from its largest key if seek is unset, and reports whether yield asked to
+// go on.
+func (node *btreeNode[K, V]) descend(from K, seek bool, yield func(key K, value V) bool) bool {
+
+	i, found := len(node.entries), false
+
+	if seek {
+
+		if i, found = node.search(from); found {
+
+			i++
+
+		}
+
+	}
+
+	// Everything right of an entry equal to from is greater than from.
+	if !node.leaf() && !found && !node.children[i].descend(from, seek, yield) {
+
+		return false
+
+	}
+
+	for i--; i >= 0; i-- {
+
+		if !yield(node.entries[i].key, node.entries[i].value) {
+
+			return false
+
+		}
+
+		if !node.leaf() && !node.children[i].descend(from, false, yield) {
+
+			return false
+
+		}
+
+	}
+
+	return true
+}
+
+func (node *btreeNode[K, V]) clone() *btreeNode[K, V] {
+
+	if node == nil {
+
+		return nil
+
+	}
+
+	clone := &btreeNode[K, V]{entries: slices.Clone(node.entries)}
+
+	if !node.leaf() {
+
+		clone.children = make([]*btreeNode[K, V], len(node.children))
+
+		for i, child := range node.children {
+
+			clone.children[i] = child.clone()
+
+		}
+
+	}
+
+	return clone
+}
@@ -0,0 +1,128 @@
+package src
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestSortedBackend(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	backend := SortedBackend[int, int](0).(*sortedBackend[int, int])
+
+	reference := make(map[int]int)
+
+	random := rand.New(rand.NewPCG(1, 2))
+
+	for i := 0; i < 50000; i++ {
+
+		key := random.IntN(5000)
+
+		if random.IntN(3) == 0 {
+
+			_, found := reference[key]
+
+			assertions.Equal(found, backend.Delete(key))
+
+			delete(reference, key)
+
+		} else {
+
+			backend.Put(key, i)
+
+			reference[key] = i
+
+		}
+
+	}
+
+	assertions.Equal(len(reference), backend.Len())
+
+	keys := make([]int, 0, len(reference))
+
+	for key, value := range reference {
+
+		keys = append(keys, key)
+
+		found, ok := backend.Get(key)
+
+		assertions.True(ok)
+
+		assertions.Equal(value, found)
+
+	}
+
+	slices.Sort(keys)
+
+	var visited []int
+
+	backend.Iter(func(key int, value int) bool {
+
+		visited = append(visited, key)
+
+		return false
+
+	})
+
+	assertions.Equal(keys, visited)
+
+	for _, from := range []int{-1, 0, 1, 2500, 4999, 5000} {
+
+		start, _ := slices.BinarySearch(keys, from)
+
+		visited = []int{}
+
+		backend.ascend(from, true, func(key int, value int) bool {
+
+			visited = append(visited, key)
+
+			return true
+
+		})
+
+		assertions.Equal(keys[start:], visited)
+
+		end, found := slices.BinarySearch(keys, from)
+
+		if found {
+
+			end++
+
+		}
+
+		visited = []int{}
+
+		backend.descend(from, true, func(key int, value int) bool {
+
+			visited = append(visited, key)
+
+			return true
+
+		})
+
+		expected := slices.Clone(keys[:end])
+
+		slices.Reverse(expected)
+
+		assertions.Equal(expected, visited)
+
+	}
+
+	clone := backend.Clone()
+
+	for _, key := range keys {
+
+		assertions.True(backend.Delete(key))
+
+	}
+
+	assertions.Zero(backend.Len())
+
+	assertions.Nil(backend.root)
+
+	assertions.Equal(len(keys), clone.Len())
+
+}
@@ -0,0 +1,92 @@
+package src
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Codec turns keys or values into bytes for snapshots and the write-ahead log.
+type Codec[T any] interface {
+	Append(dst []byte, value T) []byte
+
+	// Decode reads one value from the start of src and returns it with the
+	// number of bytes it took.
+	Decode(src []byte) (value T, n int, err error)
+}
+
+type Signed interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64
+}
+
+type Unsigned interface {
+	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// StringCodec writes a uvarint length followed by the bytes of the string.
+type StringCodec struct{}
+
+// VarintCodec writes signed integers as zigzag varints.
+type VarintCodec[T Signed] struct{}
+
+// UvarintCodec writes unsigned integers as uvarints.
+type UvarintCodec[T Unsigned] struct{}
+
+var ErrorCorruptValue = errors.New("corrupt encoded value")
+
+func (StringCodec) Append(dst []byte, value string) []byte {
+
+	dst = binary.AppendUvarint(dst, uint64(len(value)))
+
+	return append(dst, value...)
+}
+
+func (StringCodec) Decode(src []byte) (value string, n int, err error) {
+
+	length, n := binary.Uvarint(src)
+
+	if n <= 0 || uint64(len(src)-n) < length {
+
+		return "", 0, ErrorCorruptValue
+
+	}
+
+	return string(src[n : n+int(length)]), n + int(length), nil
+}
+
+func (VarintCodec[T]) Append(dst []byte, value T) []byte {
+
+	return binary.AppendVarint(dst, int64(value))
+
+}
+
+func (VarintCodec[T]) Decode(src []byte) (value T, n int, err error) {
+
+	decoded, n := binary.Varint(src)
+
+	if n <= 0 {
+
+		return value, 0, ErrorCorruptValue
+
+	}
+
+	return T(decoded), n, nil
+}
+
+func (UvarintCodec[T]) Append(dst []byte, value T) []byte {
+
+	return binary.AppendUvarint(dst, uint64(value))
+
+}
+
+func (UvarintCodec[T]) Decode(src []byte) (value T, n int, err error) {
+
+	decoded, n := binary.Uvarint(src)
+
+	if n <= 0 {
+
+		return value, 0, ErrorCorruptValue
+
+	}
+
+	return T(decoded), n, nil
+}
@@ -0,0 +1,73 @@
+package src
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestStringCodec(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	var codec Codec[string] = StringCodec{}
+
+	encoded := codec.Append(codec.Append(nil, "test"), "")
+
+	value, n, err := codec.Decode(encoded)
+
+	assertions.NoError(err)
+
+	assertions.Equal("test", value)
+
+	assertions.Equal(5, n)
+
+	value, n, err = codec.Decode(encoded[n:])
+
+	assertions.NoError(err)
+
+	assertions.Equal("", value)
+
+	assertions.Equal(1, n)
+
+	_, _, err = codec.Decode(encoded[:3])
+
+	assertions.ErrorIs(err, ErrorCorruptValue)
+
+}
+
+func TestIntegerCodecs(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	var signed Codec[int] = VarintCodec[int]{}
+
+	for _, value := range []int{0, 1, -1, math.MaxInt, math.MinInt} {
+
+		decoded, n, err := signed.Decode(signed.Append(nil, value))
+
+		assertions.NoError(err)
+
+		assertions.Equal(value, decoded)
+
+		assertions.Positive(n)
+
+	}
+
+	var unsigned Codec[uint64] = UvarintCodec[uint64]{}
+
+	decoded, _, err := unsigned.Decode(unsigned.Append(nil, math.MaxUint64))
+
+	assertions.NoError(err)
+
+	assertions.Equal(uint64(math.MaxUint64), decoded)
+
+	_, _, err = signed.Decode(nil)
+
+	assertions.ErrorIs(err, ErrorCorruptValue)
+
+	_, _, err = unsigned.Decode([]byte{0x80})
+
+	assertions.ErrorIs(err, ErrorCorruptValue)
+
+}
@@ -0,0 +1,177 @@
+package src
+
+import (
+	"time"
+)
+
+// ComputeOp tells Compute what to do with the value returned by its callback.
+type ComputeOp int
+
+const (
+	UpdateOp ComputeOp = iota
+
+	DeleteOp
+
+	CancelOp
+)
+
+// Compute runs fn with the current value of key while holding the write lock of
+// the key's shard and applies the returned ComputeOp, so the whole
+// read-modify-write is atomic. fn must not access the map. Updating a key keeps
+// its TTL. It returns the value stored under key once the operation is done and
+// whether there is one.
+func (shardMap *ShardMap[K, V]) Compute(key K, fn func(old V, ok bool) (V, ComputeOp)) (value V, ok bool) {
+
+	return shardMap.compute(key, fn, func(shard *shard[K, V], value V, loaded bool) bool {
+
+		return shard.update(key, value, loaded)
+
+	})
+}
+
+// ComputeWithTTL is Compute, except that a value stored by UpdateOp replaces the
+// TTL of key with ttl as SetWithTTL does, under the same lock.
+func (shardMap *ShardMap[K, V]) ComputeWithTTL(key K, ttl time.Duration, fn func(old V, ok bool) (V, ComputeOp)) (value V, ok bool) {
+
+	return shardMap.compute(key, fn, func(shard *shard[K, V], value V, loaded bool) bool {
+
+		return shard.putUntil(key, value, deadlineAfter(ttl))
+
+	})
+}
+
+// Upsert stores the value returned by fn, which receives the current value of
+// key if there is one.
+func (shardMap *ShardMap[K, V]) Upsert(key K, fn func(old V, ok bool) V) V {
+
+	value, _ := shardMap.Compute(key, func(old V, ok bool) (V, ComputeOp) {
+
+		return fn(old, ok), UpdateOp
+
+	})
+
+	return value
+}
+
+// GetOrSet returns the existing value of key if present. Otherwise it stores
+// and returns value. loaded reports whether the value was already present.
+func (shardMap *ShardMap[K, V]) GetOrSet(key K, value V) (actual V, loaded bool) {
+
+	shardMap.Compute(key, func(old V, ok bool) (V, ComputeOp) {
+
+		if actual, loaded = old, ok; ok {
+
+			return old, CancelOp
+
+		}
+
+		actual = value
+
+		return value, UpdateOp
+
+	})
+
+	return actual, loaded
+}
+
+func (shardMap *ShardMap[K, V]) SetIfAbsent(key K, value V) bool {
+
+	_, loaded := shardMap.GetOrSet(key, value)
+
+	return !loaded
+}
+
+// CompareAndSwap stores new under key if its current value equals old. Like
+// sync.Map, values are compared with == and V must therefore be comparable at
+// run time or CompareAndSwap panics.
+func (shardMap *ShardMap[K, V]) CompareAndSwap(key K, old, new V) (swapped bool) {
+
+	shardMap.Compute(key, func(current V, ok bool) (V, ComputeOp) {
+
+		if swapped = ok && any(current) == any(old); swapped {
+
+			return new, UpdateOp
+
+		}
+
+		return current, CancelOp
+
+	})
+
+	return swapped
+}
+
+// CompareAndDelete removes key if its current value equals old, compared the
+// same way as in CompareAndSwap.
+func (shardMap *ShardMap[K, V]) CompareAndDelete(key K, old V) (deleted bool) {
+
+	shardMap.Compute(key, func(current V, ok bool) (V, ComputeOp) {
+
+		if deleted = ok && any(current) == any(old); deleted {
+
+			return current, DeleteOp
+
+		}
+
+		return current, CancelOp
+
+	})
+
+	return deleted
+}
+
+func (shardMap *ShardMap[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+
+	shardMap.Compute(key, func(old V, ok bool) (V, ComputeOp) {
+
+		value, loaded = old, ok
+
+		return old, DeleteOp
+
+	})
+
+	return value, loaded
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+// compute runs fn under the lock of the shard of key and hands a value to be
+// stored to store, which reports whether the shard took it.
+func (shardMap *ShardMap[K, V]) compute(key K, fn func(old V, ok bool) (V, ComputeOp), store func(shard *shard[K, V], value V, loaded bool) bool) (value V, ok bool) {
+
+	shard := shardMap.lockShard(key)
+
+	defer shard.Unlock()
+
+	old, loaded := shard.get(key)
+
+	value, op := fn(old, loaded)
+
+	switch op {
+
+	case UpdateOp:
+
+		if store(shard, value, loaded) {
+
+			return value, true
+
+		}
+
+		var zero V
+
+		return zero, false
+
+	case DeleteOp:
+
+		shard.delete(key)
+
+		var zero V
+
+		return zero, false
+
+	default:
+
+		return old, loaded
+
+	}
+}
@@ -0,0 +1,290 @@
+package src
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+var shardedMaps = map[string]func(numShards int) ShardedMap[string, int]{
+
+	"ShardMap": func(numShards int) ShardedMap[string, int] {
+
+		return NewShardMap(numShards)
+
+	},
+
+	"ShardSwissMap": func(numShards int) ShardedMap[string, int] {
+
+		return NewShardSwissMap(numShards)
+
+	},
+
+	"ShardSortedMap": func(numShards int) ShardedMap[string, int] {
+
+		return NewShardSortedMap(numShards)
+
+	},
+
+	"ShardArenaMap": func(numShards int) ShardedMap[string, int] {
+
+		return NewShardMapOf[string, int](numShards, append(stringIntOptions(), WithBackend(ArenaBackend[string, int](StringCodec{}, VarintCodec[int]{})))...)
+
+	},
+}
+
+func TestCompute(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(4)
+
+			increment := func(old int, ok bool) (int, ComputeOp) {
+
+				return old + 1, UpdateOp
+
+			}
+
+			value, ok := shardedMap.Compute("test", increment)
+
+			assertions.True(ok)
+
+			assertions.Equal(1, value)
+
+			value, ok = shardedMap.Compute("test", increment)
+
+			assertions.True(ok)
+
+			assertions.Equal(2, value)
+
+			value, ok = shardedMap.Compute("test", func(old int, ok bool) (int, ComputeOp) {
+
+				return 100, CancelOp
+
+			})
+
+			assertions.True(ok)
+
+			assertions.Equal(2, value)
+
+			value, ok = shardedMap.Compute("test", func(old int, ok bool) (int, ComputeOp) {
+
+				return old, DeleteOp
+
+			})
+
+			assertions.False(ok)
+
+			assertions.Zero(value)
+
+			assertions.False(shardedMap.Contains("test"))
+
+		})
+
+	}
+}
+
+func TestComputeWithTTL(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(4)
+
+			shardedMap.SetWithTTL("test", 1, time.Hour)
+
+			value, ok := shardedMap.ComputeWithTTL("test", time.Minute, func(old int, ok bool) (int, ComputeOp) {
+
+				return old + 1, UpdateOp
+
+			})
+
+			assertions.True(ok)
+
+			assertions.Equal(2, value)
+
+			ttl, _ := shardedMap.TTL("test")
+
+			assertions.LessOrEqual(ttl, time.Minute)
+
+			// A ttl <= 0 clears the TTL rather than keeping it as Compute does.
+			shardedMap.ComputeWithTTL("test", 0, func(old int, ok bool) (int, ComputeOp) {
+
+				return old + 1, UpdateOp
+
+			})
+
+			ttl, _ = shardedMap.TTL("test")
+
+			assertions.Equal(NoExpiration, ttl)
+
+			shardedMap.Expire("test", time.Hour)
+
+			value, ok = shardedMap.ComputeWithTTL("test", time.Minute, func(old int, ok bool) (int, ComputeOp) {
+
+				return 100, CancelOp
+
+			})
+
+			assertions.Equal(3, value)
+
+			ttl, _ = shardedMap.TTL("test")
+
+			assertions.Greater(ttl, time.Minute)
+
+		})
+
+	}
+}
+
+func TestComputeConcurrent(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(4)
+
+			var wg sync.WaitGroup
+
+			for worker := 0; worker < 16; worker++ {
+
+				wg.Add(1)
+
+				go func() {
+
+					defer wg.Done()
+
+					for i := 0; i < 1000; i++ {
+
+						shardedMap.Upsert("counter", func(old int, ok bool) int {
+
+							return old + 1
+
+						})
+
+					}
+
+				}()
+
+			}
+
+			wg.Wait()
+
+			value, _ := shardedMap.Get("counter")
+
+			assertions.Equal(16000, value)
+
+		})
+
+	}
+}
+
+func TestGetOrSet(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(4)
+
+			actual, loaded := shardedMap.GetOrSet("test", 1)
+
+			assertions.False(loaded)
+
+			assertions.Equal(1, actual)
+
+			actual, loaded = shardedMap.GetOrSet("test", 2)
+
+			assertions.True(loaded)
+
+			assertions.Equal(1, actual)
+
+			assertions.False(shardedMap.SetIfAbsent("test", 3))
+
+			assertions.True(shardedMap.SetIfAbsent("test2", 3))
+
+			value, _ := shardedMap.Get("test2")
+
+			assertions.Equal(3, value)
+
+		})
+
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(4)
+
+			assertions.False(shardedMap.CompareAndSwap("test", 0, 1))
+
+			assertions.False(shardedMap.Contains("test"))
+
+			shardedMap.Set("test", 1)
+
+			assertions.False(shardedMap.CompareAndSwap("test", 2, 3))
+
+			assertions.True(shardedMap.CompareAndSwap("test", 1, 3))
+
+			value, _ := shardedMap.Get("test")
+
+			assertions.Equal(3, value)
+
+			assertions.False(shardedMap.CompareAndDelete("test", 1))
+
+			assertions.True(shardedMap.CompareAndDelete("test", 3))
+
+			assertions.False(shardedMap.Contains("test"))
+
+		})
+
+	}
+}
+
+func TestLoadAndDelete(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(4)
+
+			_, loaded := shardedMap.LoadAndDelete("test")
+
+			assertions.False(loaded)
+
+			shardedMap.Set("test", 1)
+
+			value, loaded := shardedMap.LoadAndDelete("test")
+
+			assertions.True(loaded)
+
+			assertions.Equal(1, value)
+
+			assertions.False(shardedMap.Contains("test"))
+
+		})
+
+	}
+}
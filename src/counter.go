@@ -0,0 +1,256 @@
+package src
+
+import (
+	"container/heap"
+	"sort"
+)
+
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Add atomically adds delta to the value of key, treating a missing key as
+// zero, and returns the new value.
+func Add[K comparable, V Integer](shardedMap ShardedMap[K, V], key K, delta V) V {
+
+	return shardedMap.Upsert(key, func(old V, ok bool) V {
+
+		return old + delta
+
+	})
+}
+
+func Incr[K comparable, V Integer](shardedMap ShardedMap[K, V], key K) V {
+
+	return Add(shardedMap, key, 1)
+
+}
+
+func Decr[K comparable, V Integer](shardedMap ShardedMap[K, V], key K) V {
+
+	return shardedMap.Upsert(key, func(old V, ok bool) V {
+
+		return old - 1
+
+	})
+
+}
+
+// AddIfExists adds delta to the value of key only if key is present. ok
+// reports whether it was.
+func AddIfExists[K comparable, V Integer](shardedMap ShardedMap[K, V], key K, delta V) (value V, ok bool) {
+
+	return shardedMap.Compute(key, func(old V, ok bool) (V, ComputeOp) {
+
+		if !ok {
+
+			return old, CancelOp
+
+		}
+
+		return old + delta, UpdateOp
+
+	})
+}
+
+// Sum adds up every value in the map, visiting the shards in parallel. Each
+// shard is summed under its own read lock, so the result is not a point in time
+// view of the whole map, and during a Resize an entry being migrated may be
+// counted twice.
+func Sum[K comparable, V Integer](shardedMap ShardedMap[K, V]) V {
+
+	sums := reduceShards(shardedMap, func(iter func(callback func(key K, value V) bool)) (sum V) {
+
+		iter(func(key K, value V) bool {
+
+			sum += value
+
+			return false
+
+		})
+
+		return sum
+
+	})
+
+	var sum V
+
+	for _, shardSum := range sums {
+
+		sum += shardSum
+
+	}
+
+	return sum
+}
+
+// Min returns the smallest value in the map, ok is false if the map is empty.
+func Min[K comparable, V Integer](shardedMap ShardedMap[K, V]) (value V, ok bool) {
+
+	return extremum(shardedMap, func(a, b V) bool { return a < b })
+
+}
+
+// Max returns the largest value in the map, ok is false if the map is empty.
+func Max[K comparable, V Integer](shardedMap ShardedMap[K, V]) (value V, ok bool) {
+
+	return extremum(shardedMap, func(a, b V) bool { return a > b })
+
+}
+
+// TopK returns the k entries with the largest values, largest first. Every
+// shard keeps its own k best entries in parallel before they are merged.
+func TopK[K comparable, V Integer](shardedMap ShardedMap[K, V], k int) []KV[K, V] {
+
+	if k <= 0 {
+
+		return nil
+
+	}
+
+	tops := reduceShards(shardedMap, func(iter func(callback func(key K, value V) bool)) (top topKHeap[K, V]) {
+
+		iter(func(key K, value V) bool {
+
+			top.offer(KV[K, V]{Key: key, Value: value}, k)
+
+			return false
+
+		})
+
+		return top
+
+	})
+
+	var top topKHeap[K, V]
+
+	for _, shardTop := range tops {
+
+		for _, entry := range shardTop {
+
+			top.offer(entry, k)
+
+		}
+
+	}
+
+	sort.Slice(top, func(i, j int) bool { return top[i].Value > top[j].Value })
+
+	return top
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func extremum[K comparable, V Integer](shardedMap ShardedMap[K, V], better func(a, b V) bool) (value V, ok bool) {
+
+	type best struct {
+		value V
+
+		found bool
+	}
+
+	bests := reduceShards(shardedMap, func(iter func(callback func(key K, value V) bool)) (shardBest best) {
+
+		iter(func(key K, value V) bool {
+
+			if !shardBest.found || better(value, shardBest.value) {
+
+				shardBest = best{value: value, found: true}
+
+			}
+
+			return false
+
+		})
+
+		return shardBest
+
+	})
+
+	for _, shardBest := range bests {
+
+		if shardBest.found && (!ok || better(shardBest.value, value)) {
+
+			value, ok = shardBest.value, true
+
+		}
+
+	}
+
+	return value, ok
+}
+
+// reduceShards calls fn in parallel with an iterator over each shard of
+// shardedMap and returns the results. Maps of this package are walked under
+// their resize lock like Iter, so that no shard is skipped or added halfway
+// through a Resize. Other ShardedMaps are walked with Iter as a single shard.
+func reduceShards[K comparable, V any, R any](shardedMap ShardedMap[K, V], fn func(iter func(callback func(key K, value V) bool)) R) []R {
+
+	withShardMap, ok := shardedMap.(interface{ shardMapOf() *ShardMap[K, V] })
+
+	if !ok {
+
+		return []R{fn(shardedMap.Iter)}
+
+	}
+
+	shardMap := withShardMap.shardMapOf()
+
+	shardMap.resizeMu.RLock()
+
+	defer shardMap.resizeMu.RUnlock()
+
+	shards := shardMap.allShards()
+
+	results := make([]R, len(shards))
+
+	forEachShardParallel(len(shards), 0, func(shardIndex int) {
+
+		results[shardIndex] = fn(func(callback func(key K, value V) bool) {
+
+			shards[shardIndex].iter(callback)
+
+		})
+
+	})
+
+	return results
+}
+
+// topKHeap is a min-heap on Value holding at most k entries, so its root is the
+// entry to drop when a larger one comes along.
+type topKHeap[K comparable, V Integer] []KV[K, V]
+
+func (top *topKHeap[K, V]) offer(entry KV[K, V], k int) {
+
+	if len(*top) < k {
+
+		heap.Push(top, entry)
+
+	} else if entry.Value > (*top)[0].Value {
+
+		(*top)[0] = entry
+
+		heap.Fix(top, 0)
+
+	}
+}
+
+func (top topKHeap[K, V]) Len() int { return len(top) }
+
+func (top topKHeap[K, V]) Less(i, j int) bool { return top[i].Value < top[j].Value }
+
+func (top topKHeap[K, V]) Swap(i, j int) { top[i], top[j] = top[j], top[i] }
+
+func (top *topKHeap[K, V]) Push(entry any) { *top = append(*top, entry.(KV[K, V])) }
+
+func (top *topKHeap[K, V]) Pop() any {
+
+	old := *top
+
+	entry := old[len(old)-1]
+
+	*top = old[:len(old)-1]
+
+	return entry
+}
@@ -0,0 +1,170 @@
+package src
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(4)
+
+			assertions.Equal(5, Add(shardedMap, "test", 5))
+
+			assertions.Equal(6, Incr(shardedMap, "test"))
+
+			assertions.Equal(5, Decr(shardedMap, "test"))
+
+			assertions.Equal(-1, Decr(shardedMap, "test2"))
+
+			value, ok := AddIfExists(shardedMap, "test", 10)
+
+			assertions.True(ok)
+
+			assertions.Equal(15, value)
+
+			_, ok = AddIfExists(shardedMap, "test3", 10)
+
+			assertions.False(ok)
+
+			assertions.False(shardedMap.Contains("test3"))
+
+		})
+
+	}
+}
+
+func TestAddConcurrent(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardMap := NewShardMapOf[string, uint32](8)
+
+	var wg sync.WaitGroup
+
+	for worker := 0; worker < 8; worker++ {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			for i := 0; i < 1000; i++ {
+
+				Incr[string, uint32](shardMap, fmt.Sprintf("test%v", i%10))
+
+			}
+
+		}()
+
+	}
+
+	wg.Wait()
+
+	for i := 0; i < 10; i++ {
+
+		value, _ := shardMap.Get(fmt.Sprintf("test%v", i))
+
+		assertions.Equal(uint32(800), value)
+
+	}
+
+	assertions.Equal(uint32(8000), Sum[string, uint32](shardMap))
+
+}
+
+func TestAggregates(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(16)
+
+			t.Run("Empty", func(t *testing.T) {
+
+				assertions.Zero(Sum(shardedMap))
+
+				_, ok := Min(shardedMap)
+
+				assertions.False(ok)
+
+				_, ok = Max(shardedMap)
+
+				assertions.False(ok)
+
+				assertions.Empty(TopK(shardedMap, 3))
+
+			})
+
+			for i := -50; i <= 100; i++ {
+
+				shardedMap.Set(fmt.Sprintf("test%v", i), i)
+
+			}
+
+			t.Run("ValidCase", func(t *testing.T) {
+
+				assertions.Equal(3775, Sum(shardedMap))
+
+				value, ok := Min(shardedMap)
+
+				assertions.True(ok)
+
+				assertions.Equal(-50, value)
+
+				value, ok = Max(shardedMap)
+
+				assertions.True(ok)
+
+				assertions.Equal(100, value)
+
+				assertions.Equal([]KV[string, int]{{"test100", 100}, {"test99", 99}, {"test98", 98}}, TopK(shardedMap, 3))
+
+				assertions.Len(TopK(shardedMap, 1000), 151)
+
+				assertions.Nil(TopK(shardedMap, 0))
+
+			})
+
+		})
+
+	}
+}
+
+func TestAggregatesResizing(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardMap := NewShardMap(4)
+
+	for i := 1; i <= 100; i++ {
+
+		shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+	}
+
+	// Swap in a smaller table without migrating, so that every key is still in
+	// a shard the new table has no index for.
+	shardMap.table.Store(&shardTable[string, int]{shards: newShards(shardMap.options, 2), previous: shardMap.currentShards()})
+
+	assertions.Equal(5050, Sum(shardMap))
+
+	value, _ := Max(shardMap)
+
+	assertions.Equal(100, value)
+
+	assertions.Len(TopK(shardMap, 1000), 100)
+
+}
@@ -0,0 +1,407 @@
+package src
+
+import (
+	"container/list"
+	"math/rand/v2"
+	"sync"
+)
+
+// EvictionPolicy picks which entry a full shard drops to make room for a new
+// key.
+type EvictionPolicy int
+
+const (
+	EvictLRU EvictionPolicy = iota
+
+	// EvictLFU evicts the least frequently used of a few sampled entries and
+	// uses TinyLFU admission, so a new key is only stored if it has been seen
+	// more often than the entry it would evict.
+	EvictLFU
+
+	EvictRandom
+)
+
+const lfuSamples = 5
+
+// evictionPolicy tracks the keys of one shard. Every method except access is
+// called with the shard's write lock held. access may run under the read lock
+// and therefore has to synchronize with itself. Inserting a new key calls access
+// before asking for a victim, so that admission sees the attempt.
+type evictionPolicy[K comparable] interface {
+	add(key K)
+
+	access(key K)
+
+	remove(key K)
+
+	clear()
+
+	victim() (key K, ok bool)
+
+	admit(candidate, victim K) bool
+}
+
+type lruPolicy[K comparable] struct {
+	sync.Mutex
+
+	order *list.List
+
+	elements map[K]*list.Element
+}
+
+type randomPolicy[K comparable] struct {
+	keys keySet[K]
+}
+
+type lfuPolicy[K comparable] struct {
+	sync.Mutex
+
+	keys keySet[K]
+
+	sketch *countMinSketch
+
+	hasher Hasher[K]
+
+	increments int
+
+	resetAt int
+}
+
+// keySet is a set that can also hand out a random member in constant time.
+type keySet[K comparable] struct {
+	keys []K
+
+	index map[K]int
+}
+
+func newEvictionPolicy[K comparable](policy EvictionPolicy, capacity int, hasher Hasher[K]) evictionPolicy[K] {
+
+	switch policy {
+
+	case EvictLFU:
+
+		return &lfuPolicy[K]{
+
+			keys: newKeySet[K](capacity),
+
+			sketch: newCountMinSketch(capacity),
+
+			hasher: hasher,
+
+			resetAt: 10 * max(capacity, 16),
+		}
+
+	case EvictRandom:
+
+		return &randomPolicy[K]{keys: newKeySet[K](capacity)}
+
+	default:
+
+		return &lruPolicy[K]{order: list.New(), elements: make(map[K]*list.Element, capacity)}
+
+	}
+}
+
+func (policy *lruPolicy[K]) add(key K) {
+
+	policy.elements[key] = policy.order.PushFront(key)
+
+}
+
+func (policy *lruPolicy[K]) access(key K) {
+
+	policy.Lock()
+
+	if element, ok := policy.elements[key]; ok {
+
+		policy.order.MoveToFront(element)
+
+	}
+
+	policy.Unlock()
+
+}
+
+func (policy *lruPolicy[K]) remove(key K) {
+
+	if element, ok := policy.elements[key]; ok {
+
+		policy.order.Remove(element)
+
+		delete(policy.elements, key)
+
+	}
+}
+
+func (policy *lruPolicy[K]) clear() {
+
+	policy.order.Init()
+
+	clear(policy.elements)
+
+}
+
+func (policy *lruPolicy[K]) victim() (key K, ok bool) {
+
+	if element := policy.order.Back(); element != nil {
+
+		return element.Value.(K), true
+
+	}
+
+	return key, false
+}
+
+func (policy *lruPolicy[K]) admit(candidate, victim K) bool {
+
+	return true
+
+}
+
+func (policy *randomPolicy[K]) add(key K) {
+
+	policy.keys.add(key)
+
+}
+
+func (policy *randomPolicy[K]) access(key K) {}
+
+func (policy *randomPolicy[K]) remove(key K) {
+
+	policy.keys.remove(key)
+
+}
+
+func (policy *randomPolicy[K]) clear() {
+
+	policy.keys.clear()
+
+}
+
+func (policy *randomPolicy[K]) victim() (key K, ok bool) {
+
+	return policy.keys.random()
+
+}
+
+func (policy *randomPolicy[K]) admit(candidate, victim K) bool {
+
+	return true
+
+}
+
+func (policy *lfuPolicy[K]) add(key K) {
+
+	policy.keys.add(key)
+
+}
+
+func (policy *lfuPolicy[K]) access(key K) {
+
+	policy.Lock()
+
+	policy.record(key)
+
+	policy.Unlock()
+
+}
+
+func (policy *lfuPolicy[K]) remove(key K) {
+
+	policy.keys.remove(key)
+
+}
+
+func (policy *lfuPolicy[K]) clear() {
+
+	policy.keys.clear()
+
+	policy.sketch.reset()
+
+	policy.increments = 0
+
+}
+
+func (policy *lfuPolicy[K]) victim() (key K, ok bool) {
+
+	var frequency uint32
+
+	for sample := 0; sample < lfuSamples; sample++ {
+
+		candidate, found := policy.keys.random()
+
+		if !found {
+
+			break
+		}
+
+		if estimate := policy.sketch.estimate(policy.hasher(candidate)); !ok || estimate < frequency {
+
+			key, frequency, ok = candidate, estimate, true
+
+		}
+
+	}
+
+	return key, ok
+}
+
+func (policy *lfuPolicy[K]) admit(candidate, victim K) bool {
+
+	return policy.sketch.estimate(policy.hasher(candidate)) > policy.sketch.estimate(policy.hasher(victim))
+}
+
+// record counts one use of key and ages the sketch once enough uses piled up.
+func (policy *lfuPolicy[K]) record(key K) {
+
+	policy.sketch.increment(policy.hasher(key))
+
+	if policy.increments++; policy.increments >= policy.resetAt {
+
+		policy.sketch.halve()
+
+		policy.increments = 0
+
+	}
+}
+
+func newKeySet[K comparable](capacity int) keySet[K] {
+
+	return keySet[K]{keys: make([]K, 0, capacity), index: make(map[K]int, capacity)}
+
+}
+
+func (set *keySet[K]) add(key K) {
+
+	if _, ok := set.index[key]; ok {
+
+		return
+	}
+
+	set.index[key] = len(set.keys)
+
+	set.keys = append(set.keys, key)
+
+}
+
+func (set *keySet[K]) remove(key K) {
+
+	index, ok := set.index[key]
+
+	if !ok {
+
+		return
+	}
+
+	last := len(set.keys) - 1
+
+	set.keys[index] = set.keys[last]
+
+	set.index[set.keys[index]] = index
+
+	var zero K
+
+	set.keys[last] = zero
+
+	set.keys = set.keys[:last]
+
+	delete(set.index, key)
+
+}
+
+func (set *keySet[K]) clear() {
+
+	clear(set.keys)
+
+	set.keys = set.keys[:0]
+
+	clear(set.index)
+
+}
+
+func (set *keySet[K]) random() (key K, ok bool) {
+
+	if len(set.keys) == 0 {
+
+		return key, false
+
+	}
+
+	return set.keys[rand.IntN(len(set.keys))], true
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+// admit makes room for key and value, or returns why the shard does not take
+// them as TrySet reports it. The eviction policy decides on a new key before
+// the byte limit evicts anything, so that a refused write costs no entries.
+// Admitted keys are registered with the policy.
+func (shard *shard[K, V]) admit(key K, value V) error {
+
+	var victim K
+
+	var exists, full bool
+
+	if shard.policy != nil {
+
+		shard.policy.access(key)
+
+		if _, exists = shard.items.Get(key); !exists && shard.items.Len() >= shard.capacity {
+
+			if victim, full = shard.policy.victim(); full && !shard.policy.admit(key, victim) {
+
+				return ErrorNotAdmitted
+
+			}
+
+		}
+
+	}
+
+	if !shard.makeBytes(key, value) {
+
+		return &MaxBytesError{Size: shard.entryDelta(key, value), Used: shard.usage(), Limit: shard.memory.limit}
+
+	}
+
+	if shard.policy == nil || exists {
+
+		return nil
+
+	}
+
+	// Whatever makeBytes evicted already made room for the entry.
+	if full && shard.items.Len() >= shard.capacity {
+
+		shard.evict(victim)
+
+	}
+
+	shard.policy.add(key)
+
+	return nil
+}
+
+func (shard *shard[K, V]) evict(key K) {
+
+	value, _ := shard.items.Get(key)
+
+	shard.delete(key)
+
+	shard.metrics.evict()
+
+	if shard.onEvict != nil {
+
+		shard.onEvict(key, value)
+
+	}
+}
+
+func (shard *shard[K, V]) touch(key K) {
+
+	if shard.policy != nil {
+
+		shard.policy.access(key)
+
+	}
+}
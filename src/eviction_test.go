@@ -0,0 +1,197 @@
+package src
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestEvictLRU(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	var evicted []string
+
+	shardMap := NewShardMapOf[string, int](1, WithMaxEntries[string, int](3), WithOnEvict(func(key string, value int) {
+
+		evicted = append(evicted, key)
+
+	}))
+
+	shardMap.Set("test1", 1)
+
+	shardMap.Set("test2", 2)
+
+	shardMap.Set("test3", 3)
+
+	shardMap.Get("test1")
+
+	shardMap.Set("test4", 4)
+
+	assertions.Equal([]string{"test2"}, evicted)
+
+	shardMap.Set("test3", 30)
+
+	shardMap.Set("test5", 5)
+
+	assertions.Equal([]string{"test2", "test1"}, evicted)
+
+	assertions.Equal(3, shardMap.Len())
+
+	for _, key := range []string{"test3", "test4", "test5"} {
+
+		assertions.True(shardMap.Contains(key))
+
+	}
+
+	shardMap.Remove("test5")
+
+	shardMap.Set("test6", 6)
+
+	assertions.Len(evicted, 2)
+
+	shardMap.RemoveAll()
+
+	for i := 0; i < 3; i++ {
+
+		shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+	}
+
+	assertions.Len(evicted, 2)
+
+}
+
+func TestEvictLFU(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	var evicted []string
+
+	shardMap := NewShardMapOf[string, int](1, WithMaxEntries[string, int](3), WithEvictionPolicy[string, int](EvictLFU), WithOnEvict(func(key string, value int) {
+
+		evicted = append(evicted, key)
+
+	}))
+
+	for i := 0; i < 3; i++ {
+
+		key := fmt.Sprintf("test%v", i)
+
+		shardMap.Set(key, i)
+
+		for j := 0; j < 10; j++ {
+
+			shardMap.Get(key)
+
+		}
+
+	}
+
+	t.Run("OneHitWonderRejected", func(t *testing.T) {
+
+		shardMap.Set("onehit", 1)
+
+		assertions.False(shardMap.Contains("onehit"))
+
+		assertions.Equal([]string{"onehit"}, evicted)
+
+		assertions.Equal(3, shardMap.Len())
+
+	})
+
+	t.Run("FrequentKeyAdmitted", func(t *testing.T) {
+
+		for i := 0; i < 20; i++ {
+
+			shardMap.Set("frequent", i)
+
+		}
+
+		assertions.True(shardMap.Contains("frequent"))
+
+		assertions.Equal(3, shardMap.Len())
+
+	})
+
+}
+
+func TestEvictRandom(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	evicted := 0
+
+	shardMap := NewShardMapOf[int, int](4, WithMaxEntries[int, int](100), WithEvictionPolicy[int, int](EvictRandom), WithOnEvict(func(key int, value int) {
+
+		evicted++
+
+	}))
+
+	for i := 0; i < 1000; i++ {
+
+		shardMap.Set(i, i)
+
+	}
+
+	assertions.LessOrEqual(shardMap.Len(), 100)
+
+	assertions.Equal(1000, shardMap.Len()+evicted)
+
+	for _, shard := range shardMap.currentShards() {
+
+		assertions.Equal(25, shard.items.Len())
+
+	}
+
+}
+
+func TestEvictionConcurrent(t *testing.T) {
+
+	for _, policy := range []EvictionPolicy{EvictLRU, EvictLFU, EvictRandom} {
+
+		t.Run(fmt.Sprint(policy), func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardMap := NewShardMapOf[string, int](8, WithMaxEntries[string, int](64), WithEvictionPolicy[string, int](policy))
+
+			var wg sync.WaitGroup
+
+			for worker := 0; worker < 8; worker++ {
+
+				wg.Add(1)
+
+				go func(worker int) {
+
+					defer wg.Done()
+
+					for i := 0; i < 2000; i++ {
+
+						key := fmt.Sprintf("test%v", (i*worker)%500)
+
+						shardMap.Set(key, i)
+
+						shardMap.Get(key)
+
+						if i%10 == 0 {
+
+							shardMap.Remove(key)
+
+						}
+
+					}
+
+				}(worker)
+
+			}
+
+			wg.Wait()
+
+			assertions.LessOrEqual(shardMap.Len(), 64)
+
+		})
+
+	}
+}
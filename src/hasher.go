@@ -0,0 +1,106 @@
+package src
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"github.com/dolthub/maphash"
+	"github.com/go-faster/city"
+	hashmaphash "hash/maphash"
+)
+
+// HasherID identifies a hasher in snapshot headers.
+type HasherID uint8
+
+const (
+	HasherCustom HasherID = iota
+
+	HasherCity
+
+	HasherMaphash
+
+	HasherStdMaphash
+
+	HasherSipHash
+)
+
+func (hasherID HasherID) String() string {
+
+	switch hasherID {
+
+	case HasherCity:
+
+		return "city"
+
+	case HasherMaphash:
+
+		return "maphash"
+
+	case HasherStdMaphash:
+
+		return "std-maphash"
+
+	case HasherSipHash:
+
+		return "siphash"
+
+	}
+
+	return "custom"
+}
+
+// Hasher maps a key to the 64 bit hash its shard is picked from. ModuloRouter
+// only looks at the low 32 bits.
+type Hasher[K comparable] func(key K) uint64
+
+// CityHasher is unseeded, so anyone who knows the number of shards can craft
+// keys that all land in the same shard. Maps fed with untrusted keys should use
+// one of the seeded hashers below instead.
+func CityHasher(key string) uint64 {
+
+	return city.Hash64([]byte(key))
+
+}
+
+// NewMaphashHasher works for any comparable key type by reusing the runtime's
+// own map hash function with a random seed.
+func NewMaphashHasher[K comparable]() Hasher[K] {
+
+	return maphash.NewHasher[K]().Hash
+
+}
+
+// NewStdMaphashHasher hashes strings with hash/maphash under a random seed.
+func NewStdMaphashHasher() Hasher[string] {
+
+	seed := hashmaphash.MakeSeed()
+
+	return func(key string) uint64 {
+
+		return hashmaphash.String(seed, key)
+
+	}
+}
+
+// NewSipHasher hashes strings with SipHash-2-4 under the given 128 bit key.
+// Unlike the maphash hashers, the same key always yields the same hashes, even
+// across processes.
+func NewSipHasher(key [16]byte) Hasher[string] {
+
+	k0, k1 := binary.LittleEndian.Uint64(key[:8]), binary.LittleEndian.Uint64(key[8:])
+
+	return func(key string) uint64 {
+
+		return sipHash24(k0, k1, []byte(key))
+
+	}
+}
+
+// NewRandomSipHasher is NewSipHasher with a key read from crypto/rand.
+func NewRandomSipHasher() Hasher[string] {
+
+	var key [16]byte
+
+	rand.Read(key[:])
+
+	return NewSipHasher(key)
+}
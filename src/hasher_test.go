@@ -0,0 +1,139 @@
+package src
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/go-faster/city"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCityHasher(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	assertions.Equal(city.Hash64([]byte("test")), CityHasher("test"))
+
+}
+
+func TestNewMaphashHasher(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	hasher := NewMaphashHasher[int]()
+
+	assertions.Equal(hasher(42), hasher(42))
+
+	assertions.NotEqual(hasher(42), hasher(43))
+
+}
+
+func TestSipHash24(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	var key [16]byte
+
+	message := make([]byte, 15)
+
+	for i := range key {
+
+		key[i] = byte(i)
+
+	}
+
+	for i := range message {
+
+		message[i] = byte(i)
+
+	}
+
+	k0, k1 := binary.LittleEndian.Uint64(key[:8]), binary.LittleEndian.Uint64(key[8:])
+
+	assertions.Equal(uint64(0x726fdb47dd0e0e31), sipHash24(k0, k1, nil))
+
+	assertions.Equal(uint64(0xa129ca6149be45e5), sipHash24(k0, k1, message))
+
+	assertions.Equal(sipHash24(k0, k1, message[:8]), NewSipHasher(key)(string(message[:8])))
+
+}
+
+func TestSeededHashers(t *testing.T) {
+
+	const numShards = 16
+
+	// Brute force a key set that CityHash, being unseeded, puts entirely into
+	// shard 0, the way an attacker flooding a single shard would.
+	var colliding []string
+
+	for i := 0; len(colliding) < 2000; i++ {
+
+		key := fmt.Sprintf("attack%v", i)
+
+		if fastModN(uint32(CityHasher(key)), numShards) == 0 {
+
+			colliding = append(colliding, key)
+
+		}
+
+	}
+
+	hashers := map[string]func() Hasher[string]{
+
+		"City": func() Hasher[string] { return CityHasher },
+
+		"Maphash": NewMaphashHasher[string],
+
+		"StdMaphash": NewStdMaphashHasher,
+
+		"SipHash": NewRandomSipHasher,
+	}
+
+	for name, newHasher := range hashers {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardMap := NewShardMapOf[string, int](numShards, WithHasher[string, int](newHasher()))
+
+			for i, key := range colliding {
+
+				shardMap.Set(key, i)
+
+			}
+
+			largest := 0
+
+			for _, shard := range shardMap.currentShards() {
+
+				largest = max(largest, shard.items.Len())
+
+			}
+
+			if name == "City" {
+
+				assertions.Equal(len(colliding), largest)
+
+				return
+			}
+
+			assertions.Less(largest, 2*len(colliding)/numShards)
+
+		})
+
+	}
+
+	t.Run("RandomSeeds", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		assertions.NotEqual(NewRandomSipHasher()("test"), NewRandomSipHasher()("test"))
+
+		assertions.NotEqual(NewStdMaphashHasher()("test"), NewStdMaphashHasher()("test"))
+
+		assertions.NotEqual(NewMaphashHasher[string]()("test"), NewMaphashHasher[string]()("test"))
+
+	})
+
+}
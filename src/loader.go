@@ -0,0 +1,273 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Loader fetches the value of a key missing from the map, typically from a
+// slower store the map caches.
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+type LoadOption func(options *loadOptions)
+
+type loadOptions struct {
+	ttl time.Duration
+
+	negativeTTL time.Duration
+
+	refreshAhead time.Duration
+}
+
+// loadCall is a load in flight that every GetOrLoad for its key waits on.
+type loadCall[V any] struct {
+	done chan struct{}
+
+	value V
+
+	err error
+
+	// stale is set under the shard lock when key is written or removed while
+	// the call is in flight, which a refresh must not undo.
+	stale bool
+}
+
+type loadFailure struct {
+	err error
+
+	deadline int64
+}
+
+// LoadTTL caches loaded values for ttl instead of until they are removed.
+func LoadTTL(ttl time.Duration) LoadOption {
+
+	return func(options *loadOptions) {
+
+		options.ttl = ttl
+
+	}
+}
+
+// LoadNegativeTTL caches loader errors for ttl, so that a key that failed to
+// load fails right away instead of hitting the loader again.
+func LoadNegativeTTL(ttl time.Duration) LoadOption {
+
+	return func(options *loadOptions) {
+
+		options.negativeTTL = ttl
+
+	}
+}
+
+// LoadRefreshAhead reloads a value in the background once it has less than
+// window left to live, while GetOrLoad keeps returning the cached value. It
+// only applies to values with a TTL. Failed refreshes are dropped and the
+// value expires as usual, and so are refreshes of a key that was written or
+// removed while they ran.
+func LoadRefreshAhead(window time.Duration) LoadOption {
+
+	return func(options *loadOptions) {
+
+		options.refreshAhead = window
+
+	}
+}
+
+// GetOrLoad returns the value of key, calling loader if it is missing. Calls
+// for the same key while a load is in flight wait for that load instead of
+// starting their own, and all of them get its value or its error. The loader
+// runs on its own goroutine with ctx's values but not its cancellation, so a
+// caller whose ctx is done returns ctx.Err() while the load carries on for the
+// others. A loaded value is only stored if the key is still missing once the
+// load is done; otherwise the value written in the meantime wins.
+func (shardMap *ShardMap[K, V]) GetOrLoad(ctx context.Context, key K, loader Loader[K, V], opts ...LoadOption) (V, error) {
+
+	var options loadOptions
+
+	for _, opt := range opts {
+
+		opt(&options)
+
+	}
+
+	shard := shardMap.rlockShard(key)
+
+	value, ok := shard.get(key)
+
+	refresh := ok && shard.refreshDue(key, options.refreshAhead)
+
+	shard.metrics.lookup(ok)
+
+	shard.RUnlock()
+
+	if ok && !refresh {
+
+		return value, nil
+
+	}
+
+	shard = shardMap.lockShard(key)
+
+	value, ok = shard.get(key)
+
+	if ok && !shard.refreshDue(key, options.refreshAhead) {
+
+		shard.Unlock()
+
+		return value, nil
+	}
+
+	if failure, found := shard.failures[key]; found && !ok {
+
+		if failure.deadline > nowNano() {
+
+			shard.Unlock()
+
+			var zero V
+
+			return zero, failure.err
+		}
+
+		delete(shard.failures, key)
+
+	}
+
+	call, loading := shard.loads[key]
+
+	if !loading {
+
+		call = &loadCall[V]{done: make(chan struct{})}
+
+		if shard.loads == nil {
+
+			shard.loads = make(map[K]*loadCall[V])
+
+		}
+
+		shard.loads[key] = call
+
+		go shardMap.load(context.WithoutCancel(ctx), shard, key, call, loader, options, ok)
+
+	}
+
+	shard.Unlock()
+
+	// A value that is only being refreshed is still good to return.
+	if ok {
+
+		return value, nil
+
+	}
+
+	select {
+
+	case <-call.done:
+
+		return call.value, call.err
+
+	case <-ctx.Done():
+
+		var zero V
+
+		return zero, ctx.Err()
+	}
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+// load runs loader for the call registered in owner and stores its outcome. A
+// refresh only replaces the value it was started for, and a first load only
+// fills a key that stayed missing and untouched. Waiters get the loaded value
+// either way.
+func (shardMap *ShardMap[K, V]) load(ctx context.Context, owner *shard[K, V], key K, call *loadCall[V], loader Loader[K, V], options loadOptions, refresh bool) {
+
+	defer close(call.done)
+
+	call.value, call.err = callLoader(ctx, key, loader)
+
+	shard := shardMap.lockShard(key)
+
+	if call.err == nil {
+
+		_, ok := shard.get(key)
+
+		// A key that a Resize moved may have been written in its new shard,
+		// which does not know about the call.
+		if (refresh && ok && shard == owner || !refresh && !ok) && !call.stale {
+
+			shard.putUntil(key, call.value, deadlineAfter(options.ttl))
+
+		}
+
+		delete(shard.failures, key)
+
+	} else if options.negativeTTL > 0 && !refresh {
+
+		if shard.failures == nil {
+
+			shard.failures = make(map[K]loadFailure)
+
+		}
+
+		shard.failures[key] = loadFailure{err: call.err, deadline: deadlineAfter(options.negativeTTL)}
+
+	}
+
+	// A Resize may have moved the key since the call was registered.
+	if shard != owner {
+
+		shard.Unlock()
+
+		owner.Lock()
+
+		shard = owner
+
+	}
+
+	delete(shard.loads, key)
+
+	shard.Unlock()
+
+}
+
+func callLoader[K comparable, V any](ctx context.Context, key K, loader Loader[K, V]) (value V, err error) {
+
+	defer func() {
+
+		if recovered := recover(); recovered != nil {
+
+			err = fmt.Errorf("loader panicked: %v", recovered)
+
+		}
+
+	}()
+
+	return loader(ctx, key)
+}
+
+// invalidateLoad marks a load in flight for key as stale. It is called by every
+// write and removal of key.
+func (shard *shard[K, V]) invalidateLoad(key K) {
+
+	if call, loading := shard.loads[key]; loading {
+
+		call.stale = true
+
+	}
+}
+
+// refreshDue reports whether key, which must be present, has less than window
+// left to live.
+func (shard *shard[K, V]) refreshDue(key K, window time.Duration) bool {
+
+	if window <= 0 {
+
+		return false
+
+	}
+
+	deadline, found := shard.expiries[key]
+
+	return found && deadline-nowNano() < int64(window)
+}
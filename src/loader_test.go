@@ -0,0 +1,489 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoad(t *testing.T) {
+
+	t.Run("Deduplicated", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(8)
+
+		var calls atomic.Int32
+
+		release := make(chan struct{})
+
+		loader := func(ctx context.Context, key string) (int, error) {
+
+			calls.Add(1)
+
+			<-release
+
+			return len(key), nil
+		}
+
+		var wait sync.WaitGroup
+
+		values := make([]int, 10)
+
+		for i := range values {
+
+			wait.Add(1)
+
+			go func() {
+
+				defer wait.Done()
+
+				values[i], _ = shardMap.GetOrLoad(context.Background(), "test", loader)
+
+			}()
+
+		}
+
+		assertions.Eventually(func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+		close(release)
+
+		wait.Wait()
+
+		assertions.Equal(int32(1), calls.Load())
+
+		assertions.Equal([]int{4, 4, 4, 4, 4, 4, 4, 4, 4, 4}, values)
+
+		value, ok := shardMap.Get("test")
+
+		assertions.True(ok)
+
+		assertions.Equal(4, value)
+
+		value, err := shardMap.GetOrLoad(context.Background(), "test", loader)
+
+		assertions.NoError(err)
+
+		assertions.Equal(4, value)
+
+		assertions.Equal(int32(1), calls.Load())
+
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(8)
+
+		failed := errors.New("unavailable")
+
+		release := make(chan struct{})
+
+		var calls atomic.Int32
+
+		loader := func(ctx context.Context, key string) (int, error) {
+
+			calls.Add(1)
+
+			<-release
+
+			return 0, failed
+		}
+
+		var wait sync.WaitGroup
+
+		errs := make([]error, 5)
+
+		for i := range errs {
+
+			wait.Add(1)
+
+			go func() {
+
+				defer wait.Done()
+
+				_, errs[i] = shardMap.GetOrLoad(context.Background(), "test", loader)
+
+			}()
+
+		}
+
+		assertions.Eventually(func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+		close(release)
+
+		wait.Wait()
+
+		for _, err := range errs {
+
+			assertions.ErrorIs(err, failed)
+
+		}
+
+		assertions.False(shardMap.Contains("test"))
+
+		_, err := shardMap.GetOrLoad(context.Background(), "test", loader)
+
+		assertions.ErrorIs(err, failed)
+
+		assertions.Equal(int32(2), calls.Load())
+
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		advance := fakeClock(t)
+
+		shardMap := NewShardMap(8)
+
+		var calls atomic.Int32
+
+		loader := func(ctx context.Context, key string) (int, error) {
+
+			return int(calls.Add(1)), nil
+		}
+
+		value, err := shardMap.GetOrLoad(context.Background(), "test", loader, LoadTTL(time.Minute))
+
+		assertions.NoError(err)
+
+		assertions.Equal(1, value)
+
+		ttl, ok := shardMap.TTL("test")
+
+		assertions.True(ok)
+
+		assertions.Equal(time.Minute, ttl)
+
+		advance(time.Minute)
+
+		value, _ = shardMap.GetOrLoad(context.Background(), "test", loader, LoadTTL(time.Minute))
+
+		assertions.Equal(2, value)
+
+	})
+
+	t.Run("NegativeTTL", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		advance := fakeClock(t)
+
+		shardMap := NewShardMap(8)
+
+		var calls atomic.Int32
+
+		loader := func(ctx context.Context, key string) (int, error) {
+
+			if calls.Add(1) == 1 {
+
+				return 0, errors.New("unavailable")
+
+			}
+
+			return 1, nil
+		}
+
+		for range 3 {
+
+			_, err := shardMap.GetOrLoad(context.Background(), "test", loader, LoadNegativeTTL(time.Second))
+
+			assertions.EqualError(err, "unavailable")
+
+		}
+
+		assertions.Equal(int32(1), calls.Load())
+
+		advance(time.Second)
+
+		value, err := shardMap.GetOrLoad(context.Background(), "test", loader, LoadNegativeTTL(time.Second))
+
+		assertions.NoError(err)
+
+		assertions.Equal(1, value)
+
+		calls.Store(0)
+
+		for range 2 {
+
+			_, err = shardMap.GetOrLoad(context.Background(), "forever", loader, LoadNegativeTTL(math.MaxInt64))
+
+			assertions.EqualError(err, "unavailable")
+
+		}
+
+		assertions.Equal(int32(1), calls.Load())
+
+	})
+
+	t.Run("RefreshAhead", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		advance := fakeClock(t)
+
+		shardMap := NewShardMap(8)
+
+		var calls atomic.Int32
+
+		release := make(chan struct{})
+
+		loader := func(ctx context.Context, key string) (int, error) {
+
+			if calls.Add(1) > 1 {
+
+				<-release
+
+			}
+
+			return int(calls.Load()), nil
+		}
+
+		opts := []LoadOption{LoadTTL(time.Minute), LoadRefreshAhead(10 * time.Second)}
+
+		value, _ := shardMap.GetOrLoad(context.Background(), "test", loader, opts...)
+
+		assertions.Equal(1, value)
+
+		advance(55 * time.Second)
+
+		for range 3 {
+
+			value, err := shardMap.GetOrLoad(context.Background(), "test", loader, opts...)
+
+			assertions.NoError(err)
+
+			assertions.Equal(1, value)
+
+		}
+
+		assertions.Eventually(func() bool { return calls.Load() == 2 }, time.Second, time.Millisecond)
+
+		close(release)
+
+		assertions.Eventually(func() bool {
+
+			value, _ := shardMap.Get("test")
+
+			return value == 2
+
+		}, time.Second, time.Millisecond)
+
+		ttl, _ := shardMap.TTL("test")
+
+		assertions.Equal(time.Minute, ttl)
+
+		assertions.Equal(int32(2), calls.Load())
+
+	})
+
+	t.Run("RefreshOutdated", func(t *testing.T) {
+
+		for name, write := range map[string]func(shardMap *ShardMap[string, int]){
+
+			"Removed": func(shardMap *ShardMap[string, int]) { shardMap.Remove("test") },
+
+			"Overwritten": func(shardMap *ShardMap[string, int]) { shardMap.Set("test", 100) },
+
+			"Computed": func(shardMap *ShardMap[string, int]) {
+
+				shardMap.Compute("test", func(old int, ok bool) (int, ComputeOp) { return 100, UpdateOp })
+
+			},
+
+			"Cleared": func(shardMap *ShardMap[string, int]) { shardMap.RemoveAll() },
+		} {
+
+			t.Run(name, func(t *testing.T) {
+
+				assertions := assert.New(t)
+
+				advance := fakeClock(t)
+
+				shardMap := NewShardMap(8)
+
+				var calls atomic.Int32
+
+				release := make(chan struct{})
+
+				loader := func(ctx context.Context, key string) (int, error) {
+
+					if calls.Add(1) > 1 {
+
+						<-release
+
+					}
+
+					return int(calls.Load()), nil
+				}
+
+				opts := []LoadOption{LoadTTL(time.Minute), LoadRefreshAhead(10 * time.Second)}
+
+				shardMap.GetOrLoad(context.Background(), "test", loader, opts...)
+
+				advance(55 * time.Second)
+
+				shardMap.GetOrLoad(context.Background(), "test", loader, opts...)
+
+				assertions.Eventually(func() bool { return calls.Load() == 2 }, time.Second, time.Millisecond)
+
+				write(shardMap)
+
+				expected, present := shardMap.Get("test")
+
+				close(release)
+
+				// The refresh is done once its call is no longer registered.
+				assertions.Eventually(func() bool {
+
+					shard := shardMap.rlockShard("test")
+
+					defer shard.RUnlock()
+
+					return len(shard.loads) == 0
+
+				}, time.Second, time.Millisecond)
+
+				value, ok := shardMap.Get("test")
+
+				assertions.Equal(present, ok)
+
+				assertions.Equal(expected, value)
+
+			})
+
+		}
+	})
+
+	t.Run("FirstLoadOutdated", func(t *testing.T) {
+
+		for name, write := range map[string]func(shardMap *ShardMap[string, int]){
+
+			"Removed": func(shardMap *ShardMap[string, int]) { shardMap.Remove("test") },
+
+			"Cleared": func(shardMap *ShardMap[string, int]) { shardMap.RemoveAll() },
+		} {
+
+			t.Run(name, func(t *testing.T) {
+
+				assertions := assert.New(t)
+
+				shardMap := NewShardMap(8)
+
+				var calls atomic.Int32
+
+				release := make(chan struct{})
+
+				loader := func(ctx context.Context, key string) (int, error) {
+
+					calls.Add(1)
+
+					<-release
+
+					return 1, nil
+				}
+
+				done := make(chan int)
+
+				go func() {
+
+					value, _ := shardMap.GetOrLoad(context.Background(), "test", loader)
+
+					done <- value
+
+				}()
+
+				assertions.Eventually(func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+				// The key is written and removed while it loads, so the loaded
+				// value predates the removal and must not bring the key back.
+				shardMap.Set("test", 100)
+
+				write(shardMap)
+
+				close(release)
+
+				assertions.Equal(1, <-done)
+
+				assertions.Eventually(func() bool {
+
+					shard := shardMap.rlockShard("test")
+
+					defer shard.RUnlock()
+
+					return len(shard.loads) == 0
+
+				}, time.Second, time.Millisecond)
+
+				assertions.False(shardMap.Contains("test"))
+
+			})
+
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(8)
+
+		release := make(chan struct{})
+
+		loader := func(ctx context.Context, key string) (int, error) {
+
+			<-release
+
+			return 1, ctx.Err()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		cancel()
+
+		_, err := shardMap.GetOrLoad(ctx, "test", loader)
+
+		assertions.ErrorIs(err, context.Canceled)
+
+		close(release)
+
+		assertions.Eventually(func() bool { return shardMap.Contains("test") }, time.Second, time.Millisecond)
+
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(8)
+
+		_, err := shardMap.GetOrLoad(context.Background(), "test", func(ctx context.Context, key string) (int, error) {
+
+			panic("boom")
+
+		})
+
+		assertions.EqualError(err, "loader panicked: boom")
+
+		value, err := shardMap.GetOrLoad(context.Background(), "test", func(ctx context.Context, key string) (int, error) {
+
+			return 1, nil
+
+		})
+
+		assertions.NoError(err)
+
+		assertions.Equal(1, value)
+
+	})
+
+}
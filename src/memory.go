@@ -0,0 +1,461 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"unsafe"
+)
+
+type MaxBytesPolicy int
+
+// ErrorNotAdmitted is returned by TrySet for an entry dropped for any reason
+// other than WithMaxBytes.
+var ErrorNotAdmitted = errors.New("entry was not admitted to its shard")
+
+const (
+	// MaxBytesEvict evicts entries with the map's EvictionPolicy until a write
+	// fits.
+	MaxBytesEvict MaxBytesPolicy = iota
+
+	// MaxBytesReject drops writes that do not fit. TrySet reports them with a
+	// *MaxBytesError.
+	MaxBytesReject
+)
+
+// MemoryStats is an estimate of the bytes held by every shard and the map as a
+// whole, as returned by MemoryUsage.
+type MemoryStats struct {
+	Shards []ShardMemory
+
+	Total ShardMemory
+}
+
+type ShardMemory struct {
+	Entries int
+
+	// Data is what keys and values reference outside the backend, such as the
+	// contents of strings.
+	Data int64
+
+	// Overhead is the backend's own memory for the entries and their TTLs.
+	Overhead int64
+
+	Bytes int64
+
+	// Limit is the shard's share of WithMaxBytes, or 0 if it is unbounded.
+	Limit int64
+}
+
+// MaxBytesError is returned by TrySet for an entry that does not fit its
+// shard's share of WithMaxBytes.
+type MaxBytesError struct {
+	Size int64
+
+	Used int64
+
+	Limit int64
+}
+
+// shardMemory is the byte accounting of a shard.
+type shardMemory[K comparable, V any] struct {
+	// data is the sum of sizer over the entries. It stays 0 if sizer is nil.
+	data int64
+
+	limit int64
+
+	reject bool
+
+	sizer func(key K, value V) int
+
+	// sizeOld is set if an overwrite changes data, so that the old value has to
+	// be sized before it is replaced.
+	sizeOld bool
+
+	overhead int
+
+	// sized is set if the backend measures itself.
+	sized bool
+
+	// bounded is set if the backend can run out of room.
+	bounded bool
+}
+
+// overheadBackend is implemented by backends that can estimate what they spend
+// on each entry on top of its data.
+type overheadBackend interface {
+	entryOverhead() int
+}
+
+// boundedBackend is implemented by backends that can be full regardless of
+// WithMaxBytes.
+type boundedBackend[K comparable, V any] interface {
+	// tryPut is Put, except that it reports whether there was room.
+	tryPut(key K, value V) bool
+}
+
+// sizedBackend is implemented by backends that know the bytes they hold, which
+// replaces the estimate from the sizer and entryOverhead.
+type sizedBackend[K comparable, V any] interface {
+	bytes() int
+
+	// entryBytes returns how many bytes storing key and value would add.
+	entryBytes(key K, value V) int
+}
+
+// MemoryUsage estimates the bytes held by each shard. The estimate covers the
+// entries, their TTLs and the backend's tables, but not eviction bookkeeping,
+// watchers or the write-ahead log.
+func (shardMap *ShardMap[K, V]) MemoryUsage() MemoryStats {
+
+	shards := shardMap.currentShards()
+
+	stats := MemoryStats{Shards: make([]ShardMemory, len(shards))}
+
+	for shardIndex, shard := range shards {
+
+		shard.RLock()
+
+		usage := ShardMemory{Entries: shard.items.Len(), Data: shard.memory.data, Limit: shard.memory.limit}
+
+		usage.Bytes = shard.usage()
+
+		usage.Overhead = usage.Bytes - usage.Data
+
+		shard.RUnlock()
+
+		stats.Shards[shardIndex] = usage
+
+		stats.Total.Entries += usage.Entries
+
+		stats.Total.Data += usage.Data
+
+		stats.Total.Overhead += usage.Overhead
+
+		stats.Total.Bytes += usage.Bytes
+
+		stats.Total.Limit += usage.Limit
+
+	}
+
+	return stats
+}
+
+// TrySet is Set, except that it returns an error instead of dropping an entry:
+// a *MaxBytesError for one that cannot be made to fit a WithMaxBytes bound, or
+// ErrorNotAdmitted for a key the eviction policy refused or a backend with no
+// room left.
+func (shardMap *ShardMap[K, V]) TrySet(key K, value V) error {
+
+	shard := shardMap.lockShard(key)
+
+	defer shard.Unlock()
+
+	return shard.set(key, value, 0)
+}
+
+func (err *MaxBytesError) Error() string {
+
+	return fmt.Sprintf("entry of %v bytes does not fit shard with %v of %v bytes used", err.Size, err.Used, err.Limit)
+
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func newShardMemory[K comparable, V any](options *options[K, V], numShards int, items Backend[K, V]) shardMemory[K, V] {
+
+	memory := shardMemory[K, V]{sizer: options.sizer, sizeOld: options.sizer != nil}
+
+	if options.maxBytes > 0 {
+
+		memory.limit = max((options.maxBytes+int64(numShards)-1)/int64(numShards), 1)
+
+		memory.reject = options.maxBytesPolicy == MaxBytesReject
+
+	}
+
+	_, memory.bounded = items.(boundedBackend[K, V])
+
+	if _, memory.sized = items.(sizedBackend[K, V]); memory.sized {
+
+		memory.sizer, memory.sizeOld = nil, false
+
+		return memory
+	}
+
+	if backend, ok := items.(overheadBackend); ok {
+
+		memory.overhead = backend.entryOverhead()
+
+	}
+
+	if memory.sizer == nil {
+
+		keySize, valueSize := heapSize[K](), heapSize[V]()
+
+		memory.sizeOld = valueSize != nil
+
+		switch {
+
+		case keySize != nil && valueSize != nil:
+
+			memory.sizer = func(key K, value V) int { return keySize(key) + valueSize(value) }
+
+		case keySize != nil:
+
+			memory.sizer = func(key K, value V) int { return keySize(key) }
+
+		case valueSize != nil:
+
+			memory.sizer = func(key K, value V) int { return valueSize(value) }
+
+		}
+
+	}
+
+	return memory
+}
+
+// heapSize returns the size of what a T references outside itself, or nil if
+// that is not worth tracking.
+func heapSize[T any]() func(value T) int {
+
+	var zero T
+
+	switch any(zero).(type) {
+
+	case string:
+
+		return func(value T) int { return len(*(*string)(unsafe.Pointer(&value))) }
+
+	case []byte:
+
+		return func(value T) int { return cap(*(*[]byte)(unsafe.Pointer(&value))) }
+
+	}
+
+	return nil
+}
+
+// store puts key and value into the backend and accounts for their data. It
+// reports whether the backend had room for them.
+func (shard *shard[K, V]) store(key K, value V) bool {
+
+	memory := &shard.memory
+
+	if memory.bounded {
+
+		return shard.items.(boundedBackend[K, V]).tryPut(key, value)
+
+	}
+
+	if memory.sizer == nil {
+
+		shard.items.Put(key, value)
+
+		return true
+	}
+
+	if memory.sizeOld {
+
+		if old, exists := shard.items.Get(key); exists {
+
+			memory.data -= int64(memory.sizer(key, old))
+
+		}
+
+		shard.items.Put(key, value)
+
+		memory.data += int64(memory.sizer(key, value))
+
+		return true
+	}
+
+	// Entries of the same key have the same size, so only new keys count.
+	entries := shard.items.Len()
+
+	shard.items.Put(key, value)
+
+	if shard.items.Len() > entries {
+
+		memory.data += int64(memory.sizer(key, value))
+
+	}
+
+	return true
+}
+
+// usage returns the estimated bytes held by the shard.
+func (shard *shard[K, V]) usage() int64 {
+
+	var zeroKey K
+
+	expiries := int64(len(shard.expiries)) * int64(unsafe.Sizeof(zeroKey)+9) * 16 / 13
+
+	if shard.memory.sized {
+
+		return int64(shard.items.(sizedBackend[K, V]).bytes()) + expiries
+
+	}
+
+	return shard.memory.data + int64(shard.items.Len())*int64(shard.memory.overhead) + expiries
+}
+
+// entryDelta returns how much storing key and value would change usage.
+func (shard *shard[K, V]) entryDelta(key K, value V) int64 {
+
+	if shard.memory.sized {
+
+		return int64(shard.items.(sizedBackend[K, V]).entryBytes(key, value))
+
+	}
+
+	var delta int64
+
+	old, exists := shard.items.Get(key)
+
+	if !exists {
+
+		delta = int64(shard.memory.overhead)
+
+	}
+
+	if shard.memory.sizer != nil {
+
+		delta += int64(shard.memory.sizer(key, value))
+
+		if exists {
+
+			delta -= int64(shard.memory.sizer(key, old))
+
+		}
+
+	}
+
+	return delta
+}
+
+// makeBytes makes room for key and value under the shard's byte limit and
+// reports whether they fit.
+func (shard *shard[K, V]) makeBytes(key K, value V) bool {
+
+	if shard.memory.limit <= 0 {
+
+		return true
+
+	}
+
+	delta := shard.entryDelta(key, value)
+
+	if delta > shard.memory.limit {
+
+		return false
+
+	}
+
+	for shard.usage()+delta > shard.memory.limit {
+
+		if shard.memory.reject || shard.policy == nil {
+
+			return false
+
+		}
+
+		victim, ok := shard.policy.victim()
+
+		if !ok || victim == key {
+
+			return false
+
+		}
+
+		shard.evict(victim)
+
+	}
+
+	return true
+}
+
+// refuse drops a write that a bounded shard had no room for.
+func (shard *shard[K, V]) refuse(key K, value V) {
+
+	shard.metrics.evict()
+
+	if shard.onEvict != nil {
+
+		shard.onEvict(key, value)
+
+	}
+}
+
+// forget undoes the registration of a refused new key with the eviction
+// policy, which admit makes before the backend is known to have room for it.
+func (shard *shard[K, V]) forget(key K) {
+
+	if _, exists := shard.items.Get(key); !exists && shard.policy != nil {
+
+		shard.policy.remove(key)
+
+	}
+}
+
+// Builtin maps fill about 13 of every 16 slots and keep a byte of hash per
+// slot.
+func (backend mapBackend[K, V]) entryOverhead() int {
+
+	var key K
+
+	var value V
+
+	return int(unsafe.Sizeof(key)+unsafe.Sizeof(value)+1) * 16 / 13
+}
+
+// swiss.Map fills up to 7 of every 8 slots and keeps a control byte per slot.
+func (backend swissBackend[K, V]) entryOverhead() int {
+
+	var key K
+
+	var value V
+
+	return int(unsafe.Sizeof(key)+unsafe.Sizeof(value)+1) * 8 / 7
+}
+
+// arenaIndexSlot is the cost of a uint64 to uint32 entry in the arena's index.
+const arenaIndexSlot = (8 + 4 + 1) * 16 / 13
+
+func (backend *arenaBackend[K, V]) bytes() int {
+
+	return len(backend.arena) - backend.garbage + backend.Len()*arenaIndexSlot
+}
+
+func (backend *arenaBackend[K, V]) entryBytes(key K, value V) int {
+
+	encoded := backend.keyCodec.Append(nil, key)
+
+	size := len(encoded) + len(backend.valueCodec.Append(nil, value))
+
+	size += uvarintLen(len(encoded)) + uvarintLen(size-len(encoded))
+
+	if offset, ok := backend.find(encoded); ok {
+
+		start, keyLength, valueLength := backend.header(offset)
+
+		return size - (start - int(offset) + keyLength + valueLength)
+
+	}
+
+	return size + arenaIndexSlot
+}
+
+func uvarintLen(value int) int {
+
+	return max(1, (bits.Len64(uint64(value))+6)/7)
+}
+
+// B-tree nodes are between half and completely full, so about three quarters.
+func (backend *sortedBackend[K, V]) entryOverhead() int {
+
+	var entry btreeEntry[K, V]
+
+	return int(unsafe.Sizeof(entry))*4/3 + 1
+}
@@ -0,0 +1,231 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryUsage(t *testing.T) {
+
+	t.Run("Accounting", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMapOf[string, string](4)
+
+		for i := 0; i < 100; i++ {
+
+			shardMap.Set(fmt.Sprintf("key%03d", i), "value")
+
+		}
+
+		usage := shardMap.MemoryUsage()
+
+		assertions.Len(usage.Shards, 4)
+
+		assertions.Equal(100, usage.Total.Entries)
+
+		assertions.Equal(int64(100*(6+5)), usage.Total.Data)
+
+		assertions.Equal(int64(100*mapBackend[string, string]{}.entryOverhead()), usage.Total.Overhead)
+
+		assertions.Equal(usage.Total.Data+usage.Total.Overhead, usage.Total.Bytes)
+
+		assertions.Zero(usage.Total.Limit)
+
+		shardMap.Set("key000", "longer value")
+
+		shardMap.Remove("key001")
+
+		shardMap.SetWithTTL("key002", "value", time.Minute)
+
+		usage = shardMap.MemoryUsage()
+
+		assertions.Equal(int64(99*(6+5)+7), usage.Total.Data)
+
+		assertions.Greater(usage.Total.Overhead, int64(99*mapBackend[string, string]{}.entryOverhead()))
+
+		shardMap.RemoveAll()
+
+		assertions.Zero(shardMap.MemoryUsage().Total.Bytes)
+
+	})
+
+	t.Run("Backends", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardSwissMap := NewShardSwissMap(1)
+
+		shardArenaMap := NewShardMapOf[string, int](1, WithBackend(ArenaBackend[string, int](StringCodec{}, VarintCodec[int]{})))
+
+		for i := 0; i < 100; i++ {
+
+			shardSwissMap.Set(fmt.Sprintf("key%03d", i), i)
+
+			shardArenaMap.Set(fmt.Sprintf("key%03d", i), i)
+
+		}
+
+		usage := shardSwissMap.MemoryUsage()
+
+		assertions.Equal(int64(100*6), usage.Total.Data)
+
+		assertions.Equal(int64(100*swissBackend[string, int]{}.entryOverhead()), usage.Total.Overhead)
+
+		usage = shardArenaMap.MemoryUsage()
+
+		assertions.Zero(usage.Total.Data)
+
+		before := usage.Total.Bytes
+
+		assertions.Greater(before, int64(100*(1+6+1)))
+
+		shardArenaMap.Remove("key000")
+
+		assertions.Less(shardArenaMap.MemoryUsage().Total.Bytes, before)
+
+	})
+
+	t.Run("Sizer", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMapOf[int, []string](1, WithSizer(func(key int, value []string) int {
+
+			return 24 * len(value)
+
+		}))
+
+		shardMap.Set(1, []string{"a", "b"})
+
+		shardMap.Set(1, []string{"a"})
+
+		shardMap.Set(2, nil)
+
+		assertions.Equal(int64(24), shardMap.MemoryUsage().Total.Data)
+
+	})
+
+}
+
+func TestMaxBytes(t *testing.T) {
+
+	t.Run("Reject", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		var rejected []string
+
+		shardMap := NewShardMapOf[string, string](1, WithMaxBytes[string, string](1000, MaxBytesReject), WithOnEvict(func(key, value string) {
+
+			rejected = append(rejected, key)
+
+		}))
+
+		for i := 0; shardMap.TrySet(fmt.Sprintf("key%03d", i), "value") == nil; i++ {
+
+		}
+
+		entries := shardMap.Len()
+
+		assertions.Greater(entries, 0)
+
+		assertions.LessOrEqual(shardMap.MemoryUsage().Total.Bytes, int64(1000))
+
+		err := shardMap.TrySet("another", "value")
+
+		var maxBytesError *MaxBytesError
+
+		assertions.True(errors.As(err, &maxBytesError))
+
+		assertions.Equal(int64(1000), maxBytesError.Limit)
+
+		shardMap.Set("another", "value")
+
+		assertions.Equal(entries, shardMap.Len())
+
+		assertions.Equal([]string{"another"}, rejected)
+
+		assertions.NoError(shardMap.TrySet("key000", "v"))
+
+	})
+
+	t.Run("Evict", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMapOf[string, string](4, WithMaxBytes[string, string](4000, MaxBytesEvict))
+
+		for i := 0; i < 1000; i++ {
+
+			shardMap.Set(fmt.Sprintf("key%03d", i), "value")
+
+		}
+
+		usage := shardMap.MemoryUsage()
+
+		assertions.Less(usage.Total.Entries, 1000)
+
+		assertions.Equal(int64(4000), usage.Total.Limit)
+
+		for _, shard := range usage.Shards {
+
+			assertions.LessOrEqual(shard.Bytes, shard.Limit)
+
+		}
+
+		assertions.True(shardMap.Contains("key999"))
+
+		assertions.False(shardMap.Contains("key000"))
+
+		err := shardMap.TrySet("huge", string(make([]byte, 2000)))
+
+		var maxBytesError *MaxBytesError
+
+		assertions.True(errors.As(err, &maxBytesError))
+
+		assertions.Equal(usage.Total.Entries, shardMap.Len())
+
+	})
+
+	t.Run("Admission", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		var evicted []string
+
+		// A fixed hasher keeps "cold" from sharing every sketch counter with a
+		// hot key on some runs.
+		shardMap := NewShardMapOf[string, string](1, WithHasher[string, string](NewSipHasher([16]byte{})), WithMaxEntries[string, string](10), WithEvictionPolicy[string, string](EvictLFU), WithMaxBytes[string, string](2000, MaxBytesEvict), WithOnEvict(func(key, value string) {
+
+			evicted = append(evicted, key)
+
+		}))
+
+		for round := 0; round < 5; round++ {
+
+			for i := 0; i < 10; i++ {
+
+				shardMap.Set(fmt.Sprintf("key%v", i), "value")
+
+				shardMap.Get(fmt.Sprintf("key%v", i))
+
+			}
+
+		}
+
+		// A cold key that would need byte evictions is refused before any of
+		// them happen.
+		assertions.ErrorIs(shardMap.TrySet("cold", string(make([]byte, 1500))), ErrorNotAdmitted)
+
+		assertions.Empty(evicted)
+
+		assertions.Equal(10, shardMap.Len())
+
+	})
+
+}
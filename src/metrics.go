@@ -0,0 +1,285 @@
+package src
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Stats describes a map at the time Stats was called. Everything but Entries
+// is only counted for maps created WithMetrics. Counters start over for the
+// shards a Resize creates.
+type Stats struct {
+	// Shards holds the stats of the current shards. The totals also count the
+	// shards a Resize is still migrating out of.
+	Shards []ShardStats
+
+	Total ShardStats
+}
+
+type ShardStats struct {
+	// Entries includes expired entries that have not been removed yet.
+	Entries int
+
+	// Hits and Misses count lookups by Get, Contains, GetMany and
+	// ContainsMany.
+	Hits uint64
+
+	Misses uint64
+
+	Sets uint64
+
+	// Removes counts every entry removed, including evictions and expired
+	// entries swept by RemoveExpired, but not RemoveAll.
+	Removes uint64
+
+	// Evictions counts entries evicted to respect WithMaxEntries and new
+	// entries refused admission.
+	Evictions uint64
+
+	// LockWait is the time spent waiting for the lock of the shard by single
+	// key operations and batches.
+	LockWait time.Duration
+}
+
+type shardMetrics struct {
+	created time.Time
+
+	hits atomic.Uint64
+
+	misses atomic.Uint64
+
+	sets atomic.Uint64
+
+	removes atomic.Uint64
+
+	evictions atomic.Uint64
+
+	lockWait atomic.Int64
+}
+
+// Stats collects the stats of every shard, read locking one shard at a time.
+func (shardMap *ShardMap[K, V]) Stats() Stats {
+
+	shardMap.resizeMu.RLock()
+
+	defer shardMap.resizeMu.RUnlock()
+
+	table := shardMap.table.Load()
+
+	var stats Stats
+
+	for _, shard := range table.previous {
+
+		stats.Total.add(shard.stats())
+
+	}
+
+	stats.Shards = make([]ShardStats, len(table.shards))
+
+	for shardIndex, shard := range table.shards {
+
+		stats.Shards[shardIndex] = shard.stats()
+
+		stats.Total.add(stats.Shards[shardIndex])
+
+	}
+
+	return stats
+}
+
+// PublishExpvar exports Stats as the expvar variable name. Like
+// expvar.Publish it panics if name is already in use.
+func (shardMap *ShardMap[K, V]) PublishExpvar(name string) {
+
+	expvar.Publish(name, expvar.Func(func() any {
+
+		return shardMap.Stats()
+
+	}))
+}
+
+// WritePrometheus writes Stats in the Prometheus text format, one series per
+// shard labeled with the shard index and, unless name is empty, map="name".
+func (shardMap *ShardMap[K, V]) WritePrometheus(w io.Writer, name string) error {
+
+	stats := shardMap.Stats()
+
+	labels := ""
+
+	if name != "" {
+
+		labels = `map="` + escapeLabel(name) + `",`
+
+	}
+
+	metrics := []struct {
+		name, kind, help string
+
+		value func(stats ShardStats) string
+	}{
+		{"shardmap_entries", "gauge", "Entries stored in the shard.", func(stats ShardStats) string { return strconv.Itoa(stats.Entries) }},
+
+		{"shardmap_hits_total", "counter", "Lookups that found their key.", func(stats ShardStats) string { return strconv.FormatUint(stats.Hits, 10) }},
+
+		{"shardmap_misses_total", "counter", "Lookups that did not find their key.", func(stats ShardStats) string { return strconv.FormatUint(stats.Misses, 10) }},
+
+		{"shardmap_sets_total", "counter", "Entries stored.", func(stats ShardStats) string { return strconv.FormatUint(stats.Sets, 10) }},
+
+		{"shardmap_removes_total", "counter", "Entries removed, including evictions.", func(stats ShardStats) string { return strconv.FormatUint(stats.Removes, 10) }},
+
+		{"shardmap_evictions_total", "counter", "Entries evicted or refused admission.", func(stats ShardStats) string { return strconv.FormatUint(stats.Evictions, 10) }},
+
+		{"shardmap_lock_wait_seconds_total", "counter", "Time spent waiting for the shard lock.", func(stats ShardStats) string {
+			return strconv.FormatFloat(stats.LockWait.Seconds(), 'g', -1, 64)
+		}},
+	}
+
+	var output strings.Builder
+
+	for _, metric := range metrics {
+
+		fmt.Fprintf(&output, "# HELP %v %v\n# TYPE %v %v\n", metric.name, metric.help, metric.name, metric.kind)
+
+		for shardIndex, shard := range stats.Shards {
+
+			fmt.Fprintf(&output, "%v{%vshard=\"%v\"} %v\n", metric.name, labels, shardIndex, metric.value(shard))
+
+		}
+
+	}
+
+	_, err := io.WriteString(w, output.String())
+
+	return err
+}
+
+// PrometheusHandler serves WritePrometheus for scrapes.
+func (shardMap *ShardMap[K, V]) PrometheusHandler(name string) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		shardMap.WritePrometheus(w, name)
+
+	})
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func (shard *shard[K, V]) stats() ShardStats {
+
+	shard.RLock()
+
+	stats := ShardStats{Entries: shard.items.Len()}
+
+	shard.RUnlock()
+
+	if metrics := shard.metrics; metrics != nil {
+
+		stats.Hits, stats.Misses = metrics.hits.Load(), metrics.misses.Load()
+
+		stats.Sets, stats.Removes, stats.Evictions = metrics.sets.Load(), metrics.removes.Load(), metrics.evictions.Load()
+
+		stats.LockWait = time.Duration(metrics.lockWait.Load())
+
+	}
+
+	return stats
+}
+
+func (stats *ShardStats) add(other ShardStats) {
+
+	stats.Entries += other.Entries
+
+	stats.Hits += other.Hits
+
+	stats.Misses += other.Misses
+
+	stats.Sets += other.Sets
+
+	stats.Removes += other.Removes
+
+	stats.Evictions += other.Evictions
+
+	stats.LockWait += other.LockWait
+
+}
+
+// lock takes the shard lock with lock, timing the wait if metrics are on.
+func (shard *shard[K, V]) lock(lock func(*shard[K, V])) {
+
+	if shard.metrics == nil {
+
+		lock(shard)
+
+		return
+	}
+
+	start := time.Now()
+
+	lock(shard)
+
+	shard.metrics.lockWait.Add(int64(time.Since(start)))
+
+}
+
+// The counting methods do nothing on nil metrics, which keeps their cost for
+// maps without WithMetrics to a nil check.
+func (metrics *shardMetrics) lookup(found bool) {
+
+	if metrics == nil {
+
+		return
+
+	}
+
+	if found {
+
+		metrics.hits.Add(1)
+
+	} else {
+
+		metrics.misses.Add(1)
+
+	}
+}
+
+func (metrics *shardMetrics) set() {
+
+	if metrics != nil {
+
+		metrics.sets.Add(1)
+
+	}
+}
+
+func (metrics *shardMetrics) remove() {
+
+	if metrics != nil {
+
+		metrics.removes.Add(1)
+
+	}
+}
+
+func (metrics *shardMetrics) evict() {
+
+	if metrics != nil {
+
+		metrics.evictions.Add(1)
+
+	}
+}
+
+func escapeLabel(value string) string {
+
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+
+}
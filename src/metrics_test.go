@@ -0,0 +1,157 @@
+package src
+
+import (
+	"expvar"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+
+	t.Run("Enabled", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMapOf[int, int](4, WithMetrics[int, int](), WithMaxEntries[int, int](40))
+
+		for i := 0; i < 100; i++ {
+
+			shardMap.Set(i, i)
+
+		}
+
+		hits := 0
+
+		for i := 0; i < 100; i++ {
+
+			if shardMap.Contains(i) {
+
+				hits++
+
+			}
+
+		}
+
+		shardMap.GetMany([]int{-1, -2})
+
+		removed := shardMap.RemoveMany([]int{99, 98})
+
+		stats := shardMap.Stats()
+
+		assertions.Len(stats.Shards, 4)
+
+		assertions.Equal(shardMap.Len(), stats.Total.Entries)
+
+		assertions.Equal(uint64(hits), stats.Total.Hits)
+
+		assertions.Equal(uint64(100-hits+2), stats.Total.Misses)
+
+		assertions.Equal(uint64(100), stats.Total.Sets)
+
+		assertions.Equal(stats.Total.Sets-uint64(stats.Total.Entries), stats.Total.Removes)
+
+		assertions.Equal(stats.Total.Removes-uint64(removed), stats.Total.Evictions)
+
+		assertions.Positive(stats.Total.LockWait)
+
+		sum := 0
+
+		for _, shard := range stats.Shards {
+
+			sum += shard.Entries
+
+		}
+
+		assertions.Equal(stats.Total.Entries, sum)
+
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(4)
+
+		shardMap.Set("test", 1)
+
+		shardMap.Get("test")
+
+		stats := shardMap.Stats()
+
+		assertions.Equal(ShardStats{Entries: 1}, stats.Total)
+
+	})
+
+	t.Run("Resize", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMapOf[int, int](2, WithMetrics[int, int]())
+
+		for i := 0; i < 10000; i++ {
+
+			shardMap.Set(i, i)
+
+		}
+
+		assertions.NoError(shardMap.Resize(8))
+
+		assertions.Len(shardMap.Stats().Shards, 8)
+
+		assertions.Equal(10000, shardMap.Stats().Total.Entries)
+
+	})
+}
+
+func TestPrometheus(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardMap := NewShardMapOf[string, int](2, WithMetrics[string, int]())
+
+	shardMap.Set("test", 1)
+
+	shardMap.Get("test")
+
+	recorder := httptest.NewRecorder()
+
+	shardMap.PrometheusHandler(`my "map"`).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := recorder.Body.String()
+
+	assertions.Contains(recorder.Header().Get("Content-Type"), "text/plain")
+
+	assertions.Contains(body, "# TYPE shardmap_hits_total counter\n")
+
+	shard := shardMap.GetShardIndex("test")
+
+	assertions.Contains(body, fmt.Sprintf("shardmap_entries{map=\"my \\\"map\\\"\",shard=\"%v\"} 1\n", shard))
+
+	assertions.Contains(body, fmt.Sprintf("shardmap_hits_total{map=\"my \\\"map\\\"\",shard=\"%v\"} 1\n", shard))
+
+	var builder strings.Builder
+
+	assertions.NoError(shardMap.WritePrometheus(&builder, ""))
+
+	assertions.Contains(builder.String(), fmt.Sprintf("shardmap_sets_total{shard=\"%v\"} 1\n", shard))
+
+	for _, line := range strings.Split(strings.TrimSpace(builder.String()), "\n") {
+
+		assertions.True(strings.HasPrefix(line, "#") || strings.HasPrefix(line, "shardmap_"), line)
+
+	}
+}
+
+func TestPublishExpvar(t *testing.T) {
+
+	shardMap := NewShardMap(2)
+
+	shardMap.Set("test", 1)
+
+	shardMap.PublishExpvar("TestPublishExpvar")
+
+	assert.Contains(t, expvar.Get("TestPublishExpvar").String(), `"Entries":1`)
+}
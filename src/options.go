@@ -0,0 +1,282 @@
+package src
+
+import (
+	"runtime"
+	"time"
+)
+
+type Option[K comparable, V any] func(options *options[K, V])
+
+type options[K comparable, V any] struct {
+	hasher Hasher[K]
+
+	hasherID HasherID
+
+	router Router
+
+	backend BackendFactory[K, V]
+
+	janitorInterval time.Duration
+
+	maxEntries int
+
+	evictionPolicy EvictionPolicy
+
+	onEvict func(key K, value V)
+
+	keyCodec Codec[K]
+
+	valueCodec Codec[V]
+
+	syncPolicy SyncPolicy
+
+	compactAt int64
+
+	batchWorkers int
+
+	metrics bool
+
+	hotKeys int
+
+	maxBytes int64
+
+	maxBytesPolicy MaxBytesPolicy
+
+	sizer func(key K, value V) int
+
+	// log is set by OpenShardMap once the map is recovered rather than by an
+	// Option, so that shards created by Resize log too.
+	log *writeAheadLog[K, V]
+
+	watchers *watchHub[K, V]
+}
+
+func WithHasher[K comparable, V any](hasher Hasher[K]) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.hasher, options.hasherID = hasher, HasherCustom
+
+	}
+}
+
+// WithHasherID records which hasher WithHasher was given, for snapshot headers.
+// It does not change the hasher.
+func WithHasherID[K comparable, V any](hasherID HasherID) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.hasherID = hasherID
+
+	}
+}
+
+// WithRouter selects how keys are assigned to shards. It defaults to
+// ModuloRouter.
+func WithRouter[K comparable, V any](router Router) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.router = router
+
+	}
+}
+
+// WithBackend selects the store used for every shard. Shards default to the
+// builtin map.
+func WithBackend[K comparable, V any](backend BackendFactory[K, V]) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.backend = backend
+
+	}
+}
+
+// WithJanitor starts a goroutine that removes expired entries every interval,
+// locking one shard at a time. It runs until the map is closed.
+func WithJanitor[K comparable, V any](interval time.Duration) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.janitorInterval = interval
+
+	}
+}
+
+// WithMaxEntries bounds the map to roughly maxEntries entries. The bound is
+// split evenly between the shards and each shard evicts on its own, so a full
+// shard evicts even if others still have room.
+func WithMaxEntries[K comparable, V any](maxEntries int) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.maxEntries = maxEntries
+
+	}
+}
+
+// WithEvictionPolicy selects how a full shard picks its victim. It defaults to
+// EvictLRU.
+func WithEvictionPolicy[K comparable, V any](policy EvictionPolicy) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.evictionPolicy = policy
+
+	}
+}
+
+// WithOnEvict registers a callback for every entry dropped to respect
+// WithMaxEntries, including new entries refused by EvictLFU admission. It runs
+// under the shard's write lock and must not access the map.
+func WithOnEvict[K comparable, V any](onEvict func(key K, value V)) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.onEvict = onEvict
+
+	}
+}
+
+// WithCodecs sets how keys and values are encoded by SaveTo and LoadFrom. The
+// string to int constructors use StringCodec and VarintCodec.
+func WithCodecs[K comparable, V any](keyCodec Codec[K], valueCodec Codec[V]) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.keyCodec, options.valueCodec = keyCodec, valueCodec
+
+	}
+}
+
+// WithSyncPolicy selects when the write-ahead log of a map opened by
+// OpenShardMap is fsynced. It defaults to SyncAlways.
+func WithSyncPolicy[K comparable, V any](policy SyncPolicy) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.syncPolicy = policy
+
+	}
+}
+
+// WithCompactAt compacts the map in the background whenever its current log
+// segment grows past size bytes. Without it the log only shrinks on Compact.
+func WithCompactAt[K comparable, V any](size int64) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.compactAt = size
+
+	}
+}
+
+// WithBatchWorkers spreads the shards touched by SetMany, GetMany, RemoveMany
+// and ContainsMany over up to workers goroutines, GOMAXPROCS if workers <= 0.
+// Batches run on the calling goroutine by default.
+func WithBatchWorkers[K comparable, V any](workers int) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		if workers <= 0 {
+
+			workers = runtime.GOMAXPROCS(0)
+
+		}
+
+		options.batchWorkers = workers
+
+	}
+}
+
+// WithMetrics counts hits, misses, writes, evictions and lock waits per shard
+// for Stats, at the cost of a few atomic adds per operation.
+func WithMetrics[K comparable, V any]() Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.metrics = true
+
+	}
+}
+
+// WithHotKeys tracks the size most accessed keys of every shard for HotKeys and
+// AnalyzeSkew. Every access then also updates a count-min sketch under a
+// per-shard mutex.
+func WithHotKeys[K comparable, V any](size int) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.hotKeys = size
+
+	}
+}
+
+// WithMaxBytes bounds the map to roughly maxBytes as estimated by MemoryUsage.
+// Like WithMaxEntries the bound is split evenly between the shards. policy
+// decides whether a full shard evicts or rejects writes.
+func WithMaxBytes[K comparable, V any](maxBytes int64, policy MaxBytesPolicy) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.maxBytes, options.maxBytesPolicy = maxBytes, policy
+
+	}
+}
+
+// WithSizer replaces the estimate of the bytes an entry references outside the
+// backend. By default only the contents of string and []byte keys and values
+// are counted, so maps holding pointers or structs of strings should set one.
+func WithSizer[K comparable, V any](sizer func(key K, value V) int) Option[K, V] {
+
+	return func(options *options[K, V]) {
+
+		options.sizer = sizer
+
+	}
+}
+
+// stringIntOptions are the options of the original string to int maps.
+func stringIntOptions() []Option[string, int] {
+
+	return []Option[string, int]{
+
+		WithHasher[string, int](CityHasher),
+
+		WithHasherID[string, int](HasherCity),
+
+		WithCodecs[string, int](StringCodec{}, VarintCodec[int]{}),
+	}
+}
+
+func newOptions[K comparable, V any](opts []Option[K, V]) *options[K, V] {
+
+	options := &options[K, V]{}
+
+	for _, opt := range opts {
+
+		opt(options)
+
+	}
+
+	if options.hasher == nil {
+
+		options.hasher, options.hasherID = NewMaphashHasher[K](), HasherMaphash
+
+	}
+
+	if options.router == nil {
+
+		options.router = ModuloRouter{}
+
+	}
+
+	if options.backend == nil {
+
+		options.backend = MapBackend[K, V]
+
+	}
+
+	return options
+}
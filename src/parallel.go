@@ -0,0 +1,245 @@
+package src
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ParallelIter calls fn for every entry, visiting up to workers shards at a
+// time. workers <= 0 means GOMAXPROCS. Each shard is visited under its read
+// lock, so fn must not write to the map, but it is called concurrently for
+// different shards. A Resize started meanwhile waits for ParallelIter to return.
+//
+// If fn fails, shards after the failing one are abandoned while those before it
+// run to completion, so the error returned is always the one of the lowest
+// failing shard, no matter how the work was scheduled. During a Resize the
+// shards of the previous table come first. If ctx is done first, ParallelIter
+// stops and returns ctx.Err().
+func (shardMap *ShardMap[K, V]) ParallelIter(ctx context.Context, workers int, fn func(shardIndex int, key K, value V) error) error {
+
+	var errs []error
+
+	var failed atomic.Int64
+
+	failed.Store(math.MaxInt64)
+
+	walkShardsParallel[K, V](shardMap, workers, func(numShards int) {
+
+		errs = make([]error, numShards)
+
+	}, func(position, shardIndex int, key K, value V) bool {
+
+		if int64(position) > failed.Load() {
+
+			return true
+
+		}
+
+		select {
+
+		case <-ctx.Done():
+
+			return true
+
+		default:
+
+		}
+
+		if errs[position] = fn(shardIndex, key, value); errs[position] != nil {
+
+			for lowest := failed.Load(); int64(position) < lowest; lowest = failed.Load() {
+
+				if failed.CompareAndSwap(lowest, int64(position)) {
+
+					break
+				}
+
+			}
+
+			return true
+
+		}
+
+		return false
+
+	})
+
+	for _, err := range errs {
+
+		if err != nil {
+
+			return err
+
+		}
+
+	}
+
+	return ctx.Err()
+}
+
+// MapReduce maps every entry with mapFn and folds the results with reduceFn,
+// visiting up to workers shards at a time like ParallelIter. Every shard is
+// reduced on its own first and the per shard results are then reduced in shard
+// order. ok is false if the map is empty.
+func MapReduce[K comparable, V any, R any](ctx context.Context, shardedMap ShardedMap[K, V], workers int, mapFn func(shardIndex int, key K, value V) R, reduceFn func(a, b R) R) (result R, ok bool, err error) {
+
+	var partials []R
+
+	var found []bool
+
+	walkShardsParallel(shardedMap, workers, func(numShards int) {
+
+		partials, found = make([]R, numShards), make([]bool, numShards)
+
+	}, func(position, shardIndex int, key K, value V) bool {
+
+		select {
+
+		case <-ctx.Done():
+
+			return true
+
+		default:
+
+		}
+
+		mapped := mapFn(shardIndex, key, value)
+
+		if found[position] {
+
+			partials[position] = reduceFn(partials[position], mapped)
+
+		} else {
+
+			partials[position], found[position] = mapped, true
+
+		}
+
+		return false
+
+	})
+
+	if err = ctx.Err(); err != nil {
+
+		return result, false, err
+
+	}
+
+	for position, partial := range partials {
+
+		if !found[position] {
+
+			continue
+		}
+
+		if ok {
+
+			result = reduceFn(result, partial)
+
+		} else {
+
+			result, ok = partial, true
+
+		}
+
+	}
+
+	return result, ok, nil
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+// walkShardsParallel visits every shard of shardedMap from up to workers
+// goroutines while holding the resize lock, as reduceShards does, so that a
+// Resize cannot swap the table halfway and make the walk skip keys. prepare is
+// told how many shards there are before any is visited. visit gets the position
+// of the shard in the walk and the index of the current shard every entry
+// belongs to, and returns true to stop walking that shard.
+func walkShardsParallel[K comparable, V any](shardedMap ShardedMap[K, V], workers int, prepare func(numShards int), visit func(position, shardIndex int, key K, value V) bool) {
+
+	withShardMap, ok := shardedMap.(interface{ shardMapOf() *ShardMap[K, V] })
+
+	if !ok {
+
+		prepare(1)
+
+		shardedMap.Iter(func(key K, value V) bool {
+
+			return visit(0, int(shardedMap.GetShardIndex(key)), key, value)
+
+		})
+
+		return
+	}
+
+	shardMap := withShardMap.shardMapOf()
+
+	shardMap.resizeMu.RLock()
+
+	defer shardMap.resizeMu.RUnlock()
+
+	table := shardMap.table.Load()
+
+	shards := table.all()
+
+	prepare(len(shards))
+
+	forEachShardParallel(len(shards), workers, func(position int) {
+
+		shardIndex := position - len(table.previous)
+
+		shards[position].iter(func(key K, value V) bool {
+
+			if position < len(table.previous) {
+
+				return visit(position, int(shardMap.route(shardMap.hasher(key), len(table.shards))), key, value)
+
+			}
+
+			return visit(position, shardIndex, key, value)
+
+		})
+
+	})
+}
+
+// forEachShardParallel calls fn once for every shard index from a pool of at
+// most workers goroutines, GOMAXPROCS if workers <= 0, and returns once all
+// calls are done.
+func forEachShardParallel(numShards, workers int, fn func(shardIndex int)) {
+
+	if workers <= 0 {
+
+		workers = runtime.GOMAXPROCS(0)
+
+	}
+
+	workers = min(workers, numShards)
+
+	var next atomic.Int64
+
+	var wg sync.WaitGroup
+
+	for worker := 0; worker < workers; worker++ {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			for shardIndex := int(next.Add(1) - 1); shardIndex < numShards; shardIndex = int(next.Add(1) - 1) {
+
+				fn(shardIndex)
+
+			}
+
+		}()
+
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,267 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelIter(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(32)
+
+			for i := 0; i < 10000; i++ {
+
+				shardedMap.Set(fmt.Sprintf("test%v", i), i)
+
+			}
+
+			t.Run("VisitsEverything", func(t *testing.T) {
+
+				var count atomic.Int64
+
+				err := shardedMap.ParallelIter(context.Background(), 4, func(shardIndex int, key string, value int) error {
+
+					assertions.Equal(uint32(shardIndex), shardedMap.GetShardIndex(key))
+
+					count.Add(1)
+
+					return nil
+
+				})
+
+				assertions.NoError(err)
+
+				assertions.Equal(int64(10000), count.Load())
+
+			})
+
+			t.Run("LowestShardErrorWins", func(t *testing.T) {
+
+				for _, workers := range []int{1, 4, 32} {
+
+					err := shardedMap.ParallelIter(context.Background(), workers, func(shardIndex int, key string, value int) error {
+
+						if shardIndex%5 == 3 {
+
+							return fmt.Errorf("shard %v failed", shardIndex)
+
+						}
+
+						return nil
+
+					})
+
+					assertions.EqualError(err, "shard 3 failed")
+
+				}
+
+			})
+
+			t.Run("Cancelled", func(t *testing.T) {
+
+				ctx, cancel := context.WithCancel(context.Background())
+
+				var count atomic.Int64
+
+				err := shardedMap.ParallelIter(ctx, 2, func(shardIndex int, key string, value int) error {
+
+					if count.Add(1) == 100 {
+
+						cancel()
+
+					}
+
+					return nil
+
+				})
+
+				assertions.ErrorIs(err, context.Canceled)
+
+				assertions.Less(count.Load(), int64(10000))
+
+			})
+
+		})
+
+	}
+}
+
+func TestParallelIterResize(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		for _, numShards := range []int{64, 4} {
+
+			t.Run(fmt.Sprintf("%v/%v", name, numShards), func(t *testing.T) {
+
+				assertions := assert.New(t)
+
+				shardedMap := newMap(32)
+
+				for i := 0; i < 10000; i++ {
+
+					shardedMap.Set(fmt.Sprintf("test%v", i), i)
+
+				}
+
+				var seen sync.Map
+
+				var once sync.Once
+
+				resized := make(chan error, 1)
+
+				// The Resize is started from the first callback, so it can only
+				// swap the table in the middle of the walk if nothing holds it off.
+				err := shardedMap.ParallelIter(context.Background(), 1, func(shardIndex int, key string, value int) error {
+
+					once.Do(func() {
+
+						go func() { resized <- shardedMap.Resize(numShards) }()
+
+						time.Sleep(10 * time.Millisecond)
+
+					})
+
+					seen.Store(key, value)
+
+					return nil
+
+				})
+
+				assertions.NoError(err)
+
+				assertions.NoError(<-resized)
+
+				shardedMap.WaitResize()
+
+				count := 0
+
+				seen.Range(func(key, value any) bool {
+
+					count++
+
+					return true
+
+				})
+
+				assertions.Equal(10000, count)
+
+				var again sync.Once
+
+				total, _, err := MapReduce(context.Background(), shardedMap, 1, func(shardIndex int, key string, value int) int {
+
+					again.Do(func() {
+
+						go func() { resized <- shardedMap.Resize(32) }()
+
+						time.Sleep(10 * time.Millisecond)
+
+					})
+
+					return value
+
+				}, func(a, b int) int { return a + b })
+
+				assertions.NoError(err)
+
+				assertions.Equal(49995000, total)
+
+				assertions.NoError(<-resized)
+
+			})
+
+		}
+
+	}
+}
+
+func TestMapReduce(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(16)
+
+			sum := func(a, b int) int { return a + b }
+
+			_, ok, err := MapReduce(context.Background(), shardedMap, 4, func(shardIndex int, key string, value int) int {
+
+				return value
+
+			}, sum)
+
+			assertions.NoError(err)
+
+			assertions.False(ok)
+
+			for i := 0; i < 1000; i++ {
+
+				shardedMap.Set(fmt.Sprintf("test%v", i), i)
+
+			}
+
+			total, ok, err := MapReduce(context.Background(), shardedMap, 4, func(shardIndex int, key string, value int) int {
+
+				return value
+
+			}, sum)
+
+			assertions.NoError(err)
+
+			assertions.True(ok)
+
+			assertions.Equal(499500, total)
+
+			longest, _, err := MapReduce(context.Background(), shardedMap, 0, func(shardIndex int, key string, value int) string {
+
+				return key
+
+			}, func(a, b string) string {
+
+				if len(b) > len(a) || (len(b) == len(a) && b > a) {
+
+					return b
+
+				}
+
+				return a
+
+			})
+
+			assertions.NoError(err)
+
+			assertions.Equal("test999", longest)
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			cancel()
+
+			_, ok, err = MapReduce(ctx, shardedMap, 4, func(shardIndex int, key string, value int) int {
+
+				return value
+
+			}, sum)
+
+			assertions.True(errors.Is(err, context.Canceled))
+
+			assertions.False(ok)
+
+		})
+
+	}
+}
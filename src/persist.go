@@ -0,0 +1,711 @@
+package src
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// A snapshot file starts with a header
+//
+//	magic "SHMP" | version u8 | hasher id u8 | shard count uvarint | crc32c u32
+//
+// followed by one block per shard, in shard order
+//
+//	shard index uvarint | entry count uvarint | payload length uvarint | payload | crc32c u32
+//
+// where the payload holds the entries of the shard, each one the key and the
+// value in their codec's encoding followed by the expiry deadline in unix
+// nanoseconds as a uvarint, 0 for none. Checksums are CRC-32C, little endian,
+// over everything in the header or block that precedes them.
+const (
+	snapshotMagic = "SHMP"
+
+	snapshotVersion = 1
+
+	maxSnapshotBlock = 1 << 34
+
+	snapshotReadChunk = 1 << 20
+)
+
+var (
+	ErrorNoCodec = errors.New("map has no key or value codec, see WithCodecs")
+
+	ErrorNotSnapshot = errors.New("not a shardmap snapshot")
+
+	ErrorSnapshotVersion = errors.New("unsupported snapshot version")
+
+	ErrorSnapshotChecksum = errors.New("snapshot checksum mismatch")
+
+	ErrorCorruptSnapshot = errors.New("corrupt snapshot")
+
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type snapshotHeader struct {
+	Version uint8
+
+	HasherID HasherID
+
+	NumShards int
+}
+
+type snapshotBlock struct {
+	ShardIndex int
+
+	Entries int
+
+	Payload []byte
+}
+
+// SnapshotInfo describes a snapshot written by SaveTo.
+type SnapshotInfo struct {
+	HasherID HasherID
+
+	NumShards int
+
+	// Entries and Bytes hold the number of entries and their encoded size for
+	// every shard.
+	Entries []int
+
+	Bytes []int
+}
+
+type snapshotReader struct {
+	reader *bufio.Reader
+
+	header snapshotHeader
+
+	blocks int
+}
+
+// SaveTo writes a consistent snapshot of the map to w. Shards are encoded in
+// parallel but written in order. A Resize in progress is waited for first.
+func (shardMap *ShardMap[K, V]) SaveTo(w io.Writer) error {
+
+	shardMap.WaitResize()
+
+	snapshot := shardMap.Snapshot()
+
+	defer snapshot.Release()
+
+	return snapshot.SaveTo(w)
+}
+
+// LoadFrom adds every entry of a snapshot written by SaveTo to the map,
+// overwriting keys that are already present. The snapshot may come from a map
+// with any number of shards and any backend. Blocks are checked and inserted in
+// parallel; entries of blocks before the first corrupt one are all inserted.
+func (shardMap *ShardMap[K, V]) LoadFrom(r io.Reader) error {
+
+	keyCodec, valueCodec := shardMap.options.keyCodec, shardMap.options.valueCodec
+
+	if keyCodec == nil || valueCodec == nil {
+
+		return ErrorNoCodec
+
+	}
+
+	reader, err := newSnapshotReader(r)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	// errs grows with the blocks read rather than with the shard count in the
+	// header, which nothing has backed up yet.
+	var errs []error
+
+	var mu sync.Mutex
+
+	var wg sync.WaitGroup
+
+	blocks := make(chan snapshotBlock)
+
+	for worker := 0; worker < runtime.GOMAXPROCS(0); worker++ {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			for block := range blocks {
+
+				if err := shardMap.loadBlock(block, keyCodec, valueCodec); err != nil {
+
+					mu.Lock()
+
+					errs[block.ShardIndex] = err
+
+					mu.Unlock()
+
+				}
+
+			}
+
+		}()
+
+	}
+
+	for {
+
+		block, err := reader.next()
+
+		if err == io.EOF {
+
+			break
+		}
+
+		mu.Lock()
+
+		errs = append(errs, err)
+
+		mu.Unlock()
+
+		if err != nil {
+
+			break
+		}
+
+		blocks <- block
+
+	}
+
+	close(blocks)
+
+	wg.Wait()
+
+	return firstError(errs...)
+}
+
+// InspectSnapshot reads a snapshot written by SaveTo without loading it,
+// verifying every checksum. If fn is not nil it is called with every entry,
+// decoded with the codecs, and its encoded size.
+func InspectSnapshot[K comparable, V any](r io.Reader, keyCodec Codec[K], valueCodec Codec[V], fn func(shardIndex int, key K, value V, size int)) (SnapshotInfo, error) {
+
+	reader, err := newSnapshotReader(r)
+
+	if err != nil {
+
+		return SnapshotInfo{}, err
+
+	}
+
+	info := SnapshotInfo{
+
+		HasherID: reader.header.HasherID,
+
+		NumShards: reader.header.NumShards,
+	}
+
+	for {
+
+		block, err := reader.next()
+
+		if err == io.EOF {
+
+			return info, nil
+		}
+
+		if err != nil {
+
+			return info, err
+
+		}
+
+		// Blocks come in shard order, so the counts grow with the blocks read.
+		info.Entries = append(info.Entries, block.Entries)
+
+		info.Bytes = append(info.Bytes, len(block.Payload))
+
+		if fn == nil {
+
+			continue
+		}
+
+		err = decodeBlock(block, keyCodec, valueCodec, func(key K, value V, deadline int64, size int) {
+
+			fn(block.ShardIndex, key, value, size)
+
+		})
+
+		if err != nil {
+
+			return info, err
+
+		}
+
+	}
+}
+
+// SaveFile writes the map to path through a temporary file that is synced and
+// renamed into place, so path always holds a complete snapshot.
+func (shardMap *ShardMap[K, V]) SaveFile(path string) error {
+
+	return writeFileAtomic(path, shardMap.SaveTo)
+
+}
+
+func (shardMap *ShardMap[K, V]) LoadFile(path string) error {
+
+	file, err := os.Open(path)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	defer file.Close()
+
+	return shardMap.LoadFrom(bufio.NewReader(file))
+}
+
+// SaveTo writes the snapshot to w in the format LoadFrom reads.
+func (snapshot *Snapshot[K, V]) SaveTo(w io.Writer) error {
+
+	defer runtime.KeepAlive(snapshot)
+
+	if snapshot.keyCodec == nil || snapshot.valueCodec == nil {
+
+		return ErrorNoCodec
+
+	}
+
+	header := []byte(snapshotMagic)
+
+	header = append(header, snapshotVersion, byte(snapshot.hasherID))
+
+	header = binary.AppendUvarint(header, uint64(len(snapshot.shards)))
+
+	header = binary.LittleEndian.AppendUint32(header, crc32.Checksum(header, castagnoli))
+
+	if _, err := w.Write(header); err != nil {
+
+		return err
+
+	}
+
+	// Every shard gets its own channel so blocks can be encoded in any order
+	// and still be written in shard order. A worker slot is only freed once
+	// its block is written, which bounds the blocks held in memory.
+	workers := runtime.GOMAXPROCS(0)
+
+	slots := make(chan struct{}, workers)
+
+	encoded := make([]chan []byte, len(snapshot.shards))
+
+	for shardIndex := range encoded {
+
+		encoded[shardIndex] = make(chan []byte, 1)
+
+	}
+
+	done := make(chan struct{})
+
+	// The encoders read the backends of the snapshot, so SaveTo must not
+	// return before they are done even if w fails, since the caller may
+	// release the snapshot right after.
+	var wg sync.WaitGroup
+
+	defer func() {
+
+		close(done)
+
+		wg.Wait()
+
+	}()
+
+	wg.Add(1)
+
+	go func() {
+
+		defer wg.Done()
+
+		for shardIndex := range encoded {
+
+			select {
+
+			case slots <- struct{}{}:
+
+			case <-done:
+
+				return
+
+			}
+
+			wg.Add(1)
+
+			go func(shardIndex int) {
+
+				defer wg.Done()
+
+				encoded[shardIndex] <- snapshot.encodeBlock(shardIndex)
+
+			}(shardIndex)
+
+		}
+
+	}()
+
+	for shardIndex := range encoded {
+
+		block := <-encoded[shardIndex]
+
+		<-slots
+
+		if _, err := w.Write(block); err != nil {
+
+			return err
+
+		}
+
+	}
+
+	return nil
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func (snapshot *Snapshot[K, V]) encodeBlock(shardIndex int) []byte {
+
+	var payload []byte
+
+	entries := 0
+
+	snapshot.IterShard(func(key K, value V) bool {
+
+		payload = snapshot.keyCodec.Append(payload, key)
+
+		payload = snapshot.valueCodec.Append(payload, value)
+
+		payload = binary.AppendUvarint(payload, uint64(snapshot.deadline(key)))
+
+		entries++
+
+		return false
+
+	}, shardIndex)
+
+	block := binary.AppendUvarint(nil, uint64(shardIndex))
+
+	block = binary.AppendUvarint(block, uint64(entries))
+
+	block = binary.AppendUvarint(block, uint64(len(payload)))
+
+	block = append(block, payload...)
+
+	return binary.LittleEndian.AppendUint32(block, crc32.Checksum(block, castagnoli))
+}
+
+// deadline returns the expiry deadline of a live key of the snapshot, 0 if it
+// has none.
+func (snapshot *Snapshot[K, V]) deadline(key K) int64 {
+
+	hash := snapshot.hasher(key)
+
+	if deadline, found := snapshot.shards[snapshot.router.Route(hash, len(snapshot.shards))].expiries[key]; found {
+
+		return deadline
+
+	}
+
+	if snapshot.previous != nil {
+
+		return snapshot.previous[snapshot.router.Route(hash, len(snapshot.previous))].expiries[key]
+
+	}
+
+	return 0
+}
+
+func (shardMap *ShardMap[K, V]) loadBlock(block snapshotBlock, keyCodec Codec[K], valueCodec Codec[V]) error {
+
+	now := nowNano()
+
+	return decodeBlock(block, keyCodec, valueCodec, func(key K, value V, deadline int64, size int) {
+
+		if deadline == 0 {
+
+			shardMap.Set(key, value)
+
+		} else if deadline > now {
+
+			shardMap.setWithDeadline(key, value, deadline)
+
+		}
+
+	})
+}
+
+// decodeBlock calls fn with every entry of block and the number of bytes it
+// takes up.
+func decodeBlock[K comparable, V any](block snapshotBlock, keyCodec Codec[K], valueCodec Codec[V], fn func(key K, value V, deadline int64, size int)) error {
+
+	payload := block.Payload
+
+	for entry := 0; entry < block.Entries; entry++ {
+
+		size := len(payload)
+
+		key, n, err := keyCodec.Decode(payload)
+
+		if err != nil {
+
+			return fmt.Errorf("shard %v entry %v key: %w", block.ShardIndex, entry, err)
+
+		}
+
+		payload = payload[n:]
+
+		value, n, err := valueCodec.Decode(payload)
+
+		if err != nil {
+
+			return fmt.Errorf("shard %v entry %v value: %w", block.ShardIndex, entry, err)
+
+		}
+
+		payload = payload[n:]
+
+		deadline, n := binary.Uvarint(payload)
+
+		if n <= 0 {
+
+			return fmt.Errorf("shard %v entry %v deadline: %w", block.ShardIndex, entry, ErrorCorruptSnapshot)
+
+		}
+
+		payload = payload[n:]
+
+		fn(key, value, int64(deadline), size-len(payload))
+
+	}
+
+	if len(payload) != 0 {
+
+		return fmt.Errorf("shard %v: %w", block.ShardIndex, ErrorCorruptSnapshot)
+
+	}
+
+	return nil
+}
+
+func (shardMap *ShardMap[K, V]) setWithDeadline(key K, value V, deadline int64) {
+
+	shard := shardMap.lockShard(key)
+
+	shard.putUntil(key, value, deadline)
+
+	shard.Unlock()
+
+}
+
+func newSnapshotReader(r io.Reader) (*snapshotReader, error) {
+
+	reader := &snapshotReader{reader: bufio.NewReader(r)}
+
+	header := make([]byte, len(snapshotMagic)+2)
+
+	if _, err := io.ReadFull(reader.reader, header); err != nil {
+
+		return nil, ErrorNotSnapshot
+
+	}
+
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+
+		return nil, ErrorNotSnapshot
+
+	}
+
+	reader.header.Version, reader.header.HasherID = header[len(snapshotMagic)], HasherID(header[len(snapshotMagic)+1])
+
+	if reader.header.Version != snapshotVersion {
+
+		return nil, ErrorSnapshotVersion
+
+	}
+
+	numShards, err := binary.ReadUvarint(reader.reader)
+
+	if err != nil || numShards > 1<<32 {
+
+		return nil, ErrorCorruptSnapshot
+
+	}
+
+	reader.header.NumShards = int(numShards)
+
+	header = binary.AppendUvarint(header, numShards)
+
+	if err = reader.checksum(header); err != nil {
+
+		return nil, err
+
+	}
+
+	return reader, nil
+}
+
+// next returns the next block with its checksum verified, or io.EOF after the
+// last one.
+func (reader *snapshotReader) next() (block snapshotBlock, err error) {
+
+	if reader.blocks == reader.header.NumShards {
+
+		return block, io.EOF
+
+	}
+
+	var fields [3]uint64
+
+	var prefix []byte
+
+	for i := range fields {
+
+		if fields[i], err = binary.ReadUvarint(reader.reader); err != nil {
+
+			return block, ErrorCorruptSnapshot
+
+		}
+
+		prefix = binary.AppendUvarint(prefix, fields[i])
+
+	}
+
+	if fields[0] != uint64(reader.blocks) || fields[2] > maxSnapshotBlock || fields[1] > fields[2] {
+
+		return block, ErrorCorruptSnapshot
+
+	}
+
+	block.ShardIndex, block.Entries = int(fields[0]), int(fields[1])
+
+	// The payload grows as it is read, so that a corrupt length fails on the
+	// end of the stream before its memory is committed.
+	payload := bytes.NewBuffer(make([]byte, 0, min(fields[2], snapshotReadChunk)))
+
+	if _, err = io.CopyN(payload, reader.reader, int64(fields[2])); err != nil {
+
+		return block, ErrorCorruptSnapshot
+
+	}
+
+	block.Payload = payload.Bytes()
+
+	if err = reader.checksum(prefix, block.Payload); err != nil {
+
+		return block, err
+
+	}
+
+	reader.blocks++
+
+	return block, nil
+}
+
+// checksum reads the CRC-32C that follows data and compares the two.
+func (reader *snapshotReader) checksum(data ...[]byte) error {
+
+	var stored [4]byte
+
+	if _, err := io.ReadFull(reader.reader, stored[:]); err != nil {
+
+		return ErrorCorruptSnapshot
+
+	}
+
+	checksum := uint32(0)
+
+	for _, part := range data {
+
+		checksum = crc32.Update(checksum, castagnoli, part)
+
+	}
+
+	if binary.LittleEndian.Uint32(stored[:]) != checksum {
+
+		return ErrorSnapshotChecksum
+
+	}
+
+	return nil
+}
+
+func writeFileAtomic(path string, write func(w io.Writer) error) (err error) {
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+
+	if err != nil {
+
+		return err
+
+	}
+
+	defer func() {
+
+		if err != nil {
+
+			file.Close()
+
+			os.Remove(file.Name())
+
+		}
+
+	}()
+
+	buffered := bufio.NewWriter(file)
+
+	if err = write(buffered); err != nil {
+
+		return err
+
+	}
+
+	if err = buffered.Flush(); err != nil {
+
+		return err
+
+	}
+
+	if err = file.Sync(); err != nil {
+
+		return err
+
+	}
+
+	if err = file.Close(); err != nil {
+
+		return err
+
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+func firstError(errs ...error) error {
+
+	for _, err := range errs {
+
+		if err != nil {
+
+			return err
+
+		}
+
+	}
+
+	return nil
+}
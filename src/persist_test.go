@@ -0,0 +1,376 @@
+package src
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"hash/crc32"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveToLoadFrom(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		for loadName, newLoadMap := range shardedMaps {
+
+			t.Run(name+"To"+loadName, func(t *testing.T) {
+
+				assertions := assert.New(t)
+
+				shardedMap := newMap(8)
+
+				for i := 0; i < 1000; i++ {
+
+					shardedMap.Set(fmt.Sprintf("test%v", i), i-500)
+
+				}
+
+				var buffer bytes.Buffer
+
+				assertions.NoError(shardedMap.SaveTo(&buffer))
+
+				loaded := newLoadMap(3)
+
+				loaded.Set("existing", 1)
+
+				assertions.NoError(loaded.LoadFrom(&buffer))
+
+				assertions.Equal(1001, loaded.Len())
+
+				for i := 0; i < 1000; i++ {
+
+					value, ok := loaded.Get(fmt.Sprintf("test%v", i))
+
+					assertions.True(ok)
+
+					assertions.Equal(i-500, value)
+
+				}
+
+			})
+
+		}
+
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		var buffer bytes.Buffer
+
+		assertions.NoError(NewShardMap(4).SaveTo(&buffer))
+
+		loaded := NewShardMap(4)
+
+		assertions.NoError(loaded.LoadFrom(&buffer))
+
+		assertions.Equal(0, loaded.Len())
+
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		advance := fakeClock(t)
+
+		shardMap := NewShardMap(4)
+
+		shardMap.SetWithTTL("short", 1, time.Second)
+
+		shardMap.SetWithTTL("long", 2, time.Minute)
+
+		shardMap.Set("forever", 3)
+
+		var buffer bytes.Buffer
+
+		assertions.NoError(shardMap.SaveTo(&buffer))
+
+		advance(2 * time.Second)
+
+		loaded := NewShardMap(2)
+
+		assertions.NoError(loaded.LoadFrom(&buffer))
+
+		assertions.False(loaded.Contains("short"))
+
+		ttl, ok := loaded.TTL("long")
+
+		assertions.True(ok)
+
+		assertions.Equal(58*time.Second, ttl)
+
+		ttl, ok = loaded.TTL("forever")
+
+		assertions.True(ok)
+
+		assertions.Equal(time.Duration(NoExpiration), ttl)
+
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(4)
+
+		shardMap.Set("before", 1)
+
+		snapshot := shardMap.Snapshot()
+
+		shardMap.Set("after", 2)
+
+		var buffer bytes.Buffer
+
+		assertions.NoError(snapshot.SaveTo(&buffer))
+
+		loaded := NewShardMap(4)
+
+		assertions.NoError(loaded.LoadFrom(&buffer))
+
+		assertions.True(loaded.Contains("before"))
+
+		assertions.False(loaded.Contains("after"))
+
+	})
+
+	t.Run("FailingWriter", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(64)
+
+		for i := 0; i < 10000; i++ {
+
+			shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+		}
+
+		assertions.ErrorIs(shardMap.SaveTo(&failingWriter{writes: 2}), errWrite)
+
+		// The snapshot is released by now, so these write the shards in place,
+		// which must not race with an encoder still reading them.
+		for i := 0; i < 10000; i++ {
+
+			shardMap.Set(fmt.Sprintf("test%v", i), -i)
+
+		}
+
+	})
+
+	t.Run("NoCodec", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMapOf[int, int](4)
+
+		assertions.ErrorIs(shardMap.SaveTo(&bytes.Buffer{}), ErrorNoCodec)
+
+		assertions.ErrorIs(shardMap.LoadFrom(&bytes.Buffer{}), ErrorNoCodec)
+
+	})
+
+}
+
+func TestLoadFromCorrupt(t *testing.T) {
+
+	shardMap := NewShardMap(4)
+
+	for i := 0; i < 100; i++ {
+
+		shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+	}
+
+	var buffer bytes.Buffer
+
+	shardMap.SaveTo(&buffer)
+
+	valid := buffer.Bytes()
+
+	corrupt := func(index int, value byte) []byte {
+
+		data := bytes.Clone(valid)
+
+		data[index] = value
+
+		return data
+
+	}
+
+	tests := map[string]struct {
+		data []byte
+
+		err error
+	}{
+		"Magic": {corrupt(0, 'X'), ErrorNotSnapshot},
+
+		"Version": {corrupt(4, snapshotVersion+1), ErrorSnapshotVersion},
+
+		"Header": {corrupt(5, 0xff), ErrorSnapshotChecksum},
+
+		"Payload": {corrupt(len(valid)-10, valid[len(valid)-10]^0xff), ErrorSnapshotChecksum},
+
+		"Truncated": {valid[:len(valid)-1], ErrorCorruptSnapshot},
+
+		// A block claiming 16GiB must fail on the short stream rather than
+		// allocate its length up front.
+		"BlockLength": {append(binary.AppendUvarint(append(bytes.Clone(valid[:11]), 0, 1), maxSnapshotBlock), "short"...), ErrorCorruptSnapshot},
+
+		"Empty": {nil, ErrorNotSnapshot},
+
+		// A header claiming 2^32 shards must not size anything by it.
+		"ShardCount": {shardCountHeader(valid[5], 1<<32), ErrorCorruptSnapshot},
+	}
+
+	for name, test := range tests {
+
+		t.Run(name, func(t *testing.T) {
+
+			err := NewShardMap(4).LoadFrom(bytes.NewReader(test.data))
+
+			assert.True(t, errors.Is(err, test.err), "got %v", err)
+
+			_, err = InspectSnapshot[string, int](bytes.NewReader(test.data), StringCodec{}, VarintCodec[int]{}, nil)
+
+			assert.True(t, errors.Is(err, test.err), "got %v", err)
+
+		})
+
+	}
+}
+
+func TestSaveFileLoadFile(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "shardmap.snapshot")
+
+	shardMap := NewShardSwissMap(8)
+
+	for i := 0; i < 100; i++ {
+
+		shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+	}
+
+	assertions.NoError(shardMap.SaveFile(path))
+
+	shardMap.Set("test0", 1000)
+
+	assertions.NoError(shardMap.SaveFile(path))
+
+	loaded := NewShardMap(16)
+
+	assertions.NoError(loaded.LoadFile(path))
+
+	assertions.Equal(100, loaded.Len())
+
+	value, _ := loaded.Get("test0")
+
+	assertions.Equal(1000, value)
+
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp*"))
+
+	assertions.Empty(matches)
+
+	assertions.Error(loaded.LoadFile(filepath.Join(t.TempDir(), "missing")))
+}
+
+func TestInspectSnapshot(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardMap := NewShardMap(4)
+
+	for i := 0; i < 100; i++ {
+
+		shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+	}
+
+	var buffer bytes.Buffer
+
+	assertions.NoError(shardMap.SaveTo(&buffer))
+
+	data := buffer.Bytes()
+
+	info, err := InspectSnapshot[string, int](bytes.NewReader(data), nil, nil, nil)
+
+	assertions.NoError(err)
+
+	assertions.Equal(HasherCity, info.HasherID)
+
+	assertions.Equal(4, info.NumShards)
+
+	entries, size := 0, 0
+
+	for shardIndex := range info.Entries {
+
+		entries += info.Entries[shardIndex]
+
+		size += info.Bytes[shardIndex]
+
+	}
+
+	assertions.Equal(100, entries)
+
+	decoded := map[string]int{}
+
+	decodedSize := 0
+
+	_, err = InspectSnapshot(bytes.NewReader(data), StringCodec{}, VarintCodec[int]{}, func(shardIndex int, key string, value int, size int) {
+
+		assertions.Equal(uint32(shardIndex), shardMap.GetShardIndex(key))
+
+		decoded[key] = value
+
+		decodedSize += size
+
+	})
+
+	assertions.NoError(err)
+
+	assertions.Len(decoded, 100)
+
+	assertions.Equal(size, decodedSize)
+
+	_, err = InspectSnapshot[string, int](bytes.NewReader(data[:len(data)-1]), nil, nil, nil)
+
+	assertions.ErrorIs(err, ErrorCorruptSnapshot)
+}
+
+var errWrite = errors.New("write failed")
+
+// failingWriter fails every write after the first writes.
+type failingWriter struct {
+	writes int
+}
+
+func (writer *failingWriter) Write(data []byte) (int, error) {
+
+	if writer.writes == 0 {
+
+		return 0, errWrite
+
+	}
+
+	writer.writes--
+
+	return len(data), nil
+}
+
+// shardCountHeader returns a snapshot header with a valid checksum claiming
+// numShards shards.
+func shardCountHeader(hasherID byte, numShards uint64) []byte {
+
+	header := binary.AppendUvarint(append([]byte(snapshotMagic), snapshotVersion, hasherID), numShards)
+
+	return binary.LittleEndian.AppendUint32(header, crc32.Checksum(header, castagnoli))
+}
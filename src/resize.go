@@ -0,0 +1,221 @@
+package src
+
+import (
+	"errors"
+)
+
+const migrationBatch = 1024
+
+var (
+	ErrorResizeInProgress = errors.New("a resize is already in progress")
+
+	ErrorInvalidShardCount = errors.New("number of shards must be positive")
+)
+
+type migration struct {
+	done chan struct{}
+}
+
+// Resize changes the number of shards under live traffic. It swaps in a new,
+// empty table right away and returns, while a background goroutine moves the
+// keys over one batch of one old shard at a time. Until that is done, any key
+// that is accessed is migrated on the spot, so lookups consult both tables and
+// never see a stale value. Only one Resize can run at a time.
+func (shardMap *ShardMap[K, V]) Resize(numShards int) error {
+
+	if numShards <= 0 {
+
+		return ErrorInvalidShardCount
+
+	}
+
+	shardMap.resizeMu.Lock()
+
+	defer shardMap.resizeMu.Unlock()
+
+	table := shardMap.table.Load()
+
+	if table.previous != nil {
+
+		return ErrorResizeInProgress
+
+	}
+
+	if numShards == len(table.shards) {
+
+		return nil
+
+	}
+
+	next := &shardTable[K, V]{
+
+		shards: newShards(shardMap.options, numShards),
+
+		previous: table.shards,
+	}
+
+	shardMap.migration = &migration{done: make(chan struct{})}
+
+	shardMap.table.Store(next)
+
+	go shardMap.migrate(next, shardMap.migration)
+
+	return nil
+}
+
+// Resizing reports whether a Resize is still migrating keys.
+func (shardMap *ShardMap[K, V]) Resizing() bool {
+
+	return shardMap.table.Load().previous != nil
+
+}
+
+// WaitResize blocks until the last Resize has migrated every key.
+func (shardMap *ShardMap[K, V]) WaitResize() {
+
+	shardMap.resizeMu.RLock()
+
+	migration := shardMap.migration
+
+	shardMap.resizeMu.RUnlock()
+
+	if migration != nil {
+
+		<-migration.done
+
+	}
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func (shardMap *ShardMap[K, V]) migrate(table *shardTable[K, V], migration *migration) {
+
+	for _, source := range table.previous {
+
+		for drained := false; !drained; {
+
+			drained = shardMap.migrateBatch(table, source)
+
+		}
+
+	}
+
+	shardMap.table.Store(&shardTable[K, V]{shards: table.shards})
+
+	close(migration.done)
+
+}
+
+// migrateBatch moves up to migrationBatch keys out of source, holding its lock
+// only for that long. It reports whether source is empty.
+func (shardMap *ShardMap[K, V]) migrateBatch(table *shardTable[K, V], source *shard[K, V]) (drained bool) {
+
+	source.Lock()
+
+	defer source.Unlock()
+
+	keys := make([]K, 0, migrationBatch)
+
+	source.items.Iter(func(key K, value V) bool {
+
+		keys = append(keys, key)
+
+		return len(keys) == migrationBatch
+
+	})
+
+	for _, key := range keys {
+
+		destination := table.shards[shardMap.route(shardMap.hasher(key), len(table.shards))]
+
+		destination.Lock()
+
+		source.moveTo(destination, key)
+
+		destination.Unlock()
+
+	}
+
+	if len(keys) < migrationBatch {
+
+		source.drained.Store(true)
+
+		return true
+	}
+
+	return false
+}
+
+// migrateKey moves key into destination if it still lives in the previous
+// table. Both it and migrateBatch lock the old shard before the new one.
+func (shardMap *ShardMap[K, V]) migrateKey(table *shardTable[K, V], hash uint64, key K, destination *shard[K, V]) {
+
+	source := table.previous[shardMap.route(hash, len(table.previous))]
+
+	if source.drained.Load() {
+
+		return
+	}
+
+	source.Lock()
+
+	if _, ok := source.items.Get(key); ok {
+
+		destination.Lock()
+
+		source.moveTo(destination, key)
+
+		destination.Unlock()
+
+	}
+
+	source.Unlock()
+
+}
+
+// moveTo hands key over to destination together with its TTL. A value still in
+// the old table is always newer than one in the new table, because keys are
+// migrated before they are written to the new table. Moving a key is neither
+// logged nor published unless the move drops it.
+func (shard *shard[K, V]) moveTo(destination *shard[K, V], key K) {
+
+	value, _ := shard.items.Get(key)
+
+	deadline, hasDeadline := shard.expiries[key]
+
+	shard.unlink(key)
+
+	if hasDeadline && deadline <= nowNano() {
+
+		shard.watchers.publish(Event[K, V]{Type: EventExpire, Key: key, OldValue: value, HasOld: true})
+
+		return
+	}
+
+	destination.own()
+
+	if destination.admit(key, value) != nil || !destination.store(key, value) {
+
+		destination.forget(key)
+
+		destination.refuse(key, value)
+
+		destination.log.remove(key)
+
+		destination.watchers.publish(Event[K, V]{Type: EventRemove, Key: key, OldValue: value, HasOld: true})
+
+		return
+	}
+
+	if hasDeadline {
+
+		if destination.expiries == nil {
+
+			destination.expiries = make(map[K]int64)
+
+		}
+
+		destination.expiries[key] = deadline
+
+	}
+}
@@ -0,0 +1,251 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResize(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(16)
+
+			for i := 0; i < 10000; i++ {
+
+				shardedMap.Set(fmt.Sprintf("test%v", i), i)
+
+			}
+
+			assertions.ErrorIs(shardedMap.Resize(0), ErrorInvalidShardCount)
+
+			assertions.NoError(shardedMap.Resize(1024))
+
+			assertions.Equal(1024, shardedMap.NumShards())
+
+			for i := 0; i < 10000; i += 7 {
+
+				value, ok := shardedMap.Get(fmt.Sprintf("test%v", i))
+
+				assertions.True(ok)
+
+				assertions.Equal(i, value)
+
+			}
+
+			shardedMap.WaitResize()
+
+			assertions.False(shardedMap.Resizing())
+
+			assertions.Equal(10000, shardedMap.Len())
+
+			for i := 0; i < 10000; i++ {
+
+				value, ok := shardedMap.Get(fmt.Sprintf("test%v", i))
+
+				assertions.True(ok)
+
+				assertions.Equal(i, value)
+
+			}
+
+			assertions.NoError(shardedMap.Resize(3))
+
+			shardedMap.WaitResize()
+
+			assertions.Equal(3, shardedMap.NumShards())
+
+			assertions.Equal(10000, shardedMap.Len())
+
+		})
+
+	}
+}
+
+func TestResizeMigrationState(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	advance := fakeClock(t)
+
+	shardMap := NewShardMap(4)
+
+	for i := 0; i < 100; i++ {
+
+		shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+	}
+
+	shardMap.SetWithTTL("expired", 1, time.Millisecond)
+
+	advance(time.Millisecond)
+
+	shardMap.SetWithTTL("expiring", 1, time.Second)
+
+	// Swap in the new table without starting the background migration, so
+	// that every key is still in the previous table.
+	table := &shardTable[string, int]{shards: newShards(shardMap.options, 8), previous: shardMap.currentShards()}
+
+	shardMap.table.Store(table)
+
+	assertions.True(shardMap.Resizing())
+
+	assertions.ErrorIs(shardMap.Resize(16), ErrorResizeInProgress)
+
+	assertions.Equal(8, shardMap.NumShards())
+
+	t.Run("IterShard", func(t *testing.T) {
+
+		visited := make(map[string]struct{})
+
+		for shardIndex := 0; shardIndex < 8; shardIndex++ {
+
+			shardMap.IterShard(func(key string, value int) bool {
+
+				assertions.Equal(uint32(shardIndex), shardMap.GetShardIndex(key))
+
+				visited[key] = struct{}{}
+
+				return false
+
+			}, shardIndex)
+
+		}
+
+		assertions.Len(visited, 101)
+
+	})
+
+	t.Run("MigrateOnAccess", func(t *testing.T) {
+
+		value, ok := shardMap.Get("test42")
+
+		assertions.True(ok)
+
+		assertions.Equal(42, value)
+
+		_, ok = table.shards[shardMap.GetShardIndex("test42")].items.Get("test42")
+
+		assertions.True(ok)
+
+		shardMap.Set("test43", 430)
+
+		shardMap.Remove("test44")
+
+		ttl, ok := shardMap.TTL("expiring")
+
+		assertions.True(ok)
+
+		assertions.Equal(time.Second, ttl)
+
+		assertions.False(shardMap.Contains("expired"))
+
+	})
+
+	shardMap.migrate(table, &migration{done: make(chan struct{})})
+
+	assertions.False(shardMap.Resizing())
+
+	for _, shard := range table.previous {
+
+		assertions.True(shard.drained.Load())
+
+		assertions.Zero(shard.items.Len())
+
+	}
+
+	assertions.Equal(100, shardMap.Len())
+
+	value, _ := shardMap.Get("test43")
+
+	assertions.Equal(430, value)
+
+	assertions.False(shardMap.Contains("test44"))
+
+	ttl, _ := shardMap.TTL("expiring")
+
+	assertions.Equal(time.Second, ttl)
+
+}
+
+func TestResizeConcurrent(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardMap := NewShardMap(16)
+
+	defer shardMap.Close()
+
+	const workers, keys = 8, 2000
+
+	for worker := 0; worker < workers; worker++ {
+
+		for i := 0; i < keys; i++ {
+
+			shardMap.Set(fmt.Sprintf("worker%v-%v", worker, i), 0)
+
+		}
+
+	}
+
+	var wg sync.WaitGroup
+
+	for worker := 0; worker < workers; worker++ {
+
+		wg.Add(1)
+
+		go func(worker int) {
+
+			defer wg.Done()
+
+			for round := 1; round <= 3; round++ {
+
+				for i := 0; i < keys; i++ {
+
+					key := fmt.Sprintf("worker%v-%v", worker, i)
+
+					Incr(shardMap, key)
+
+					value, ok := shardMap.Get(key)
+
+					assertions.True(ok)
+
+					assertions.Equal(round, value)
+
+				}
+
+			}
+
+		}(worker)
+
+	}
+
+	for _, numShards := range []int{1024, 7, 64} {
+
+		for errors.Is(shardMap.Resize(numShards), ErrorResizeInProgress) {
+
+			shardMap.WaitResize()
+
+		}
+
+	}
+
+	wg.Wait()
+
+	shardMap.WaitResize()
+
+	assertions.Equal(64, shardMap.NumShards())
+
+	assertions.Equal(workers*keys, shardMap.Len())
+
+	assertions.Equal(3*workers*keys, Sum(shardMap))
+
+}
@@ -0,0 +1,181 @@
+package src
+
+import (
+	"sort"
+	"sync"
+)
+
+// Router picks the shard of a key from its hash. Every Router spreads keys
+// evenly, they differ in how many keys change shards when the number of shards
+// does, which is what a Resize has to migrate.
+type Router interface {
+	Route(hash uint64, numShards int) uint32
+}
+
+// ModuloRouter is the default Router. It is the fastest, but changing the
+// number of shards moves most keys.
+type ModuloRouter struct{}
+
+// JumpRouter implements Jump Consistent Hash. Growing from n to n+1 shards
+// moves only 1/(n+1) of the keys and it needs no memory, but it can only move
+// keys onto the shards that are added or removed at the end.
+type JumpRouter struct{}
+
+// RingRouter places VirtualNodes points per shard on a consistent hash ring and
+// routes a key to the first point after its hash. A ring is built once for
+// every number of shards it sees.
+type RingRouter struct {
+	VirtualNodes int
+
+	rings sync.Map
+}
+
+// RendezvousRouter implements highest random weight hashing. It moves the
+// minimum number of keys for any change in shard count, but routing costs one
+// hash per shard.
+type RendezvousRouter struct{}
+
+type hashRing struct {
+	points []uint64
+
+	shards []uint32
+}
+
+const DefaultVirtualNodes = 160
+
+func NewRingRouter(virtualNodes int) *RingRouter {
+
+	if virtualNodes <= 0 {
+
+		virtualNodes = DefaultVirtualNodes
+
+	}
+
+	return &RingRouter{VirtualNodes: virtualNodes}
+}
+
+func (ModuloRouter) Route(hash uint64, numShards int) uint32 {
+
+	return fastModN(uint32(hash), uint32(numShards))
+
+}
+
+// arxiv.org/abs/1406.2294
+func (JumpRouter) Route(hash uint64, numShards int) uint32 {
+
+	var bucket, next int64 = -1, 0
+
+	for next < int64(numShards) {
+
+		bucket = next
+
+		hash = hash*2862933555777941757 + 1
+
+		next = int64(float64(bucket+1) * (float64(int64(1)<<31) / float64((hash>>33)+1)))
+
+	}
+
+	return uint32(bucket)
+}
+
+func (router *RingRouter) Route(hash uint64, numShards int) uint32 {
+
+	ring := router.ring(numShards)
+
+	point := mix64(hash)
+
+	index := sort.Search(len(ring.points), func(i int) bool { return ring.points[i] >= point })
+
+	if index == len(ring.points) {
+
+		index = 0
+
+	}
+
+	return ring.shards[index]
+}
+
+func (RendezvousRouter) Route(hash uint64, numShards int) (shard uint32) {
+
+	var best uint64
+
+	for candidate := 0; candidate < numShards; candidate++ {
+
+		if weight := mix64(hash ^ mix64(uint64(candidate)+1)); candidate == 0 || weight > best {
+
+			shard, best = uint32(candidate), weight
+
+		}
+
+	}
+
+	return shard
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func (router *RingRouter) ring(numShards int) *hashRing {
+
+	if ring, ok := router.rings.Load(numShards); ok {
+
+		return ring.(*hashRing)
+
+	}
+
+	virtualNodes := router.VirtualNodes
+
+	if virtualNodes <= 0 {
+
+		virtualNodes = DefaultVirtualNodes
+
+	}
+
+	ring := &hashRing{}
+
+	indexes := make([]int, 0, numShards*virtualNodes)
+
+	for shard := 0; shard < numShards; shard++ {
+
+		for node := 0; node < virtualNodes; node++ {
+
+			ring.points = append(ring.points, mix64(uint64(shard)<<32|uint64(node)))
+
+			ring.shards = append(ring.shards, uint32(shard))
+
+			indexes = append(indexes, len(indexes))
+
+		}
+
+	}
+
+	sort.Slice(indexes, func(i, j int) bool { return ring.points[indexes[i]] < ring.points[indexes[j]] })
+
+	sorted := &hashRing{points: make([]uint64, len(indexes)), shards: make([]uint32, len(indexes))}
+
+	for i, index := range indexes {
+
+		sorted.points[i], sorted.shards[i] = ring.points[index], ring.shards[index]
+
+	}
+
+	actual, _ := router.rings.LoadOrStore(numShards, sorted)
+
+	return actual.(*hashRing)
+}
+
+// mix64 is the splitmix64 finalizer, used to turn shard numbers and key hashes
+// into well spread ring positions and weights.
+func mix64(x uint64) uint64 {
+
+	x ^= x >> 30
+
+	x *= 0xbf58476d1ce4e5b9
+
+	x ^= x >> 27
+
+	x *= 0x94d049bb133111eb
+
+	x ^= x >> 31
+
+	return x
+}
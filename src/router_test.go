@@ -0,0 +1,150 @@
+package src
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var routers = map[string]Router{
+
+	"Modulo": ModuloRouter{},
+
+	"Jump": JumpRouter{},
+
+	"Ring": NewRingRouter(0),
+
+	"Rendezvous": RendezvousRouter{},
+}
+
+func TestRouterBalance(t *testing.T) {
+
+	const numShards, keys = 16, 64000
+
+	for name, router := range routers {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			counts := make([]int, numShards)
+
+			for i := 0; i < keys; i++ {
+
+				shard := router.Route(CityHasher(fmt.Sprintf("test%v", i)), numShards)
+
+				assertions.Less(shard, uint32(numShards))
+
+				counts[shard]++
+
+			}
+
+			for _, count := range counts {
+
+				assertions.InDelta(keys/numShards, count, keys/numShards/4)
+
+			}
+
+		})
+
+	}
+}
+
+func TestRouterMovedKeys(t *testing.T) {
+
+	const keys = 20000
+
+	resizes := []struct {
+		From, To int
+	}{
+		{16, 17},
+
+		{16, 32},
+
+		{32, 31},
+	}
+
+	// Ideal is the fraction of keys that have to move for the shards to stay
+	// balanced; consistent routers should stay close to it.
+	for _, resize := range resizes {
+
+		ideal := 1 - float64(min(resize.From, resize.To))/float64(max(resize.From, resize.To))
+
+		for name, router := range routers {
+
+			t.Run(fmt.Sprintf("%v/%v-%v", name, resize.From, resize.To), func(t *testing.T) {
+
+				assertions := assert.New(t)
+
+				moved := 0
+
+				for i := 0; i < keys; i++ {
+
+					hash := CityHasher(fmt.Sprintf("test%v", i))
+
+					if router.Route(hash, resize.From) != router.Route(hash, resize.To) {
+
+						moved++
+
+					}
+
+				}
+
+				fraction := float64(moved) / keys
+
+				t.Logf("moved %.3f of the keys, ideal %.3f", fraction, ideal)
+
+				if name == "Modulo" {
+
+					assertions.Greater(fraction, ideal+0.25)
+
+					return
+				}
+
+				assertions.InDelta(ideal, fraction, 0.05)
+
+			})
+
+		}
+
+	}
+}
+
+func TestWithRouter(t *testing.T) {
+
+	for name, router := range routers {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardMap := NewShardMapOf[string, int](8, WithHasher[string, int](CityHasher), WithRouter[string, int](router))
+
+			for i := 0; i < 1000; i++ {
+
+				key := fmt.Sprintf("test%v", i)
+
+				shardMap.Set(key, i)
+
+				assertions.Equal(router.Route(CityHasher(key), 8), shardMap.GetShardIndex(key))
+
+			}
+
+			assertions.NoError(shardMap.Resize(12))
+
+			shardMap.WaitResize()
+
+			for i := 0; i < 1000; i++ {
+
+				value, ok := shardMap.Get(fmt.Sprintf("test%v", i))
+
+				assertions.True(ok)
+
+				assertions.Equal(i, value)
+
+			}
+
+		})
+
+	}
+}
@@ -0,0 +1,60 @@
+package src
+
+import "iter"
+
+// All returns an iterator over every entry for use with range. Like Iter, it
+// holds a shard's read lock while the loop body runs, so the body must not
+// write to the map.
+func (shardMap *ShardMap[K, V]) All() iter.Seq2[K, V] {
+
+	return func(yield func(key K, value V) bool) {
+
+		shardMap.Iter(func(key K, value V) bool {
+
+			return !yield(key, value)
+
+		})
+
+	}
+}
+
+func (shardMap *ShardMap[K, V]) Keys() iter.Seq[K] {
+
+	return func(yield func(key K) bool) {
+
+		shardMap.Iter(func(key K, value V) bool {
+
+			return !yield(key)
+
+		})
+
+	}
+}
+
+func (shardMap *ShardMap[K, V]) Values() iter.Seq[V] {
+
+	return func(yield func(value V) bool) {
+
+		shardMap.Iter(func(key K, value V) bool {
+
+			return !yield(value)
+
+		})
+
+	}
+}
+
+// Shard returns an iterator over the entries of one shard. It yields nothing if
+// the shard does not exist; use IterShard to get an error instead.
+func (shardMap *ShardMap[K, V]) Shard(shardIndex int) iter.Seq2[K, V] {
+
+	return func(yield func(key K, value V) bool) {
+
+		shardMap.IterShard(func(key K, value V) bool {
+
+			return !yield(key, value)
+
+		}, shardIndex)
+
+	}
+}
@@ -0,0 +1,133 @@
+package src
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(8)
+
+			for i := 0; i < 100; i++ {
+
+				shardedMap.Set(fmt.Sprintf("test%v", i), i)
+
+			}
+
+			entries := make(map[string]int)
+
+			for key, value := range shardedMap.All() {
+
+				entries[key] = value
+
+			}
+
+			assertions.Len(entries, 100)
+
+			assertions.Equal(42, entries["test42"])
+
+			visited := 0
+
+			for range shardedMap.All() {
+
+				if visited++; visited == 5 {
+
+					break
+				}
+
+			}
+
+			assertions.Equal(5, visited)
+
+		})
+
+	}
+}
+
+func TestKeysAndValues(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardMap := NewShardMap(8)
+
+	for i := 0; i < 100; i++ {
+
+		shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+	}
+
+	keys := make(map[string]struct{})
+
+	for key := range shardMap.Keys() {
+
+		keys[key] = struct{}{}
+
+	}
+
+	assertions.Len(keys, 100)
+
+	sum := 0
+
+	for value := range shardMap.Values() {
+
+		sum += value
+
+	}
+
+	assertions.Equal(4950, sum)
+
+	for range shardMap.Keys() {
+
+		break
+	}
+
+	for range shardMap.Values() {
+
+		break
+	}
+
+}
+
+func TestShard(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardMap := NewShardMap(8)
+
+	for i := 0; i < 100; i++ {
+
+		shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+	}
+
+	total := 0
+
+	for shardIndex := 0; shardIndex < 8; shardIndex++ {
+
+		for key := range shardMap.Shard(shardIndex) {
+
+			assertions.Equal(uint32(shardIndex), shardMap.GetShardIndex(key))
+
+			total++
+
+		}
+
+	}
+
+	assertions.Equal(100, total)
+
+	for range shardMap.Shard(8) {
+
+		assertions.Fail("shard 8 does not exist")
+
+	}
+
+}
@@ -0,0 +1,127 @@
+package src
+
+import (
+	"context"
+	"io"
+	"iter"
+	"time"
+)
+
+// ShardedMap is implemented by every sharded map in this package regardless of
+// the Backend its shards are stored in.
+type ShardedMap[K comparable, V any] interface {
+	Set(key K, value V)
+
+	Get(key K) (value V, ok bool)
+
+	Remove(key K)
+
+	RemoveAll()
+
+	Iter(callback func(key K, value V) bool)
+
+	IterShard(callback func(key K, value V) bool, shardIndex int) error
+
+	All() iter.Seq2[K, V]
+
+	Keys() iter.Seq[K]
+
+	Values() iter.Seq[V]
+
+	Shard(shardIndex int) iter.Seq2[K, V]
+
+	Snapshot() *Snapshot[K, V]
+
+	SnapshotShard(shardIndex int) (*Snapshot[K, V], error)
+
+	TrySet(key K, value V) error
+
+	MemoryUsage() MemoryStats
+
+	GetOrLoad(ctx context.Context, key K, loader Loader[K, V], opts ...LoadOption) (V, error)
+
+	SaveTo(w io.Writer) error
+
+	LoadFrom(r io.Reader) error
+
+	SaveFile(path string) error
+
+	LoadFile(path string) error
+
+	Compact() error
+
+	Sync() error
+
+	Watch(key K, opts ...WatchOption) *Subscription[K, V]
+
+	WatchFunc(match func(key K) bool, opts ...WatchOption) *Subscription[K, V]
+
+	Subscribe(opts ...WatchOption) *Subscription[K, V]
+
+	ParallelIter(ctx context.Context, workers int, fn func(shardIndex int, key K, value V) error) error
+
+	Contains(key K) bool
+
+	SetMany(entries []KV[K, V])
+
+	SetManyMap(entries map[K]V)
+
+	GetMany(keys []K) (values []V, found []bool)
+
+	RemoveMany(keys []K) int
+
+	ContainsMany(keys []K) []bool
+
+	Len() int
+
+	Stats() Stats
+
+	AnalyzeSkew() SkewReport[K]
+
+	HotKeys(n int) []HotKey[K]
+
+	NumShards() int
+
+	GetShardIndex(key K) uint32
+
+	Compute(key K, fn func(old V, ok bool) (V, ComputeOp)) (value V, ok bool)
+
+	ComputeWithTTL(key K, ttl time.Duration, fn func(old V, ok bool) (V, ComputeOp)) (value V, ok bool)
+
+	Upsert(key K, fn func(old V, ok bool) V) V
+
+	GetOrSet(key K, value V) (actual V, loaded bool)
+
+	SetIfAbsent(key K, value V) bool
+
+	CompareAndSwap(key K, old, new V) bool
+
+	CompareAndDelete(key K, old V) bool
+
+	LoadAndDelete(key K) (value V, loaded bool)
+
+	SetWithTTL(key K, value V, ttl time.Duration)
+
+	Expire(key K, ttl time.Duration) bool
+
+	TTL(key K) (ttl time.Duration, ok bool)
+
+	Persist(key K) bool
+
+	RemoveExpired() int
+
+	Resize(numShards int) error
+
+	Resizing() bool
+
+	WaitResize()
+
+	Close() error
+}
+
+// KV is a single key value pair.
+type KV[K comparable, V any] struct {
+	Key K
+
+	Value V
+}
@@ -1,140 +1,761 @@
 package src
 
 import (
-	"errors"
 	"fmt"
-	"github.com/go-faster/city"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
 )
 
-type ShardMap struct {
-	shards []map[string]int
+type ShardMap[K comparable, V any] struct {
+	table atomic.Pointer[shardTable[K, V]]
+
+	hasher Hasher[K]
+
+	router Router
+
+	options *options[K, V]
+
+	// resizeMu is held for reading by operations that walk every shard and for
+	// writing while Resize swaps in a new table, so that a walk never starts on
+	// a table whose keys are migrating away underneath it.
+	resizeMu sync.RWMutex
+
+	migration *migration
+
+	janitor *janitor
+
+	closeOnce sync.Once
+}
+
+type shardTable[K comparable, V any] struct {
+	shards []*shard[K, V]
+
+	// previous holds the shards keys are migrated out of while a Resize is in
+	// progress. A key lives in exactly one of the two tables.
+	previous []*shard[K, V]
+}
+
+type shard[K comparable, V any] struct {
+	shardState[K, V]
+
+	_ [cacheLineSize - unsafe.Sizeof(shardState[int, int]{})%cacheLineSize]byte
+}
+
+// shardState is everything a shard owns. It is wrapped by shard, which pads it
+// out to a full cache line so that neighbouring shard locks never share one and
+// contend through false sharing.
+type shardState[K comparable, V any] struct {
+	sync.RWMutex
+
+	items Backend[K, V]
+
+	// expiries holds the deadline in unix nanoseconds of every key that has a
+	// TTL. It is only allocated once the shard sees its first TTL.
+	expiries map[K]int64
+
+	// policy is nil unless the map is bounded by WithMaxEntries, or evicts to
+	// honour WithMaxBytes.
+	policy evictionPolicy[K]
+
+	capacity int
+
+	onEvict func(key K, value V)
+
+	backend BackendFactory[K, V]
+
+	// log is nil unless the map was opened by OpenShardMap.
+	log *writeAheadLog[K, V]
+
+	watchers *watchHub[K, V]
+
+	// metrics is nil unless the map was created WithMetrics.
+	metrics *shardMetrics
+
+	// hot is nil unless the map was created WithHotKeys.
+	hot *hotKeys[K]
+
+	memory shardMemory[K, V]
+
+	// loads holds the GetOrLoad calls in flight and failures the errors cached
+	// by LoadNegativeTTL. Both are allocated on first use.
+	loads map[K]*loadCall[V]
+
+	failures map[K]loadFailure
+
+	// snapshots counts the live Snapshots that reference items and expiries.
+	// While it is not zero the next write copies them before changing anything.
+	snapshots atomic.Int32
+
+	// generation changes, under the write lock, whenever items is copied or
+	// replaced, so that releasing a Snapshot of an earlier generation leaves
+	// snapshots alone.
+	generation uint64
+
+	// drained is set once a Resize has moved every key out of the shard.
+	drained atomic.Bool
+}
+
+type ShardNotExistsError struct {
+	Shard int
 }
 
 const (
 	DefaultShardRecords = 1000
 
+	cacheLineSize = 64
+
 	ErrorShardNotExists = "shard %v does not exist"
 )
 
-func NewShardMap(numShards int) *ShardMap {
+var _ ShardedMap[string, int] = (*ShardMap[string, int])(nil)
+
+func NewShardMap(numShards int) *ShardMap[string, int] {
+
+	return NewShardMapOf[string, int](numShards, stringIntOptions()...)
+
+}
+
+func NewShardMapOf[K comparable, V any](numShards int, opts ...Option[K, V]) *ShardMap[K, V] {
+
+	options := newOptions(opts)
 
-	shards := make([]map[string]int, numShards)
+	options.watchers = newWatchHub[K, V]()
 
-	for shard := 0; shard < len(shards); shard++ {
+	shardMap := &ShardMap[K, V]{
 
-		shards[shard] = make(map[string]int, DefaultShardRecords)
+		hasher: options.hasher,
 
+		router: options.router,
+
+		options: options,
 	}
 
-	return &ShardMap{
+	shardMap.table.Store(&shardTable[K, V]{shards: newShards(options, numShards)})
+
+	if options.janitorInterval > 0 {
+
+		shardMap.janitor = startJanitor(options.janitorInterval, shardMap.RemoveExpired)
 
-		shards: shards,
 	}
+
+	return shardMap
 }
 
-func (shardMap *ShardMap) Set(key string, value int) {
+func (shardMap *ShardMap[K, V]) Set(key K, value V) {
+
+	shard := shardMap.lockShard(key)
 
-	shardMap.shards[shardMap.GetShardIndex(key)][key] = value
+	shard.put(key, value)
+
+	shard.Unlock()
 
 }
 
-func (shardMap *ShardMap) Get(key string) (value int, ok bool) {
+func (shardMap *ShardMap[K, V]) Get(key K) (value V, ok bool) {
+
+	shard := shardMap.rlockShard(key)
+
+	if value, ok = shard.get(key); ok {
 
-	value, ok = shardMap.shards[shardMap.GetShardIndex(key)][key]
+		shard.touch(key)
 
-	return
+	}
+
+	shard.metrics.lookup(ok)
+
+	shard.RUnlock()
+
+	return value, ok
 }
 
-func (shardMap *ShardMap) Remove(key string) {
+func (shardMap *ShardMap[K, V]) Remove(key K) {
+
+	shard := shardMap.lockShard(key)
 
-	delete(shardMap.shards[shardMap.GetShardIndex(key)], key)
+	shard.delete(key)
+
+	shard.Unlock()
 
 }
 
-func (shardMap *ShardMap) RemoveAll() {
+func (shardMap *ShardMap[K, V]) RemoveAll() {
+
+	shardMap.resizeMu.RLock()
+
+	defer shardMap.resizeMu.RUnlock()
+
+	shards := shardMap.allShards()
+
+	// A logged or watched map clears every shard at once, so that no write
+	// logged or published after the clear is lost by it on replay or appears
+	// to subscribers to have been cleared.
+	if shardMap.options.log != nil || shardMap.options.watchers.active() {
+
+		for _, shard := range shards {
+
+			shard.Lock()
+
+		}
+
+		shardMap.options.log.clear()
 
-	for _, shard := range shardMap.shards {
+		shardMap.options.watchers.publish(Event[K, V]{Type: EventClear})
 
-		clear(shard)
+		for _, shard := range shards {
+
+			shard.clear()
+
+			shard.Unlock()
+
+		}
+
+		return
+	}
+
+	for _, shard := range shards {
+
+		shard.Lock()
+
+		shard.clear()
+
+		shard.Unlock()
 
 	}
 }
 
-func (shardMap *ShardMap) Iter(callback func(key string, value int) bool) {
+// Iter visits every entry until callback returns true. It holds each shard's
+// read lock while its entries are visited, so the callback must not write to
+// the map. While a Resize is migrating keys an entry may be visited twice.
+func (shardMap *ShardMap[K, V]) Iter(callback func(key K, value V) bool) {
 
-	for _, shard := range shardMap.shards {
+	shardMap.resizeMu.RLock()
 
-		for key, value := range shard {
+	defer shardMap.resizeMu.RUnlock()
 
-			if callback(key, value) {
+	for _, shard := range shardMap.allShards() {
 
-				break
-			}
+		if shard.iter(callback) {
+
+			return
 		}
+
 	}
 }
 
-func (shardMap *ShardMap) Len() (size int) {
+// Len counts expired entries that have not been removed yet. While a Resize is
+// migrating keys it is only approximate.
+func (shardMap *ShardMap[K, V]) Len() (size int) {
+
+	shardMap.resizeMu.RLock()
+
+	defer shardMap.resizeMu.RUnlock()
+
+	for _, shard := range shardMap.allShards() {
 
-	for _, shard := range shardMap.shards {
+		shard.RLock()
 
-		size += len(shard)
+		size += shard.items.Len()
+
+		shard.RUnlock()
 	}
 
 	return size
 }
 
-func (shardMap *ShardMap) IterShard(callback func(key string, value int) bool, shardIndex int) (error error) {
+func (shardMap *ShardMap[K, V]) IterShard(callback func(key K, value V) bool, shardIndex int) error {
+
+	shardMap.resizeMu.RLock()
+
+	defer shardMap.resizeMu.RUnlock()
 
-	if shardIndex > len(shardMap.shards)-1 || shardIndex < -1 {
+	table := shardMap.table.Load()
 
-		return errors.New(fmt.Sprintf(ErrorShardNotExists, shardIndex))
+	if shardIndex > len(table.shards)-1 || shardIndex < -1 {
+
+		return &ShardNotExistsError{Shard: shardIndex}
 
 	}
 
 	if shardIndex == -1 {
 
-		shardMap.Iter(callback)
+		for _, shard := range table.all() {
+
+			if shard.iter(callback) {
 
-		return error
+				return nil
+			}
+
+		}
 
+		return nil
 	}
 
-	for key, value := range shardMap.shards[shardIndex] {
+	// Keys of the shard that have not been migrated yet are still spread over
+	// the previous table.
+	for _, shard := range table.previous {
 
-		if callback(key, value) {
+		if shard.drained.Load() {
 
-			break
+			continue
 		}
+
+		stopped := shard.iter(func(key K, value V) bool {
+
+			return shardMap.route(shardMap.hasher(key), len(table.shards)) == uint32(shardIndex) && callback(key, value)
+
+		})
+
+		if stopped {
+
+			return nil
+		}
+
 	}
 
+	table.shards[shardIndex].iter(callback)
+
 	return nil
 }
 
-func (shardMap *ShardMap) Contains(key string) bool {
+func (shardMap *ShardMap[K, V]) Contains(key K) (found bool) {
 
-	_, found := shardMap.shards[shardMap.GetShardIndex(key)][key]
+	shard := shardMap.rlockShard(key)
+
+	_, found = shard.get(key)
+
+	shard.metrics.lookup(found)
+
+	shard.RUnlock()
 
 	return found
 }
 
-func (shardMap *ShardMap) Shards() int {
+func (shardMap *ShardMap[K, V]) NumShards() int {
+
+	return len(shardMap.currentShards())
+
+}
+
+// Deprecated: use NumShards, which every ShardedMap implements.
+func (shardMap *ShardMap[K, V]) Shards() int {
+
+	return shardMap.NumShards()
+
+}
+
+func (shardMap *ShardMap[K, V]) GetShardIndex(key K) uint32 {
 
-	return len(shardMap.shards)
+	return shardMap.route(shardMap.hasher(key), len(shardMap.currentShards()))
+
+}
+
+// Close stops the background goroutines of the map and closes its write-ahead
+// log. The map itself stays usable, but writes are no longer logged.
+func (shardMap *ShardMap[K, V]) Close() (err error) {
+
+	shardMap.closeOnce.Do(func() {
+
+		if shardMap.janitor != nil {
+
+			shardMap.janitor.stop()
+
+		}
+
+		shardMap.WaitResize()
+
+		err = shardMap.options.log.close()
+
+	})
+
+	return err
+}
+
+func (err *ShardNotExistsError) Error() string {
+
+	return fmt.Sprintf(ErrorShardNotExists, err.Shard)
 
 }
 
 //-------------------------------------Helper Functions----------------------------------------------------------//
 
-// lemire.me/blog/2016/06/27/a-fast-alternative-to-the-modulo-reduction/
-func fastModN(x, n uint32) uint32 {
+func newShards[K comparable, V any](options *options[K, V], numShards int) []*shard[K, V] {
 
-	return uint32((uint64(x) * uint64(n)) >> 32)
+	shards := make([]*shard[K, V], numShards)
+
+	for shardIndex := range shards {
+
+		shards[shardIndex] = newShard(options, numShards)
 
+	}
+
+	return shards
+}
+
+func newShard[K comparable, V any](options *options[K, V], numShards int) *shard[K, V] {
+
+	shard := &shard[K, V]{}
+
+	shard.backend, shard.log, shard.watchers = options.backend, options.log, options.watchers
+
+	if options.metrics {
+
+		shard.metrics = &shardMetrics{created: time.Now()}
+
+	}
+
+	if options.hotKeys > 0 {
+
+		shard.hot = newHotKeys[K](options.hotKeys)
+
+	}
+
+	if options.maxEntries > 0 {
+
+		shard.capacity = max((options.maxEntries+numShards-1)/numShards, 1)
+
+		shard.policy = newEvictionPolicy(options.evictionPolicy, shard.capacity, options.hasher)
+
+	} else if options.maxBytes > 0 && options.maxBytesPolicy == MaxBytesEvict {
+
+		shard.capacity = math.MaxInt
+
+		shard.policy = newEvictionPolicy(options.evictionPolicy, DefaultShardRecords, options.hasher)
+
+	}
+
+	shard.onEvict = options.onEvict
+
+	shard.items = shard.newItems()
+
+	shard.memory = newShardMemory(options, numShards, shard.items)
+
+	return shard
 }
 
-func (shardMap *ShardMap) GetShardIndex(key string) uint32 {
+func (shard *shard[K, V]) newItems() Backend[K, V] {
+
+	if shard.capacity > 0 {
+
+		return shard.backend(min(shard.capacity, DefaultShardRecords))
+
+	}
+
+	return shard.backend(DefaultShardRecords)
+}
+
+// lockShard returns the shard that owns key with its write lock held. If a
+// Resize is in progress the key is migrated into the new table first, so
+// callers only ever deal with a single shard.
+func (shardMap *ShardMap[K, V]) lockShard(key K) *shard[K, V] {
+
+	return shardMap.acquireShard(key, (*shard[K, V]).Lock, (*shard[K, V]).Unlock)
+
+}
+
+// rlockShard is lockShard with the read lock held instead.
+func (shardMap *ShardMap[K, V]) rlockShard(key K) *shard[K, V] {
+
+	return shardMap.acquireShard(key, (*shard[K, V]).RLock, (*shard[K, V]).RUnlock)
+
+}
+
+func (shardMap *ShardMap[K, V]) acquireShard(key K, lock, unlock func(*shard[K, V])) *shard[K, V] {
+
+	hash := shardMap.hasher(key)
 
-	return fastModN(uint32(city.Hash64([]byte(key))), uint32(len(shardMap.shards)))
+	for {
+
+		table := shardMap.table.Load()
+
+		shard := table.shards[shardMap.route(hash, len(table.shards))]
+
+		if table.previous != nil {
+
+			shardMap.migrateKey(table, hash, key, shard)
+
+		}
+
+		shard.hot.record(key, hash)
+
+		shard.lock(lock)
+
+		// A Resize that swapped the table while we were getting here may
+		// already be moving keys out of this shard.
+		if shardMap.table.Load() == table {
+
+			return shard
+
+		}
+
+		unlock(shard)
+
+	}
+}
+
+func (shardMap *ShardMap[K, V]) route(hash uint64, numShards int) uint32 {
+
+	return shardMap.router.Route(hash, numShards)
+
+}
+
+func (shardMap *ShardMap[K, V]) currentShards() []*shard[K, V] {
+
+	return shardMap.table.Load().shards
+
+}
+
+// shardMapOf gives helpers that take a ShardedMap the ShardMap behind it, which
+// ShardSwissMap and ShardSortedMap inherit.
+func (shardMap *ShardMap[K, V]) shardMapOf() *ShardMap[K, V] {
+
+	return shardMap
+
+}
+
+// allShards returns the shards of the current table, preceded by those of the
+// previous one during a Resize. Keys only move from the previous table to the
+// current one, so walking them in this order never misses a key.
+func (shardMap *ShardMap[K, V]) allShards() []*shard[K, V] {
+
+	return shardMap.table.Load().all()
+
+}
+
+func (table *shardTable[K, V]) all() []*shard[K, V] {
+
+	if table.previous == nil {
+
+		return table.shards
+
+	}
+
+	return append(append(make([]*shard[K, V], 0, len(table.previous)+len(table.shards)), table.previous...), table.shards...)
+}
+
+// get, put, delete and clear are the only places that touch a shard's items
+// and must be called with the shard lock held, the write lock for anything but
+// get. Every write goes through own first.
+func (shard *shard[K, V]) get(key K) (value V, ok bool) {
+
+	if value, ok = shard.items.Get(key); ok && shard.expired(key, nowNano()) {
+
+		var zero V
+
+		return zero, false
+
+	}
+
+	return value, ok
+}
+
+// put stores value under key without a TTL. It only returns false if a bounded
+// shard refused to admit a new key or had no room for the entry.
+func (shard *shard[K, V]) put(key K, value V) (stored bool) {
+
+	return shard.putUntil(key, value, 0)
+
+}
+
+// putUntil is put for an entry that expires at deadline, 0 for never. The value
+// and its deadline go to the write-ahead log as a single record.
+func (shard *shard[K, V]) putUntil(key K, value V, deadline int64) (stored bool) {
+
+	if shard.set(key, value, deadline) != nil {
+
+		shard.refuse(key, value)
+
+		return false
+	}
+
+	return true
+}
+
+// set is putUntil, except that it returns why an entry was refused and leaves
+// reporting it to the caller.
+func (shard *shard[K, V]) set(key K, value V, deadline int64) error {
+
+	shard.own()
+
+	event := Event[K, V]{Type: EventSet, Key: key, Value: value}
+
+	if shard.watchers.active() {
+
+		event.OldValue, event.HasOld = shard.get(key)
+
+	}
+
+	if err := shard.admit(key, value); err != nil {
+
+		return err
+
+	}
+
+	if !shard.store(key, value) {
+
+		shard.forget(key)
+
+		return ErrorNotAdmitted
+	}
+
+	if deadline > 0 {
+
+		if shard.expiries == nil {
+
+			shard.expiries = make(map[K]int64)
+
+		}
+
+		shard.expiries[key] = deadline
+
+	} else {
+
+		delete(shard.expiries, key)
+
+	}
+
+	shard.invalidateLoad(key)
+
+	shard.log.set(key, value, deadline)
+
+	shard.metrics.set()
+
+	shard.watchers.publish(event)
+
+	return nil
+}
+
+// delete publishes an EventExpire rather than an EventRemove if key had already
+// expired.
+func (shard *shard[K, V]) delete(key K) (ok bool) {
+
+	event := Event[K, V]{Type: EventRemove, Key: key}
+
+	if shard.watchers.active() {
+
+		event.OldValue, event.HasOld = shard.items.Get(key)
+
+		if shard.expired(key, nowNano()) {
+
+			event.Type = EventExpire
+
+		}
+
+	}
+
+	if !shard.unlink(key) {
+
+		return false
+
+	}
+
+	shard.invalidateLoad(key)
+
+	shard.log.remove(key)
+
+	shard.metrics.remove()
+
+	shard.watchers.publish(event)
+
+	return true
+}
+
+// unlink removes key without logging or publishing it, for keys that only
+// move between shards.
+func (shard *shard[K, V]) unlink(key K) (ok bool) {
+
+	shard.own()
+
+	delete(shard.expiries, key)
+
+	if shard.policy != nil {
+
+		shard.policy.remove(key)
+
+	}
+
+	if shard.memory.sizer == nil {
+
+		return shard.items.Delete(key)
+
+	}
+
+	value, ok := shard.items.Get(key)
+
+	if ok {
+
+		shard.items.Delete(key)
+
+		shard.memory.data -= int64(shard.memory.sizer(key, value))
+
+	}
+
+	return ok
+}
+
+func (shard *shard[K, V]) clear() {
+
+	if shard.snapshots.Load() > 0 {
+
+		shard.items = shard.newItems()
+
+		shard.generation++
+
+		shard.snapshots.Store(0)
+
+	} else {
+
+		shard.items.Clear()
+
+	}
+
+	shard.expiries, shard.failures, shard.memory.data = nil, nil, 0
+
+	for _, call := range shard.loads {
+
+		call.stale = true
+
+	}
+
+	if shard.policy != nil {
+
+		shard.policy.clear()
+
+	}
+}
+
+// iter visits the live entries of the shard under its read lock and reports
+// whether callback asked to stop.
+func (shard *shard[K, V]) iter(callback func(key K, value V) bool) (stopped bool) {
+
+	shard.RLock()
+
+	defer shard.RUnlock()
+
+	checkExpiry, now := len(shard.expiries) > 0, nowNano()
+
+	shard.items.Iter(func(key K, value V) bool {
+
+		if checkExpiry && shard.expired(key, now) {
+
+			return false
+
+		}
+
+		stopped = callback(key, value)
+
+		return stopped
+
+	})
+
+	return stopped
+}
+
+// lemire.me/blog/2016/06/27/a-fast-alternative-to-the-modulo-reduction/
+func fastModN(x, n uint32) uint32 {
+
+	return uint32((uint64(x) * uint64(n)) >> 32)
 
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/dolthub/maphash"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
+	"sync"
 	"testing"
 )
 
@@ -28,15 +29,71 @@ func TestNewShardMap(t *testing.T) {
 
 	assertions.NotNil(shardMap)
 
-	assertions.NotNil(shardMap.shards)
+	assertions.NotNil(shardMap.currentShards())
 
-	for _, shard := range shardMap.shards {
+	for _, shard := range shardMap.currentShards() {
 
 		assertions.NotNil(shard)
 
+		assertions.IsType(mapBackend[string, int]{}, shard.items)
+
+	}
+
+	assertions.Equal(len(shardMap.currentShards()), 4)
+
+}
+
+func TestNewShardMapOf(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	type record struct {
+		Name string
 	}
 
-	assertions.Equal(len(shardMap.shards), 4)
+	t.Run("DefaultHasher", func(t *testing.T) {
+
+		shardMap := NewShardMapOf[uint64, *record](8)
+
+		for i := uint64(0); i < 100; i++ {
+
+			shardMap.Set(i, &record{Name: fmt.Sprintf("test%v", i)})
+
+		}
+
+		assertions.Equal(100, shardMap.Len())
+
+		value, ok := shardMap.Get(42)
+
+		assertions.True(ok)
+
+		assertions.Equal("test42", value.Name)
+
+	})
+
+	t.Run("CustomHasher", func(t *testing.T) {
+
+		shardMap := NewShardMapOf[[2]byte, record](4, WithHasher[[2]byte, record](func(key [2]byte) uint64 {
+
+			return uint64(key[0]) << 30
+
+		}))
+
+		shardMap.Set([2]byte{0, 1}, record{Name: "first"})
+
+		shardMap.Set([2]byte{3, 1}, record{Name: "last"})
+
+		assertions.Equal(uint32(0), shardMap.GetShardIndex([2]byte{0, 1}))
+
+		assertions.Equal(uint32(3), shardMap.GetShardIndex([2]byte{3, 1}))
+
+		value, ok := shardMap.Get([2]byte{3, 1})
+
+		assertions.True(ok)
+
+		assertions.Equal("last", value.Name)
+
+	})
 
 }
 
@@ -48,9 +105,9 @@ func TestShardMapSet(t *testing.T) {
 
 	shardMap.Set("test", 1)
 
-	assertions.NotNil(shardMap.shards[shardMap.GetShardIndex("test")])
+	assertions.NotNil(shardMap.currentShards()[shardMap.GetShardIndex("test")])
 
-	value, ok := shardMap.shards[shardMap.GetShardIndex("test")]["test"]
+	value, ok := shardMap.currentShards()[shardMap.GetShardIndex("test")].items.(mapBackend[string, int])["test"]
 
 	assertions.True(ok)
 
@@ -64,9 +121,9 @@ func TestShardMapGet(t *testing.T) {
 
 	shardMap := NewShardMap(4)
 
-	assertions.NotNil(shardMap.shards[shardMap.GetShardIndex("test")])
+	assertions.NotNil(shardMap.currentShards()[shardMap.GetShardIndex("test")])
 
-	shardMap.shards[shardMap.GetShardIndex("test")] = map[string]int{"test": 1}
+	shardMap.currentShards()[shardMap.GetShardIndex("test")].items = mapBackend[string, int]{"test": 1}
 
 	value, ok := shardMap.Get("test")
 
@@ -90,13 +147,13 @@ func TestShardMapRemove(t *testing.T) {
 
 	shardMap := NewShardMap(4)
 
-	assertions.NotNil(shardMap.shards[shardMap.GetShardIndex("test")])
+	assertions.NotNil(shardMap.currentShards()[shardMap.GetShardIndex("test")])
 
-	shardMap.shards[shardMap.GetShardIndex("test")] = map[string]int{"test": 1}
+	shardMap.currentShards()[shardMap.GetShardIndex("test")].items = mapBackend[string, int]{"test": 1}
 
 	shardMap.Remove("test")
 
-	_, ok := shardMap.shards[shardMap.GetShardIndex("test")]["test"]
+	_, ok := shardMap.currentShards()[shardMap.GetShardIndex("test")].items.(mapBackend[string, int])["test"]
 
 	assertions.False(ok)
 
@@ -118,9 +175,9 @@ func TestShardMapRemoveAll(t *testing.T) {
 
 	shardMap.RemoveAll()
 
-	for _, Shd := range shardMap.shards {
+	for _, Shd := range shardMap.currentShards() {
 
-		assertions.Zero(len(Shd))
+		assertions.Zero(Shd.items.Len())
 	}
 
 }
@@ -189,9 +246,9 @@ func TestShardMapIter(t *testing.T) {
 
 		})
 
-		for _, shard := range shardMap.shards {
+		for _, shard := range shardMap.currentShards() {
 
-			for key := range shard {
+			for key := range shard.items.(mapBackend[string, int]) {
 
 				_, ok := visited[key]
 
@@ -227,6 +284,40 @@ func TestShardMapIter(t *testing.T) {
 
 	})
 
+	t.Run("StopIsGlobal", func(t *testing.T) {
+
+		for i := 0; i < 100; i++ {
+
+			shardMap.Set(fmt.Sprintf("global%v", i), i)
+
+		}
+
+		visited := 0
+
+		shardMap.Iter(func(key string, value int) bool {
+
+			visited++
+
+			return true
+
+		})
+
+		assertions.Equal(1, visited)
+
+		visited = 0
+
+		shardMap.IterShard(func(key string, value int) bool {
+
+			visited++
+
+			return visited == 10
+
+		}, -1)
+
+		assertions.Equal(10, visited)
+
+	})
+
 }
 
 func TestShardMapLen(t *testing.T) {
@@ -284,9 +375,9 @@ func TestShardMapIterShard(t *testing.T) {
 
 		assertions.Nil(err)
 
-		for _, shard := range shardMap.shards {
+		for _, shard := range shardMap.currentShards() {
 
-			for key := range shard {
+			for key := range shard.items.(mapBackend[string, int]) {
 
 				_, ok := visited[key]
 
@@ -307,6 +398,12 @@ func TestShardMapIterShard(t *testing.T) {
 
 		assertions.EqualError(err, fmt.Sprintf(ErrorShardNotExists, 6))
 
+		var shardErr *ShardNotExistsError
+
+		assertions.ErrorAs(err, &shardErr)
+
+		assertions.Equal(6, shardErr.Shard)
+
 	})
 	t.Run("ValidCase/StopFalse", func(t *testing.T) {
 
@@ -322,7 +419,7 @@ func TestShardMapIterShard(t *testing.T) {
 
 		assertions.Nil(err)
 
-		for key := range shardMap.shards[3] {
+		for key := range shardMap.currentShards()[3].items.(mapBackend[string, int]) {
 
 			_, ok := visited[key]
 
@@ -337,7 +434,7 @@ func TestShardMapIterShard(t *testing.T) {
 
 		var stopkey string
 
-		for key := range shardMap.shards[3] {
+		for key := range shardMap.currentShards()[3].items.(mapBackend[string, int]) {
 
 			stopkey = key
 
@@ -398,6 +495,118 @@ func TestShardMapShards(t *testing.T) {
 
 }
 
+func TestShardMapConcurrentAccess(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardMap := NewShardMap(16)
+
+	const goroutines, operations = 32, 2000
+
+	var wg sync.WaitGroup
+
+	for worker := 0; worker < goroutines; worker++ {
+
+		wg.Add(1)
+
+		go func(worker int) {
+
+			defer wg.Done()
+
+			for i := 0; i < operations; i++ {
+
+				key := fmt.Sprintf("test%v", rand.Intn(operations))
+
+				switch i % 8 {
+
+				case 0, 1, 2:
+
+					shardMap.Set(key, i)
+
+				case 3:
+
+					shardMap.Get(key)
+
+				case 4:
+
+					shardMap.Remove(key)
+
+				case 5:
+
+					shardMap.Contains(key)
+
+				case 6:
+
+					shardMap.Len()
+
+				default:
+
+					shardMap.IterShard(func(key string, value int) bool {
+
+						return false
+
+					}, worker%shardMap.Shards())
+
+				}
+
+			}
+
+			if worker == 0 {
+
+				shardMap.RemoveAll()
+
+			}
+
+		}(worker)
+
+	}
+
+	wg.Wait()
+
+	shardMap.RemoveAll()
+
+	assertions.Zero(shardMap.Len())
+
+	t.Run("DisjointWriters", func(t *testing.T) {
+
+		var wg sync.WaitGroup
+
+		for worker := 0; worker < goroutines; worker++ {
+
+			wg.Add(1)
+
+			go func(worker int) {
+
+				defer wg.Done()
+
+				for i := 0; i < operations; i++ {
+
+					shardMap.Set(fmt.Sprintf("worker%v-%v", worker, i), i)
+
+				}
+
+			}(worker)
+
+		}
+
+		wg.Wait()
+
+		assertions.Equal(goroutines*operations, shardMap.Len())
+
+		for worker := 0; worker < goroutines; worker++ {
+
+			value, ok := shardMap.Get(fmt.Sprintf("worker%v-%v", worker, operations-1))
+
+			assertions.True(ok)
+
+			assertions.Equal(operations-1, value)
+
+		}
+
+	})
+
+}
+
 func BenchmarkShardMapNew(b *testing.B) {
 
 	numShards := []int{10, 1000, 10000}
@@ -660,7 +869,7 @@ func BenchmarkShardMapIterShard(b *testing.B) {
 
 //-----------------------------------------------------Helper Functions-----------------------------------------------
 
-func setElementsShardMap(shardMap *ShardMap, elements int) {
+func setElementsShardMap(shardMap *ShardMap[string, int], elements int) {
 
 	for i := 0; i < elements; i++ {
 
@@ -0,0 +1,353 @@
+package src
+
+import (
+	"cmp"
+	"container/heap"
+	"iter"
+	"strings"
+)
+
+// ShardSortedMap is a ShardMap whose shards are backed by SortedBackend. Its
+// iterators merge the shards into a single stream ordered by key.
+//
+// Iterators read the shards in batches and hold no lock while the loop body
+// runs, so the body may use the map. A write made meanwhile is seen if its key
+// comes after the batch being yielded.
+type ShardSortedMap[K cmp.Ordered, V any] struct {
+	*ShardMap[K, V]
+}
+
+var _ ShardedMap[string, int] = (*ShardSortedMap[string, int])(nil)
+
+// mergeHeap orders the next entry of every shard, smallest key first or
+// largest first if reverse is set.
+type mergeHeap[K cmp.Ordered, V any] struct {
+	heads []mergeHead[K, V]
+
+	reverse bool
+}
+
+type mergeHead[K cmp.Ordered, V any] struct {
+	batch []KV[K, V]
+}
+
+// mergeBatch is how many entries merge reads from each shard while the shards
+// are locked.
+const mergeBatch = 256
+
+func NewShardSortedMap(numShards int) *ShardSortedMap[string, int] {
+
+	return NewShardSortedMapOf[string, int](numShards, stringIntOptions()...)
+
+}
+
+func NewShardSortedMapOf[K cmp.Ordered, V any](numShards int, opts ...Option[K, V]) *ShardSortedMap[K, V] {
+
+	return &ShardSortedMap[K, V]{
+
+		ShardMap: NewShardMapOf[K, V](numShards, append(opts[:len(opts):len(opts)], WithBackend(SortedBackend[K, V]))...),
+	}
+}
+
+// Ascend returns an iterator over every entry in ascending key order.
+func (sortedMap *ShardSortedMap[K, V]) Ascend() iter.Seq2[K, V] {
+
+	return sortedMap.merge(*new(K), false, false)
+
+}
+
+// Descend returns an iterator over every entry in descending key order.
+func (sortedMap *ShardSortedMap[K, V]) Descend() iter.Seq2[K, V] {
+
+	return sortedMap.merge(*new(K), false, true)
+
+}
+
+// Seek returns an iterator in ascending key order that starts at the first key
+// not less than from.
+func (sortedMap *ShardSortedMap[K, V]) Seek(from K) iter.Seq2[K, V] {
+
+	return sortedMap.merge(from, true, false)
+
+}
+
+// SeekReverse returns an iterator in descending key order that starts at the
+// last key not greater than from.
+func (sortedMap *ShardSortedMap[K, V]) SeekReverse(from K) iter.Seq2[K, V] {
+
+	return sortedMap.merge(from, true, true)
+
+}
+
+// Range returns an iterator over the keys in [from, to) in ascending order.
+func (sortedMap *ShardSortedMap[K, V]) Range(from, to K) iter.Seq2[K, V] {
+
+	return func(yield func(key K, value V) bool) {
+
+		for key, value := range sortedMap.Seek(from) {
+
+			if cmp.Compare(key, to) >= 0 || !yield(key, value) {
+
+				return
+			}
+
+		}
+
+	}
+}
+
+// RangeReverse returns an iterator over the keys in [from, to) in descending
+// order.
+func (sortedMap *ShardSortedMap[K, V]) RangeReverse(from, to K) iter.Seq2[K, V] {
+
+	return func(yield func(key K, value V) bool) {
+
+		for key, value := range sortedMap.SeekReverse(to) {
+
+			if cmp.Compare(key, to) == 0 {
+
+				continue
+			}
+
+			if cmp.Compare(key, from) < 0 || !yield(key, value) {
+
+				return
+			}
+
+		}
+
+	}
+}
+
+// Prefix returns an iterator over the keys of sortedMap that start with prefix,
+// in ascending order.
+func Prefix[V any](sortedMap *ShardSortedMap[string, V], prefix string) iter.Seq2[string, V] {
+
+	return func(yield func(key string, value V) bool) {
+
+		for key, value := range sortedMap.Seek(prefix) {
+
+			if !strings.HasPrefix(key, prefix) || !yield(key, value) {
+
+				return
+			}
+
+		}
+
+	}
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+// merge is a k-way merge of the ordered entries of every shard, starting from
+// from if seek is set. It goes in rounds that each read up to mergeBatch
+// entries of every shard under their locks, then yield the entries up to the
+// smallest last key of a shard that had more, so that nothing in between is
+// missed. The next round carries on past that key. While a Resize is in
+// progress the shards of both tables are merged, which is consistent because a
+// key is only ever moved between two shards under both of their write locks.
+func (sortedMap *ShardSortedMap[K, V]) merge(from K, seek, reverse bool) iter.Seq2[K, V] {
+
+	return func(yield func(key K, value V) bool) {
+
+		// past is set once a round has ended at from, which is then skipped.
+		past := false
+
+		for {
+
+			batches, bound, more := sortedMap.readBatches(from, seek || past, past, reverse)
+
+			merged := &mergeHeap[K, V]{heads: make([]mergeHead[K, V], 0, len(batches)), reverse: reverse}
+
+			for _, batch := range batches {
+
+				if len(batch) > 0 {
+
+					merged.heads = append(merged.heads, mergeHead[K, V]{batch: batch})
+
+				}
+
+			}
+
+			heap.Init(merged)
+
+			for len(merged.heads) > 0 {
+
+				head := &merged.heads[0]
+
+				entry := head.batch[0]
+
+				if more && after(entry.Key, bound, reverse) {
+
+					break
+				}
+
+				if !yield(entry.Key, entry.Value) {
+
+					return
+				}
+
+				if head.batch = head.batch[1:]; len(head.batch) > 0 {
+
+					heap.Fix(merged, 0)
+
+				} else {
+
+					heap.Pop(merged)
+
+				}
+
+			}
+
+			if !more {
+
+				return
+			}
+
+			from, past = bound, true
+
+		}
+
+	}
+}
+
+// readBatches reads the next entries of every shard from from, leaving out from
+// itself if past is set. bound is the last key read from a shard that has more
+// entries, the one coming first in iteration order if several do.
+func (sortedMap *ShardSortedMap[K, V]) readBatches(from K, seek, past, reverse bool) (batches [][]KV[K, V], bound K, more bool) {
+
+	sortedMap.resizeMu.RLock()
+
+	defer sortedMap.resizeMu.RUnlock()
+
+	// The previous table is locked first, the order in which Resize locks.
+	shards := sortedMap.allShards()
+
+	for _, shard := range shards {
+
+		shard.RLock()
+
+	}
+
+	defer func() {
+
+		for _, shard := range shards {
+
+			shard.RUnlock()
+
+		}
+
+	}()
+
+	now := nowNano()
+
+	batches = make([][]KV[K, V], len(shards))
+
+	for shardIndex, shard := range shards {
+
+		var batch []KV[K, V]
+
+		shard.ordered(from, seek, reverse, now)(func(key K, value V) bool {
+
+			if past && key == from {
+
+				return true
+			}
+
+			batch = append(batch, KV[K, V]{Key: key, Value: value})
+
+			return len(batch) < mergeBatch
+
+		})
+
+		if len(batch) == mergeBatch {
+
+			last := batch[len(batch)-1].Key
+
+			if !more || after(bound, last, reverse) {
+
+				bound, more = last, true
+
+			}
+
+		}
+
+		batches[shardIndex] = batch
+
+	}
+
+	return batches, bound, more
+}
+
+// ordered returns the live entries of the shard in key order. The shard must be
+// read-locked for as long as the iterator is used.
+func (shard *shard[K, V]) ordered(from K, seek, reverse bool, now int64) iter.Seq2[K, V] {
+
+	backend := shard.items.(orderedBackend[K, V])
+
+	visit := backend.ascend
+
+	if reverse {
+
+		visit = backend.descend
+
+	}
+
+	checkExpiry := len(shard.expiries) > 0
+
+	return func(yield func(key K, value V) bool) {
+
+		visit(from, seek, func(key K, value V) bool {
+
+			return checkExpiry && shard.expired(key, now) || yield(key, value)
+
+		})
+
+	}
+}
+
+// after reports whether key comes after other in ascending order, or in
+// descending order if reverse is set.
+func after[K cmp.Ordered](key, other K, reverse bool) bool {
+
+	if reverse {
+
+		return cmp.Less(key, other)
+
+	}
+
+	return cmp.Less(other, key)
+}
+
+func (merged *mergeHeap[K, V]) Len() int {
+
+	return len(merged.heads)
+
+}
+
+func (merged *mergeHeap[K, V]) Less(i, j int) bool {
+
+	return after(merged.heads[j].batch[0].Key, merged.heads[i].batch[0].Key, merged.reverse)
+
+}
+
+func (merged *mergeHeap[K, V]) Swap(i, j int) {
+
+	merged.heads[i], merged.heads[j] = merged.heads[j], merged.heads[i]
+
+}
+
+func (merged *mergeHeap[K, V]) Push(head any) {
+
+	merged.heads = append(merged.heads, head.(mergeHead[K, V]))
+
+}
+
+func (merged *mergeHeap[K, V]) Pop() any {
+
+	head := merged.heads[len(merged.heads)-1]
+
+	merged.heads = merged.heads[:len(merged.heads)-1]
+
+	return head
+}
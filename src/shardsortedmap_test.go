@@ -0,0 +1,207 @@
+package src
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"iter"
+	"testing"
+	"time"
+)
+
+func collect[K comparable, V any](seq iter.Seq2[K, V]) []K {
+
+	keys := []K{}
+
+	for key := range seq {
+
+		keys = append(keys, key)
+
+	}
+
+	return keys
+}
+
+func TestShardSortedMap(t *testing.T) {
+
+	newMap := func() *ShardSortedMap[int, int] {
+
+		sortedMap := NewShardSortedMapOf[int, int](8)
+
+		for i := 0; i < 100; i++ {
+
+			sortedMap.Set(i*2, i)
+
+		}
+
+		return sortedMap
+	}
+
+	t.Run("Order", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		sortedMap := newMap()
+
+		keys := collect(sortedMap.Ascend())
+
+		assertions.Len(keys, 100)
+
+		assertions.IsIncreasing(keys)
+
+		keys = collect(sortedMap.Descend())
+
+		assertions.Len(keys, 100)
+
+		assertions.IsDecreasing(keys)
+
+		assertions.Equal([]int{0, 2, 4}, collect(sortedMap.Range(-5, 5)))
+
+		assertions.Equal([]int{10, 12}, collect(sortedMap.Range(10, 14)))
+
+		assertions.Equal([]int{12, 10}, collect(sortedMap.RangeReverse(10, 14)))
+
+		assertions.Equal([]int{}, collect(sortedMap.Range(500, 600)))
+
+		assertions.Equal([]int{196, 198}, collect(sortedMap.Seek(195)))
+
+		assertions.Equal([]int{2, 0}, collect(sortedMap.SeekReverse(3)))
+
+		assertions.Equal([]int{2, 0}, collect(sortedMap.SeekReverse(2)))
+
+	})
+
+	t.Run("Break", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		sortedMap := newMap()
+
+		for key, value := range sortedMap.Seek(50) {
+
+			assertions.Equal(50, key)
+
+			assertions.Equal(25, value)
+
+			break
+		}
+
+		// The locks of every shard are released again.
+		sortedMap.Set(1, 1)
+
+		assertions.Equal([]int{0, 1, 2}, collect(sortedMap.Range(0, 3)))
+
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		advance := fakeClock(t)
+
+		sortedMap := newMap()
+
+		sortedMap.SetWithTTL(4, 2, time.Second)
+
+		advance(time.Second)
+
+		assertions.Equal([]int{0, 2, 6}, collect(sortedMap.Range(0, 8)))
+
+	})
+
+	t.Run("Resize", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		sortedMap := newMap()
+
+		assertions.NoError(sortedMap.Resize(32))
+
+		keys := collect(sortedMap.Ascend())
+
+		assertions.Len(keys, 100)
+
+		assertions.IsIncreasing(keys)
+
+		sortedMap.WaitResize()
+
+		assertions.Equal(keys, collect(sortedMap.Ascend()))
+
+	})
+
+	t.Run("Batches", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		sortedMap := NewShardSortedMapOf[int, int](4)
+
+		for i := 0; i < 5000; i++ {
+
+			sortedMap.Set(i*2, i)
+
+		}
+
+		keys := collect(sortedMap.Ascend())
+
+		assertions.Len(keys, 5000)
+
+		assertions.IsIncreasing(keys)
+
+		keys = collect(sortedMap.Descend())
+
+		assertions.Len(keys, 5000)
+
+		assertions.IsDecreasing(keys)
+
+		assertions.Len(collect(sortedMap.Range(1001, 9001)), 4000)
+
+		assertions.Len(collect(sortedMap.RangeReverse(1000, 9000)), 4000)
+
+		// No lock is held while the body runs, so it may write to the map.
+		// Every key present throughout is still visited once.
+		visited := 0
+
+		for key := range sortedMap.Ascend() {
+
+			if key%2 == 0 {
+
+				sortedMap.Remove(key)
+
+				sortedMap.Set(key+1, key)
+
+				visited++
+
+			}
+
+		}
+
+		assertions.Equal(5000, visited)
+
+		assertions.Equal(5000, sortedMap.Len())
+
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		sortedMap := NewShardSortedMap(4)
+
+		for user := 0; user < 50; user++ {
+
+			for item := 0; item < 3; item++ {
+
+				sortedMap.Set(fmt.Sprintf("user:%v:%v", user, item), item)
+
+			}
+
+		}
+
+		assertions.Equal([]string{"user:42:0", "user:42:1", "user:42:2"}, collect(Prefix(sortedMap, "user:42:")))
+
+		assertions.Len(collect(Prefix(sortedMap, "user:4")), 33)
+
+		assertions.Empty(collect(Prefix(sortedMap, "group:")))
+
+	})
+
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
+	"sync"
 	"testing"
 )
 
@@ -15,15 +16,71 @@ func TestNewShardSwissMap(t *testing.T) {
 
 	assertions.NotNil(shardSwissMap)
 
-	assertions.NotNil(shardSwissMap.shards)
+	assertions.NotNil(shardSwissMap.currentShards())
 
-	for _, shard := range shardSwissMap.shards {
+	for _, shard := range shardSwissMap.currentShards() {
 
 		assertions.NotNil(shard)
 
+		assertions.IsType(swissBackend[string, int]{}, shard.items)
+
+	}
+
+	assertions.Equal(len(shardSwissMap.currentShards()), 4)
+
+}
+
+func TestNewShardSwissMapOf(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	type record struct {
+		Name string
 	}
 
-	assertions.Equal(len(shardSwissMap.shards), 4)
+	t.Run("DefaultHasher", func(t *testing.T) {
+
+		shardSwissMap := NewShardSwissMapOf[uint64, *record](8)
+
+		for i := uint64(0); i < 100; i++ {
+
+			shardSwissMap.Set(i, &record{Name: fmt.Sprintf("test%v", i)})
+
+		}
+
+		assertions.Equal(100, shardSwissMap.Len())
+
+		value, ok := shardSwissMap.Get(42)
+
+		assertions.True(ok)
+
+		assertions.Equal("test42", value.Name)
+
+	})
+
+	t.Run("CustomHasher", func(t *testing.T) {
+
+		shardSwissMap := NewShardSwissMapOf[[2]byte, record](4, WithHasher[[2]byte, record](func(key [2]byte) uint64 {
+
+			return uint64(key[0]) << 30
+
+		}))
+
+		shardSwissMap.Set([2]byte{0, 1}, record{Name: "first"})
+
+		shardSwissMap.Set([2]byte{3, 1}, record{Name: "last"})
+
+		assertions.Equal(uint32(0), shardSwissMap.GetShardIndex([2]byte{0, 1}))
+
+		assertions.Equal(uint32(3), shardSwissMap.GetShardIndex([2]byte{3, 1}))
+
+		value, ok := shardSwissMap.Get([2]byte{3, 1})
+
+		assertions.True(ok)
+
+		assertions.Equal("last", value.Name)
+
+	})
 
 }
 
@@ -35,9 +92,9 @@ func TestShardSwissMapSet(t *testing.T) {
 
 	shardSwissMap.Set("test", 1)
 
-	assertions.NotNil(shardSwissMap.shards[shardSwissMap.GetShardIndex("test")])
+	assertions.NotNil(shardSwissMap.currentShards()[shardSwissMap.GetShardIndex("test")])
 
-	value, ok := shardSwissMap.shards[shardSwissMap.GetShardIndex("test")].Get("test")
+	value, ok := shardSwissMap.currentShards()[shardSwissMap.GetShardIndex("test")].items.Get("test")
 
 	assertions.True(ok)
 
@@ -51,7 +108,7 @@ func TestShardSwissMapGet(t *testing.T) {
 
 	shardSwissMap := NewShardSwissMap(4)
 
-	assertions.NotNil(shardSwissMap.shards[shardSwissMap.GetShardIndex("test")])
+	assertions.NotNil(shardSwissMap.currentShards()[shardSwissMap.GetShardIndex("test")])
 
 	shardSwissMap.Set("test", 1)
 
@@ -77,13 +134,13 @@ func TestShardSwissMapRemove(t *testing.T) {
 
 	shardSwissMap := NewShardSwissMap(4)
 
-	assertions.NotNil(shardSwissMap.shards[shardSwissMap.GetShardIndex("test")])
+	assertions.NotNil(shardSwissMap.currentShards()[shardSwissMap.GetShardIndex("test")])
 
 	shardSwissMap.Set("test", 1)
 
 	shardSwissMap.Remove("test")
 
-	_, ok := shardSwissMap.shards[shardSwissMap.GetShardIndex("test")].Get("test")
+	_, ok := shardSwissMap.currentShards()[shardSwissMap.GetShardIndex("test")].items.Get("test")
 
 	assertions.False(ok)
 
@@ -105,9 +162,9 @@ func TestShardSwissMapRemoveAll(t *testing.T) {
 
 	shardSwissMap.RemoveAll()
 
-	for _, shard := range shardSwissMap.shards {
+	for _, shard := range shardSwissMap.currentShards() {
 
-		assertions.Zero(shard.Count())
+		assertions.Zero(shard.items.Len())
 	}
 
 }
@@ -138,9 +195,9 @@ func TestShardSwissMapIter(t *testing.T) {
 
 		})
 
-		for _, shard := range shardSwissMap.shards {
+		for _, shard := range shardSwissMap.currentShards() {
 
-			shard.Iter(func(key string, value int) bool {
+			shard.items.Iter(func(key string, value int) bool {
 
 				_, ok := visited[key]
 
@@ -178,6 +235,40 @@ func TestShardSwissMapIter(t *testing.T) {
 
 	})
 
+	t.Run("StopIsGlobal", func(t *testing.T) {
+
+		for i := 0; i < 100; i++ {
+
+			shardSwissMap.Set(fmt.Sprintf("global%v", i), i)
+
+		}
+
+		visited := 0
+
+		shardSwissMap.Iter(func(key string, value int) bool {
+
+			visited++
+
+			return true
+
+		})
+
+		assertions.Equal(1, visited)
+
+		visited = 0
+
+		shardSwissMap.IterShard(func(key string, value int) bool {
+
+			visited++
+
+			return visited == 10
+
+		}, -1)
+
+		assertions.Equal(10, visited)
+
+	})
+
 }
 
 func TestShardSwissMapLen(t *testing.T) {
@@ -235,9 +326,9 @@ func TestShardSwissMapIterShard(t *testing.T) {
 
 		assertions.Nil(err)
 
-		for _, shard := range shardSwissMap.shards {
+		for _, shard := range shardSwissMap.currentShards() {
 
-			shard.Iter(func(key string, value int) bool {
+			shard.items.Iter(func(key string, value int) bool {
 
 				_, ok := visited[key]
 
@@ -275,7 +366,7 @@ func TestShardSwissMapIterShard(t *testing.T) {
 
 		assertions.Nil(err)
 
-		shardSwissMap.shards[3].Iter(func(key string, value int) bool {
+		shardSwissMap.currentShards()[3].items.Iter(func(key string, value int) bool {
 
 			_, ok := visited[key]
 
@@ -293,7 +384,7 @@ func TestShardSwissMapIterShard(t *testing.T) {
 
 		var stopKey string
 
-		shardSwissMap.shards[3].Iter(func(key string, value int) bool {
+		shardSwissMap.currentShards()[3].items.Iter(func(key string, value int) bool {
 
 			stopKey = key
 
@@ -355,6 +446,118 @@ func TestShardSwissMapNumShards(t *testing.T) {
 
 }
 
+func TestShardSwissMapConcurrentAccess(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardSwissMap := NewShardSwissMap(16)
+
+	const goroutines, operations = 32, 2000
+
+	var wg sync.WaitGroup
+
+	for worker := 0; worker < goroutines; worker++ {
+
+		wg.Add(1)
+
+		go func(worker int) {
+
+			defer wg.Done()
+
+			for i := 0; i < operations; i++ {
+
+				key := fmt.Sprintf("test%v", rand.Intn(operations))
+
+				switch i % 8 {
+
+				case 0, 1, 2:
+
+					shardSwissMap.Set(key, i)
+
+				case 3:
+
+					shardSwissMap.Get(key)
+
+				case 4:
+
+					shardSwissMap.Remove(key)
+
+				case 5:
+
+					shardSwissMap.Contains(key)
+
+				case 6:
+
+					shardSwissMap.Len()
+
+				default:
+
+					shardSwissMap.IterShard(func(key string, value int) bool {
+
+						return false
+
+					}, worker%shardSwissMap.NumShards())
+
+				}
+
+			}
+
+			if worker == 0 {
+
+				shardSwissMap.RemoveAll()
+
+			}
+
+		}(worker)
+
+	}
+
+	wg.Wait()
+
+	shardSwissMap.RemoveAll()
+
+	assertions.Zero(shardSwissMap.Len())
+
+	t.Run("DisjointWriters", func(t *testing.T) {
+
+		var wg sync.WaitGroup
+
+		for worker := 0; worker < goroutines; worker++ {
+
+			wg.Add(1)
+
+			go func(worker int) {
+
+				defer wg.Done()
+
+				for i := 0; i < operations; i++ {
+
+					shardSwissMap.Set(fmt.Sprintf("worker%v-%v", worker, i), i)
+
+				}
+
+			}(worker)
+
+		}
+
+		wg.Wait()
+
+		assertions.Equal(goroutines*operations, shardSwissMap.Len())
+
+		for worker := 0; worker < goroutines; worker++ {
+
+			value, ok := shardSwissMap.Get(fmt.Sprintf("worker%v-%v", worker, operations-1))
+
+			assertions.True(ok)
+
+			assertions.Equal(operations-1, value)
+
+		}
+
+	})
+
+}
+
 func BenchmarkShardSwissMapNew(b *testing.B) {
 
 	NumShards := []int{10, 1000, 10000}
@@ -617,7 +820,7 @@ func BenchmarkShardSwissMapIterShard(b *testing.B) {
 
 //-----------------------------------------------------Helper Functions-----------------------------------------------
 
-func setElementsShardSwissMap(shardSwissMap *ShardSwissMap, elements int) {
+func setElementsShardSwissMap(shardSwissMap *ShardSwissMap[string, int], elements int) {
 
 	for i := 0; i < elements; i++ {
 
@@ -0,0 +1,94 @@
+package src
+
+import "math/bits"
+
+const sketchDepth = 4
+
+// sketchSeeds are odd multipliers, one per row, that spread a key hash over
+// independent looking counter positions.
+var sketchSeeds = [sketchDepth]uint64{
+
+	0x9e3779b97f4a7c15,
+
+	0xc2b2ae3d27d4eb4f,
+
+	0x165667b19e3779f9,
+
+	0xd6e8feb86659fd93,
+}
+
+// countMinSketch estimates how often a hash has been seen. Estimates never
+// undercount, they only overcount on collisions.
+type countMinSketch struct {
+	rows [sketchDepth][]uint32
+
+	shift uint
+}
+
+func newCountMinSketch(width int) *countMinSketch {
+
+	width = max(width, 16)
+
+	shift := uint(bits.LeadingZeros64(uint64(width - 1)))
+
+	sketch := &countMinSketch{shift: shift}
+
+	for row := range sketch.rows {
+
+		sketch.rows[row] = make([]uint32, 1<<(64-shift))
+
+	}
+
+	return sketch
+}
+
+func (sketch *countMinSketch) increment(hash uint64) {
+
+	for row := range sketch.rows {
+
+		counter := &sketch.rows[row][(hash*sketchSeeds[row])>>sketch.shift]
+
+		if *counter < ^uint32(0) {
+
+			*counter++
+
+		}
+
+	}
+}
+
+func (sketch *countMinSketch) estimate(hash uint64) uint32 {
+
+	estimate := ^uint32(0)
+
+	for row := range sketch.rows {
+
+		estimate = min(estimate, sketch.rows[row][(hash*sketchSeeds[row])>>sketch.shift])
+
+	}
+
+	return estimate
+}
+
+// halve ages every counter so that old popularity fades out.
+func (sketch *countMinSketch) halve() {
+
+	for row := range sketch.rows {
+
+		for i := range sketch.rows[row] {
+
+			sketch.rows[row][i] >>= 1
+
+		}
+
+	}
+}
+
+func (sketch *countMinSketch) reset() {
+
+	for row := range sketch.rows {
+
+		clear(sketch.rows[row])
+
+	}
+}
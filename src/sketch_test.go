@@ -0,0 +1,48 @@
+package src
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCountMinSketch(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	sketch := newCountMinSketch(64)
+
+	// A fixed hash keeps the collisions, and so the estimates, deterministic.
+	hasher := func(i int) uint64 {
+
+		return uint64(i) * 0xbf58476d1ce4e5b9
+	}
+
+	for i := 0; i < 100; i++ {
+
+		for j := 0; j <= i%10; j++ {
+
+			sketch.increment(hasher(i))
+
+		}
+
+	}
+
+	for i := 0; i < 100; i++ {
+
+		assertions.GreaterOrEqual(sketch.estimate(hasher(i)), uint32(i%10+1))
+
+	}
+
+	assertions.GreaterOrEqual(sketch.estimate(hasher(9)), sketch.estimate(hasher(1000)))
+
+	before := sketch.estimate(hasher(9))
+
+	sketch.halve()
+
+	assertions.Equal(before/2, sketch.estimate(hasher(9)))
+
+	sketch.reset()
+
+	assertions.Zero(sketch.estimate(hasher(9)))
+
+}
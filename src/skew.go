@@ -0,0 +1,384 @@
+package src
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SkewReport describes how evenly a map spreads its entries and operations
+// over its shards.
+type SkewReport[K comparable] struct {
+	Shards []ShardLoad[K]
+
+	Sizes Uniformity
+
+	// Ops is only meaningful for maps created WithMetrics.
+	Ops Uniformity
+}
+
+type ShardLoad[K comparable] struct {
+	Entries int
+
+	// Ops counts lookups, sets and removes since the shard was created, see
+	// Stats.
+	Ops uint64
+
+	OpsPerSecond float64
+
+	// HotKeys holds the most accessed keys of the shard for maps created
+	// WithHotKeys, most accessed first.
+	HotKeys []HotKey[K]
+}
+
+type HotKey[K comparable] struct {
+	Key K
+
+	// Count estimates the recent accesses of the key. Older accesses count
+	// less and less as the tracker ages its counters.
+	Count uint64
+}
+
+// Uniformity compares counts per shard against a uniform spread with
+// Pearson's chi-squared test.
+type Uniformity struct {
+	ChiSquared float64
+
+	// PValue is the probability of a ChiSquared at least as large if the
+	// counts were spread uniformly. Values near 0 mean skew.
+	PValue float64
+
+	// MaxRatio is the largest count over the mean count.
+	MaxRatio float64
+}
+
+// hotKeys tracks the most accessed keys of a shard with a count-min sketch
+// and a handful of candidates, the keys with the highest estimates seen.
+type hotKeys[K comparable] struct {
+	mu sync.Mutex
+
+	sketch *countMinSketch
+
+	candidates map[K]uint32
+
+	size int
+
+	// floor is the lowest estimate among the candidates once they are full.
+	floor uint32
+
+	samples int
+
+	resetAt int
+}
+
+// AnalyzeSkew reports the load of every shard and how far it is from uniform.
+func (shardMap *ShardMap[K, V]) AnalyzeSkew() SkewReport[K] {
+
+	stats := shardMap.Stats()
+
+	shards := shardMap.currentShards()
+
+	report := SkewReport[K]{Shards: make([]ShardLoad[K], len(stats.Shards))}
+
+	sizes, ops := make([]float64, len(stats.Shards)), make([]float64, len(stats.Shards))
+
+	for shardIndex, shardStats := range stats.Shards {
+
+		load := &report.Shards[shardIndex]
+
+		load.Entries = shardStats.Entries
+
+		load.Ops = shardStats.Hits + shardStats.Misses + shardStats.Sets + shardStats.Removes
+
+		if shardIndex < len(shards) {
+
+			if metrics := shards[shardIndex].metrics; metrics != nil {
+
+				load.OpsPerSecond = float64(load.Ops) / time.Since(metrics.created).Seconds()
+
+			}
+
+			load.HotKeys = shards[shardIndex].hot.top(0)
+
+		}
+
+		sizes[shardIndex], ops[shardIndex] = float64(load.Entries), float64(load.Ops)
+
+	}
+
+	report.Sizes, report.Ops = MeasureUniformity(sizes), MeasureUniformity(ops)
+
+	return report
+}
+
+// HotKeys returns up to n of the most accessed keys across all shards, most
+// accessed first. It needs WithHotKeys.
+func (shardMap *ShardMap[K, V]) HotKeys(n int) []HotKey[K] {
+
+	var keys []HotKey[K]
+
+	for _, shard := range shardMap.currentShards() {
+
+		keys = append(keys, shard.hot.top(0)...)
+
+	}
+
+	sortHotKeys(keys)
+
+	return keys[:min(n, len(keys))]
+}
+
+// MeasureUniformity scores counts per shard. Fewer than two shards or no counts
+// at all are perfectly uniform.
+func MeasureUniformity(counts []float64) Uniformity {
+
+	total := 0.0
+
+	for _, count := range counts {
+
+		total += count
+
+	}
+
+	if len(counts) < 2 || total == 0 {
+
+		return Uniformity{PValue: 1, MaxRatio: 1}
+
+	}
+
+	mean := total / float64(len(counts))
+
+	uniformity := Uniformity{MaxRatio: slices.Max(counts) / mean}
+
+	for _, count := range counts {
+
+		uniformity.ChiSquared += (count - mean) * (count - mean) / mean
+
+	}
+
+	uniformity.PValue = chiSquaredSurvival(uniformity.ChiSquared, float64(len(counts)-1))
+
+	return uniformity
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func newHotKeys[K comparable](size int) *hotKeys[K] {
+
+	width := max(4096, 64*size)
+
+	return &hotKeys[K]{
+
+		sketch: newCountMinSketch(width),
+
+		candidates: make(map[K]uint32, size),
+
+		size: size,
+
+		resetAt: 10 * width,
+	}
+}
+
+// record counts an access to key. It does nothing on a nil tracker.
+func (hot *hotKeys[K]) record(key K, hash uint64) {
+
+	if hot == nil {
+
+		return
+
+	}
+
+	hot.mu.Lock()
+
+	defer hot.mu.Unlock()
+
+	hot.sketch.increment(hash)
+
+	estimate := hot.sketch.estimate(hash)
+
+	if _, found := hot.candidates[key]; found || len(hot.candidates) < hot.size {
+
+		hot.candidates[key] = estimate
+
+	} else if estimate > hot.floor {
+
+		for candidate, count := range hot.candidates {
+
+			if count <= hot.floor {
+
+				delete(hot.candidates, candidate)
+
+				break
+			}
+
+		}
+
+		hot.candidates[key] = estimate
+
+	}
+
+	if len(hot.candidates) == hot.size {
+
+		hot.floor = ^uint32(0)
+
+		for _, count := range hot.candidates {
+
+			hot.floor = min(hot.floor, count)
+
+		}
+
+	}
+
+	if hot.samples++; hot.samples >= hot.resetAt {
+
+		hot.sketch.halve()
+
+		for candidate := range hot.candidates {
+
+			hot.candidates[candidate] >>= 1
+
+		}
+
+		hot.floor >>= 1
+
+		hot.samples /= 2
+
+	}
+}
+
+// top returns the candidates, most accessed first, at most n of them unless n
+// is 0.
+func (hot *hotKeys[K]) top(n int) []HotKey[K] {
+
+	if hot == nil {
+
+		return nil
+
+	}
+
+	hot.mu.Lock()
+
+	keys := make([]HotKey[K], 0, len(hot.candidates))
+
+	for key, count := range hot.candidates {
+
+		keys = append(keys, HotKey[K]{Key: key, Count: uint64(count)})
+
+	}
+
+	hot.mu.Unlock()
+
+	sortHotKeys(keys)
+
+	if n > 0 {
+
+		keys = keys[:min(n, len(keys))]
+
+	}
+
+	return keys
+}
+
+func sortHotKeys[K comparable](keys []HotKey[K]) {
+
+	slices.SortStableFunc(keys, func(a, b HotKey[K]) int {
+
+		switch {
+
+		case a.Count > b.Count:
+
+			return -1
+
+		case a.Count < b.Count:
+
+			return 1
+
+		}
+
+		return 0
+	})
+}
+
+// chiSquaredSurvival returns P(X >= x) for X chi-squared distributed with dof
+// degrees of freedom, the regularized upper incomplete gamma function
+// Q(dof/2, x/2), computed as in Numerical Recipes 6.2.
+func chiSquaredSurvival(x, dof float64) float64 {
+
+	a, x := dof/2, x/2
+
+	if x <= 0 {
+
+		return 1
+
+	}
+
+	gln, _ := math.Lgamma(a)
+
+	if x < a+1 {
+
+		// Series for the lower function P.
+		sum, term := 1/a, 1/a
+
+		for n := 1.0; n < 1000; n++ {
+
+			term *= x / (a + n)
+
+			sum += term
+
+			if math.Abs(term) < math.Abs(sum)*1e-15 {
+
+				break
+			}
+
+		}
+
+		return 1 - sum*math.Exp(-x+a*math.Log(x)-gln)
+	}
+
+	// Lentz's continued fraction for Q.
+	const tiny = 1e-300
+
+	b := x + 1 - a
+
+	c, d := 1/tiny, 1/b
+
+	h := d
+
+	for i := 1.0; i < 1000; i++ {
+
+		an := -i * (i - a)
+
+		b += 2
+
+		d = an*d + b
+
+		if math.Abs(d) < tiny {
+
+			d = tiny
+
+		}
+
+		c = b + an/c
+
+		if math.Abs(c) < tiny {
+
+			c = tiny
+
+		}
+
+		d = 1 / d
+
+		delta := d * c
+
+		h *= delta
+
+		if math.Abs(delta-1) < 1e-15 {
+
+			break
+		}
+
+	}
+
+	return math.Exp(-x+a*math.Log(x)-gln) * h
+}
@@ -0,0 +1,140 @@
+package src
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestMeasureUniformity(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	uniform := MeasureUniformity([]float64{100, 100, 100, 100})
+
+	assertions.Equal(0.0, uniform.ChiSquared)
+
+	assertions.Equal(1.0, uniform.PValue)
+
+	assertions.Equal(1.0, uniform.MaxRatio)
+
+	skewed := MeasureUniformity([]float64{400, 0, 0, 0})
+
+	assertions.Equal(1200.0, skewed.ChiSquared)
+
+	assertions.Less(skewed.PValue, 1e-6)
+
+	assertions.Equal(4.0, skewed.MaxRatio)
+
+	assertions.Equal(Uniformity{PValue: 1, MaxRatio: 1}, MeasureUniformity(nil))
+
+	assertions.Equal(Uniformity{PValue: 1, MaxRatio: 1}, MeasureUniformity([]float64{0, 0}))
+
+	// Reference values of the chi-squared survival function.
+	tests := []struct{ x, dof, p float64 }{
+
+		{3.841458820694124, 1, 0.05},
+
+		{18.307038053275146, 10, 0.05},
+
+		{10, 10, 0.44049328506521},
+
+		{2, 7, 0.95984036873010},
+
+		{135.80672317102676, 100, 0.01},
+	}
+
+	for _, test := range tests {
+
+		assertions.InDelta(test.p, chiSquaredSurvival(test.x, test.dof), 1e-9, "%v", test)
+
+	}
+
+	assertions.False(math.IsNaN(chiSquaredSurvival(1e6, 3)))
+}
+
+func TestAnalyzeSkew(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardMap := NewShardMapOf[string, int](8, WithHasher[string, int](CityHasher), WithMetrics[string, int](), WithHotKeys[string, int](4))
+
+	for i := 0; i < 8000; i++ {
+
+		shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+	}
+
+	for i := 0; i < 1000; i++ {
+
+		shardMap.Get("hot")
+
+		shardMap.Get("warm")
+
+		shardMap.Get("warm")
+
+		shardMap.Get(fmt.Sprintf("test%v", i))
+
+	}
+
+	report := shardMap.AnalyzeSkew()
+
+	assertions.Len(report.Shards, 8)
+
+	assertions.Greater(report.Sizes.PValue, 0.001)
+
+	assertions.Less(report.Sizes.MaxRatio, 1.2)
+
+	total := 0
+
+	for _, load := range report.Shards {
+
+		total += load.Entries
+
+		assertions.Positive(load.OpsPerSecond)
+
+		assertions.LessOrEqual(len(load.HotKeys), 4)
+
+	}
+
+	assertions.Equal(8000, total)
+
+	hotKeys := shardMap.HotKeys(2)
+
+	assertions.Equal([]string{"warm", "hot"}, []string{hotKeys[0].Key, hotKeys[1].Key})
+
+	assertions.GreaterOrEqual(hotKeys[0].Count, uint64(2000))
+
+	hotShard := report.Shards[shardMap.GetShardIndex("warm")]
+
+	assertions.Equal("warm", hotShard.HotKeys[0].Key)
+
+	t.Run("Skewed", func(t *testing.T) {
+
+		shardMap := NewShardMapOf[int, int](8, WithRouter[int, int](skewedRouter{}))
+
+		for i := 0; i < 1000; i++ {
+
+			shardMap.Set(i, i)
+
+		}
+
+		report := shardMap.AnalyzeSkew()
+
+		assert.Less(t, report.Sizes.PValue, 1e-6)
+
+		assert.Equal(t, 8.0, report.Sizes.MaxRatio)
+
+		assert.Nil(t, shardMap.HotKeys(10))
+
+	})
+}
+
+type skewedRouter struct{}
+
+func (skewedRouter) Route(hash uint64, numShards int) uint32 {
+
+	return 0
+
+}
@@ -0,0 +1,434 @@
+package src
+
+import (
+	"iter"
+	"maps"
+	"runtime"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot is an immutable view of a map at the instant Snapshot was called.
+// Taking one only marks the shards as shared; a shard is copied the next time
+// it is written to, so a snapshot costs one copy of every shard that changes
+// until it is released. TTLs are evaluated at the instant the snapshot was
+// taken.
+type Snapshot[K comparable, V any] struct {
+	shards []snapshotShard[K, V]
+
+	// previous holds the shards of the table a Resize was migrating out of, if
+	// one was in progress.
+	previous []snapshotShard[K, V]
+
+	// only is the shard of a SnapshotShard, or -1.
+	only int
+
+	hasher Hasher[K]
+
+	hasherID HasherID
+
+	router Router
+
+	keyCodec Codec[K]
+
+	valueCodec Codec[V]
+
+	takenAt int64
+
+	released atomic.Bool
+}
+
+type snapshotShard[K comparable, V any] struct {
+	items Backend[K, V]
+
+	expiries map[K]int64
+
+	owner *shard[K, V]
+
+	generation uint64
+}
+
+// Snapshot read locks every shard at once to capture a consistent cut of the
+// whole map, then lets writers continue right away. Call Release once the
+// snapshot is no longer needed; one that is dropped is only released when the
+// garbage collector gets to it.
+func (shardMap *ShardMap[K, V]) Snapshot() *Snapshot[K, V] {
+
+	snapshot, _ := shardMap.snapshot(-1, nil)
+
+	return snapshot
+}
+
+// SnapshotShard is Snapshot for a single shard: only that shard is copied on
+// write for it and the other shards of the snapshot are empty. While a Resize
+// is in progress the shards it migrates out of are captured as well, since they
+// still hold part of the entries of the shard.
+func (shardMap *ShardMap[K, V]) SnapshotShard(shardIndex int) (*Snapshot[K, V], error) {
+
+	return shardMap.snapshot(shardIndex, nil)
+
+}
+
+// Release lets the shards stop copying themselves on write for the snapshot.
+// The snapshot must not be used afterwards. Releasing twice is harmless.
+func (snapshot *Snapshot[K, V]) Release() {
+
+	runtime.SetFinalizer(snapshot, nil)
+
+	snapshot.release()
+
+}
+
+func (snapshot *Snapshot[K, V]) Get(key K) (value V, ok bool) {
+
+	defer runtime.KeepAlive(snapshot)
+
+	hash := snapshot.hasher(key)
+
+	shardIndex := snapshot.router.Route(hash, len(snapshot.shards))
+
+	if snapshot.only != -1 && shardIndex != uint32(snapshot.only) {
+
+		return value, false
+
+	}
+
+	if value, ok = snapshot.shards[shardIndex].get(key, snapshot.takenAt); ok || snapshot.previous == nil {
+
+		return value, ok
+
+	}
+
+	return snapshot.previous[snapshot.router.Route(hash, len(snapshot.previous))].get(key, snapshot.takenAt)
+}
+
+func (snapshot *Snapshot[K, V]) Contains(key K) bool {
+
+	_, ok := snapshot.Get(key)
+
+	return ok
+}
+
+func (snapshot *Snapshot[K, V]) Len() (size int) {
+
+	defer runtime.KeepAlive(snapshot)
+
+	if snapshot.only != -1 {
+
+		snapshot.Iter(func(key K, value V) bool {
+
+			size++
+
+			return false
+
+		})
+
+		return size
+	}
+
+	for _, shard := range snapshot.all() {
+
+		if len(shard.expiries) == 0 {
+
+			size += shard.items.Len()
+
+			continue
+		}
+
+		shard.iter(snapshot.takenAt, func(key K, value V) bool {
+
+			size++
+
+			return false
+
+		})
+
+	}
+
+	return size
+}
+
+// Iter visits every entry of the snapshot until callback returns true.
+func (snapshot *Snapshot[K, V]) Iter(callback func(key K, value V) bool) {
+
+	defer runtime.KeepAlive(snapshot)
+
+	if snapshot.only != -1 {
+
+		snapshot.IterShard(callback, snapshot.only)
+
+		return
+	}
+
+	for _, shard := range snapshot.all() {
+
+		if shard.iter(snapshot.takenAt, callback) {
+
+			return
+		}
+
+	}
+}
+
+// IterShard is the snapshot counterpart of ShardMap.IterShard, including -1 for
+// every shard.
+func (snapshot *Snapshot[K, V]) IterShard(callback func(key K, value V) bool, shardIndex int) error {
+
+	defer runtime.KeepAlive(snapshot)
+
+	if shardIndex > len(snapshot.shards)-1 || shardIndex < -1 {
+
+		return &ShardNotExistsError{Shard: shardIndex}
+
+	}
+
+	if shardIndex == -1 {
+
+		snapshot.Iter(callback)
+
+		return nil
+	}
+
+	if snapshot.only != -1 && shardIndex != snapshot.only {
+
+		return nil
+
+	}
+
+	for _, shard := range snapshot.previous {
+
+		stopped := shard.iter(snapshot.takenAt, func(key K, value V) bool {
+
+			return snapshot.router.Route(snapshot.hasher(key), len(snapshot.shards)) == uint32(shardIndex) && callback(key, value)
+
+		})
+
+		if stopped {
+
+			return nil
+		}
+
+	}
+
+	snapshot.shards[shardIndex].iter(snapshot.takenAt, callback)
+
+	return nil
+}
+
+func (snapshot *Snapshot[K, V]) All() iter.Seq2[K, V] {
+
+	return func(yield func(key K, value V) bool) {
+
+		snapshot.Iter(func(key K, value V) bool {
+
+			return !yield(key, value)
+
+		})
+
+	}
+}
+
+func (snapshot *Snapshot[K, V]) NumShards() int {
+
+	return len(snapshot.shards)
+
+}
+
+// Time returns the instant the snapshot was taken.
+func (snapshot *Snapshot[K, V]) Time() time.Time {
+
+	return time.Unix(0, snapshot.takenAt)
+
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+// snapshot captures the shard at shardIndex of the current table, or every
+// shard for -1, and calls locked, if not nil, while they are still locked.
+func (shardMap *ShardMap[K, V]) snapshot(shardIndex int, locked func()) (*Snapshot[K, V], error) {
+
+	shardMap.resizeMu.RLock()
+
+	defer shardMap.resizeMu.RUnlock()
+
+	table := shardMap.table.Load()
+
+	if shardIndex > len(table.shards)-1 || shardIndex < -1 {
+
+		return nil, &ShardNotExistsError{Shard: shardIndex}
+
+	}
+
+	// The previous table is locked first, the order in which Resize locks.
+	shards := table.all()
+
+	if shardIndex != -1 {
+
+		shards = append(slices.Clip(table.previous), table.shards[shardIndex])
+
+	}
+
+	for _, shard := range shards {
+
+		shard.RLock()
+
+	}
+
+	snapshot := &Snapshot[K, V]{
+
+		shards: snapshotShards(table.shards, shardIndex),
+
+		previous: snapshotShards(table.previous, -1),
+
+		only: shardIndex,
+
+		hasher: shardMap.hasher,
+
+		hasherID: shardMap.options.hasherID,
+
+		router: shardMap.router,
+
+		keyCodec: shardMap.options.keyCodec,
+
+		valueCodec: shardMap.options.valueCodec,
+
+		takenAt: nowNano(),
+	}
+
+	if locked != nil {
+
+		locked()
+
+	}
+
+	for _, shard := range shards {
+
+		shard.RUnlock()
+
+	}
+
+	runtime.SetFinalizer(snapshot, (*Snapshot[K, V]).release)
+
+	return snapshot, nil
+}
+
+// snapshotShards captures the shard at only, or every shard for -1, and must be
+// called with those read locked. The others are left empty.
+func snapshotShards[K comparable, V any](shards []*shard[K, V], only int) []snapshotShard[K, V] {
+
+	if shards == nil {
+
+		return nil
+
+	}
+
+	snapshotShards := make([]snapshotShard[K, V], len(shards))
+
+	for shardIndex, shard := range shards {
+
+		if only != -1 && shardIndex != only {
+
+			snapshotShards[shardIndex] = snapshotShard[K, V]{items: mapBackend[K, V](nil)}
+
+			continue
+		}
+
+		shard.snapshots.Add(1)
+
+		snapshotShards[shardIndex] = snapshotShard[K, V]{items: shard.items, expiries: shard.expiries, owner: shard, generation: shard.generation}
+
+	}
+
+	return snapshotShards
+}
+
+func (snapshot *Snapshot[K, V]) release() {
+
+	if snapshot.released.Swap(true) {
+
+		return
+	}
+
+	for _, shard := range snapshot.all() {
+
+		owner := shard.owner
+
+		if owner == nil {
+
+			continue
+		}
+
+		owner.RLock()
+
+		if owner.generation == shard.generation {
+
+			owner.snapshots.Add(-1)
+
+		}
+
+		owner.RUnlock()
+
+	}
+}
+
+func (snapshot *Snapshot[K, V]) all() []snapshotShard[K, V] {
+
+	return append(append(make([]snapshotShard[K, V], 0, len(snapshot.previous)+len(snapshot.shards)), snapshot.previous...), snapshot.shards...)
+
+}
+
+func (shard snapshotShard[K, V]) get(key K, now int64) (value V, ok bool) {
+
+	if value, ok = shard.items.Get(key); ok {
+
+		if deadline, found := shard.expiries[key]; found && deadline <= now {
+
+			var zero V
+
+			return zero, false
+
+		}
+
+	}
+
+	return value, ok
+}
+
+func (shard snapshotShard[K, V]) iter(now int64, callback func(key K, value V) bool) (stopped bool) {
+
+	shard.items.Iter(func(key K, value V) bool {
+
+		if deadline, found := shard.expiries[key]; found && deadline <= now {
+
+			return false
+
+		}
+
+		stopped = callback(key, value)
+
+		return stopped
+
+	})
+
+	return stopped
+}
+
+// own copies items and expiries if a Snapshot still references them. It must be
+// called with the write lock held before either is changed.
+func (shard *shard[K, V]) own() {
+
+	if shard.snapshots.Load() == 0 {
+
+		return
+	}
+
+	shard.items = shard.items.Clone()
+
+	shard.expiries = maps.Clone(shard.expiries)
+
+	shard.generation++
+
+	shard.snapshots.Store(0)
+
+}
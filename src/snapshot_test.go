@@ -0,0 +1,402 @@
+package src
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(8)
+
+			for i := 0; i < 100; i++ {
+
+				shardedMap.Set(fmt.Sprintf("test%v", i), i)
+
+			}
+
+			snapshot := shardedMap.Snapshot()
+
+			shardedMap.Set("test0", 1000)
+
+			shardedMap.Set("new", 1)
+
+			shardedMap.Remove("test1")
+
+			assertions.Equal(100, snapshot.Len())
+
+			assertions.Equal(8, snapshot.NumShards())
+
+			value, ok := snapshot.Get("test0")
+
+			assertions.True(ok)
+
+			assertions.Equal(0, value)
+
+			assertions.True(snapshot.Contains("test1"))
+
+			assertions.False(snapshot.Contains("new"))
+
+			shardedMap.RemoveAll()
+
+			sum := 0
+
+			for _, value := range snapshot.All() {
+
+				sum += value
+
+			}
+
+			assertions.Equal(4950, sum)
+
+			total := 0
+
+			for shardIndex := 0; shardIndex < snapshot.NumShards(); shardIndex++ {
+
+				assertions.NoError(snapshot.IterShard(func(key string, value int) bool {
+
+					assertions.Equal(uint32(shardIndex), shardedMap.GetShardIndex(key))
+
+					total++
+
+					return false
+
+				}, shardIndex))
+
+			}
+
+			assertions.Equal(100, total)
+
+			assertions.Error(snapshot.IterShard(func(key string, value int) bool { return false }, 8))
+
+			assertions.Zero(shardedMap.Len())
+
+		})
+
+	}
+}
+
+func TestSnapshotTTL(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	advance := fakeClock(t)
+
+	shardMap := NewShardMap(4)
+
+	shardMap.SetWithTTL("expiring", 1, time.Second)
+
+	shardMap.SetWithTTL("expired", 1, time.Millisecond)
+
+	shardMap.Set("test", 1)
+
+	advance(time.Millisecond)
+
+	snapshot := shardMap.Snapshot()
+
+	shardMap.Persist("expiring")
+
+	advance(time.Hour)
+
+	assertions.Equal(2, snapshot.Len())
+
+	assertions.True(snapshot.Contains("expiring"))
+
+	assertions.False(snapshot.Contains("expired"))
+
+	assertions.True(shardMap.Contains("expiring"))
+
+}
+
+func TestSnapshotDuringResize(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardMap := NewShardMap(4)
+
+	for i := 0; i < 100; i++ {
+
+		shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+	}
+
+	table := &shardTable[string, int]{shards: newShards(shardMap.options, 8), previous: shardMap.currentShards()}
+
+	shardMap.table.Store(table)
+
+	for i := 0; i < 50; i++ {
+
+		shardMap.Get(fmt.Sprintf("test%v", i))
+
+	}
+
+	snapshot := shardMap.Snapshot()
+
+	shardMap.migrate(table, &migration{done: make(chan struct{})})
+
+	assertions.Equal(100, snapshot.Len())
+
+	for i := 0; i < 100; i++ {
+
+		value, ok := snapshot.Get(fmt.Sprintf("test%v", i))
+
+		assertions.True(ok)
+
+		assertions.Equal(i, value)
+
+	}
+
+	visited := 0
+
+	for shardIndex := 0; shardIndex < 8; shardIndex++ {
+
+		snapshot.IterShard(func(key string, value int) bool {
+
+			visited++
+
+			return false
+
+		}, shardIndex)
+
+	}
+
+	assertions.Equal(100, visited)
+
+}
+
+func TestSnapshotConcurrent(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardMap := NewShardMap(8)
+
+	for i := 0; i < 1000; i++ {
+
+		shardMap.Set(fmt.Sprintf("test%v", i), 0)
+
+	}
+
+	var wg sync.WaitGroup
+
+	for worker := 0; worker < 4; worker++ {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			for round := 0; round < 5; round++ {
+
+				for i := 0; i < 1000; i++ {
+
+					Incr(shardMap, fmt.Sprintf("test%v", i))
+
+				}
+
+			}
+
+		}()
+
+	}
+
+	// Every worker increments test0 before test999 in each round, so a
+	// consistent cut never sees test999 ahead of test0.
+	for round := 0; round < 20; round++ {
+
+		snapshot := shardMap.Snapshot()
+
+		assertions.Equal(1000, snapshot.Len())
+
+		first, _ := snapshot.Get("test0")
+
+		last, _ := snapshot.Get("test999")
+
+		assertions.LessOrEqual(last, first)
+
+	}
+
+	wg.Wait()
+
+	assertions.Equal(20000, Sum(shardMap))
+
+}
+
+func TestSnapshotRelease(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardMap := NewShardMap(1)
+
+	shard := shardMap.currentShards()[0]
+
+	shardMap.Set("test", 1)
+
+	first, second := shardMap.Snapshot(), shardMap.Snapshot()
+
+	assertions.Equal(int32(2), shard.snapshots.Load())
+
+	first.Release()
+
+	first.Release()
+
+	assertions.Equal(int32(1), shard.snapshots.Load())
+
+	// The write copies the shard for second, whose release then concerns a
+	// generation the shard no longer has.
+	shardMap.Set("test", 2)
+
+	assertions.Zero(shard.snapshots.Load())
+
+	value, _ := second.Get("test")
+
+	assertions.Equal(1, value)
+
+	third := shardMap.Snapshot()
+
+	second.Release()
+
+	assertions.Equal(int32(1), shard.snapshots.Load())
+
+	third.Release()
+
+	generation := shard.generation
+
+	shardMap.Set("test", 3)
+
+	assertions.Equal(generation, shard.generation)
+
+	t.Run("Collected", func(t *testing.T) {
+
+		shardMap.Snapshot()
+
+		assertions.Eventually(func() bool {
+
+			runtime.GC()
+
+			return shard.snapshots.Load() == 0
+
+		}, time.Second, 10*time.Millisecond)
+
+	})
+
+}
+
+func TestSnapshotShard(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	shardMap := NewShardMap(4)
+
+	for i := 0; i < 1000; i++ {
+
+		shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+	}
+
+	expected := map[string]int{}
+
+	shardMap.IterShard(func(key string, value int) bool {
+
+		expected[key] = value
+
+		return false
+
+	}, 1)
+
+	snapshot, err := shardMap.SnapshotShard(1)
+
+	assertions.NoError(err)
+
+	shards := shardMap.currentShards()
+
+	for shardIndex, shard := range shards {
+
+		assertions.Equal(shardIndex == 1, shard.snapshots.Load() == 1, shardIndex)
+
+	}
+
+	shardMap.RemoveAll()
+
+	entries := map[string]int{}
+
+	assertions.NoError(snapshot.IterShard(func(key string, value int) bool {
+
+		entries[key] = value
+
+		return false
+
+	}, 1))
+
+	assertions.Equal(expected, entries)
+
+	assertions.Equal(len(expected), snapshot.Len())
+
+	snapshot.Release()
+
+	for _, shard := range shards {
+
+		assertions.Zero(shard.snapshots.Load())
+
+	}
+
+	_, err = shardMap.SnapshotShard(4)
+
+	var notExists *ShardNotExistsError
+
+	assertions.ErrorAs(err, &notExists)
+
+	t.Run("Resizing", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(2)
+
+		for i := 0; i < 1000; i++ {
+
+			shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+		}
+
+		assertions.NoError(shardMap.Resize(5))
+
+		snapshot, err := shardMap.SnapshotShard(3)
+
+		assertions.NoError(err)
+
+		defer snapshot.Release()
+
+		count := 0
+
+		shardMap.IterShard(func(key string, value int) bool {
+
+			count++
+
+			return false
+
+		}, 3)
+
+		assertions.Equal(count, snapshot.Len())
+
+		for i := 0; i < 1000; i++ {
+
+			key := fmt.Sprintf("test%v", i)
+
+			_, ok := snapshot.Get(key)
+
+			assertions.Equal(shardMap.GetShardIndex(key) == 3, ok, key)
+
+		}
+
+	})
+}
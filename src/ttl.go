@@ -0,0 +1,307 @@
+package src
+
+import (
+	"math"
+	"time"
+)
+
+// NoExpiration is the TTL reported for keys that never expire.
+const NoExpiration time.Duration = -1
+
+var nowNano = func() int64 {
+
+	return time.Now().UnixNano()
+
+}
+
+type janitor struct {
+	done chan struct{}
+
+	stopped chan struct{}
+}
+
+// SetWithTTL stores value under key for ttl. A ttl <= 0 stores it without
+// expiration like Set. Expired entries are no longer visible, but they only free
+// their memory once overwritten, swept by RemoveExpired or by the janitor.
+func (shardMap *ShardMap[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+
+	shard := shardMap.lockShard(key)
+
+	shard.putUntil(key, value, deadlineAfter(ttl))
+
+	shard.Unlock()
+
+}
+
+// Expire sets the TTL of an existing key. A ttl <= 0 removes the key right
+// away. It reports whether key was present.
+func (shardMap *ShardMap[K, V]) Expire(key K, ttl time.Duration) bool {
+
+	shard := shardMap.lockShard(key)
+
+	defer shard.Unlock()
+
+	if _, ok := shard.get(key); !ok {
+
+		return false
+
+	}
+
+	if ttl <= 0 {
+
+		shard.delete(key)
+
+	} else {
+
+		shard.setExpiry(key, ttl)
+
+	}
+
+	return true
+}
+
+// TTL returns the time key has left to live, or NoExpiration if it has no TTL.
+// ok is false if key is not present.
+func (shardMap *ShardMap[K, V]) TTL(key K) (ttl time.Duration, ok bool) {
+
+	shard := shardMap.rlockShard(key)
+
+	defer shard.RUnlock()
+
+	if _, ok = shard.get(key); !ok {
+
+		return 0, false
+
+	}
+
+	deadline, found := shard.expiries[key]
+
+	if !found {
+
+		return NoExpiration, true
+
+	}
+
+	return time.Duration(deadline - nowNano()), true
+}
+
+// Persist removes the TTL of key and reports whether it had one.
+func (shardMap *ShardMap[K, V]) Persist(key K) bool {
+
+	shard := shardMap.lockShard(key)
+
+	defer shard.Unlock()
+
+	if _, ok := shard.get(key); !ok {
+
+		return false
+
+	}
+
+	_, found := shard.expiries[key]
+
+	if found {
+
+		shard.own()
+
+		delete(shard.expiries, key)
+
+		shard.log.expire(key, 0)
+
+	}
+
+	return found
+}
+
+// RemoveExpired sweeps the shards one at a time and removes every expired entry.
+// It returns the number of entries removed.
+func (shardMap *ShardMap[K, V]) RemoveExpired() (removed int) {
+
+	shardMap.resizeMu.RLock()
+
+	defer shardMap.resizeMu.RUnlock()
+
+	for _, shard := range shardMap.allShards() {
+
+		shard.Lock()
+
+		removed += shard.removeExpired(nowNano())
+
+		shard.Unlock()
+
+	}
+
+	return removed
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func (shard *shard[K, V]) expired(key K, now int64) bool {
+
+	deadline, found := shard.expiries[key]
+
+	return found && deadline <= now
+}
+
+func (shard *shard[K, V]) setExpiry(key K, ttl time.Duration) {
+
+	if ttl <= 0 {
+
+		return
+
+	}
+
+	shard.setDeadline(key, deadlineAfter(ttl))
+
+}
+
+// deadlineAfter returns the deadline ttl from now, or 0, no deadline, for a ttl
+// <= 0. It saturates at math.MaxInt64 so that a TTL that long never wraps around
+// into the past.
+func deadlineAfter(ttl time.Duration) int64 {
+
+	if ttl <= 0 {
+
+		return 0
+
+	}
+
+	now := nowNano()
+
+	if int64(ttl) > math.MaxInt64-now {
+
+		return math.MaxInt64
+
+	}
+
+	return now + int64(ttl)
+}
+
+func (shard *shard[K, V]) setDeadline(key K, deadline int64) {
+
+	shard.own()
+
+	if shard.expiries == nil {
+
+		shard.expiries = make(map[K]int64)
+
+	}
+
+	shard.expiries[key] = deadline
+
+	shard.log.expire(key, deadline)
+
+}
+
+// update stores a new value for a key while keeping its TTL. A key that was not
+// loaded, because it was missing or expired, starts over without one.
+func (shard *shard[K, V]) update(key K, value V, loaded bool) (stored bool) {
+
+	if !loaded {
+
+		return shard.put(key, value)
+
+	}
+
+	event := Event[K, V]{Type: EventSet, Key: key, Value: value}
+
+	if shard.watchers.active() {
+
+		event.OldValue, event.HasOld = shard.get(key)
+
+	}
+
+	shard.own()
+
+	if !shard.makeBytes(key, value) || !shard.store(key, value) {
+
+		shard.refuse(key, value)
+
+		return false
+	}
+
+	shard.touch(key)
+
+	shard.invalidateLoad(key)
+
+	shard.log.set(key, value, shard.expiries[key])
+
+	shard.metrics.set()
+
+	shard.watchers.publish(event)
+
+	return true
+}
+
+func (shard *shard[K, V]) removeExpired(now int64) (removed int) {
+
+	for key, deadline := range shard.expiries {
+
+		if deadline <= now {
+
+			shard.delete(key)
+
+			removed++
+
+		}
+
+	}
+
+	for key, failure := range shard.failures {
+
+		if failure.deadline <= now {
+
+			delete(shard.failures, key)
+
+		}
+
+	}
+
+	return removed
+}
+
+func startJanitor(interval time.Duration, sweep func() int) *janitor {
+
+	janitor := &janitor{
+
+		done: make(chan struct{}),
+
+		stopped: make(chan struct{}),
+	}
+
+	go func() {
+
+		defer close(janitor.stopped)
+
+		ticker := time.NewTicker(interval)
+
+		defer ticker.Stop()
+
+		for {
+
+			select {
+
+			case <-ticker.C:
+
+				sweep()
+
+			case <-janitor.done:
+
+				return
+
+			}
+
+		}
+
+	}()
+
+	return janitor
+}
+
+func (janitor *janitor) stop() {
+
+	close(janitor.done)
+
+	<-janitor.stopped
+
+}
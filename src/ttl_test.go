@@ -0,0 +1,248 @@
+package src
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetWithTTL(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			advance := fakeClock(t)
+
+			shardedMap := newMap(4)
+
+			shardedMap.SetWithTTL("test", 1, time.Second)
+
+			shardedMap.SetWithTTL("test2", 2, 0)
+
+			value, ok := shardedMap.Get("test")
+
+			assertions.True(ok)
+
+			assertions.Equal(1, value)
+
+			ttl, ok := shardedMap.TTL("test")
+
+			assertions.True(ok)
+
+			assertions.Equal(time.Second, ttl)
+
+			ttl, ok = shardedMap.TTL("test2")
+
+			assertions.True(ok)
+
+			assertions.Equal(NoExpiration, ttl)
+
+			advance(time.Second)
+
+			_, ok = shardedMap.Get("test")
+
+			assertions.False(ok)
+
+			assertions.False(shardedMap.Contains("test"))
+
+			_, ok = shardedMap.TTL("test")
+
+			assertions.False(ok)
+
+			assertions.True(shardedMap.Contains("test2"))
+
+			shardedMap.SetWithTTL("forever", 3, math.MaxInt64)
+
+			advance(time.Hour)
+
+			assertions.True(shardedMap.Contains("forever"))
+
+			ttl, ok = shardedMap.TTL("forever")
+
+			assertions.True(ok)
+
+			assertions.Greater(ttl, time.Hour)
+
+		})
+
+	}
+}
+
+func TestTTLWrites(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	advance := fakeClock(t)
+
+	shardMap := NewShardMap(4)
+
+	t.Run("SetClearsTTL", func(t *testing.T) {
+
+		shardMap.SetWithTTL("test", 1, time.Second)
+
+		shardMap.Set("test", 2)
+
+		ttl, _ := shardMap.TTL("test")
+
+		assertions.Equal(NoExpiration, ttl)
+
+	})
+
+	t.Run("ComputeKeepsTTL", func(t *testing.T) {
+
+		shardMap.SetWithTTL("test", 1, time.Second)
+
+		assertions.Equal(2, Incr(shardMap, "test"))
+
+		ttl, _ := shardMap.TTL("test")
+
+		assertions.Equal(time.Second, ttl)
+
+		advance(time.Second)
+
+		assertions.Equal(1, Incr(shardMap, "test"))
+
+		ttl, _ = shardMap.TTL("test")
+
+		assertions.Equal(NoExpiration, ttl)
+
+	})
+
+	t.Run("Persist", func(t *testing.T) {
+
+		shardMap.SetWithTTL("test", 1, time.Second)
+
+		assertions.True(shardMap.Persist("test"))
+
+		assertions.False(shardMap.Persist("test"))
+
+		assertions.False(shardMap.Persist("test10"))
+
+		advance(time.Second)
+
+		assertions.True(shardMap.Contains("test"))
+
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+
+		assertions.False(shardMap.Expire("test10", time.Second))
+
+		assertions.True(shardMap.Expire("test", time.Second))
+
+		ttl, _ := shardMap.TTL("test")
+
+		assertions.Equal(time.Second, ttl)
+
+		assertions.True(shardMap.Expire("test", 0))
+
+		assertions.False(shardMap.Contains("test"))
+
+	})
+
+}
+
+func TestTTLIterAndRemoveExpired(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	advance := fakeClock(t)
+
+	shardMap := NewShardMap(4)
+
+	for i := 0; i < 10; i++ {
+
+		shardMap.SetWithTTL(fmt.Sprintf("test%v", i), i, time.Duration(i+1)*time.Second)
+
+	}
+
+	advance(5 * time.Second)
+
+	visited := 0
+
+	shardMap.Iter(func(key string, value int) bool {
+
+		assertions.GreaterOrEqual(value, 5)
+
+		visited++
+
+		return false
+
+	})
+
+	assertions.Equal(5, visited)
+
+	assertions.Equal(10, shardMap.Len())
+
+	assertions.Equal(5, shardMap.RemoveExpired())
+
+	assertions.Equal(5, shardMap.Len())
+
+	assertions.Zero(shardMap.RemoveExpired())
+
+}
+
+func TestWithJanitor(t *testing.T) {
+
+	assertions := assert.New(t)
+
+	advance := fakeClock(t)
+
+	shardMap := NewShardMapOf[string, int](4, WithJanitor[string, int](time.Millisecond))
+
+	defer shardMap.Close()
+
+	for i := 0; i < 10; i++ {
+
+		shardMap.SetWithTTL(fmt.Sprintf("test%v", i), i, time.Second)
+
+	}
+
+	shardMap.Set("test", 1)
+
+	advance(time.Second)
+
+	assertions.Eventually(func() bool {
+
+		return shardMap.Len() == 1
+
+	}, time.Second, time.Millisecond)
+
+	assertions.NoError(shardMap.Close())
+
+	assertions.NoError(shardMap.Close())
+
+}
+
+//-----------------------------------------------------Helper Functions-----------------------------------------------
+
+// fakeClock freezes the clock used for TTLs for the duration of the test and
+// returns a function that moves it forward.
+func fakeClock(t *testing.T) func(time.Duration) {
+
+	var now atomic.Int64
+
+	now.Store(time.Now().UnixNano())
+
+	previous := nowNano
+
+	nowNano = now.Load
+
+	t.Cleanup(func() {
+
+		nowNano = previous
+
+	})
+
+	return func(duration time.Duration) {
+
+		now.Add(int64(duration))
+
+	}
+}
@@ -0,0 +1,925 @@
+package src
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SyncPolicy controls when the write-ahead log is flushed to stable storage.
+type SyncPolicy time.Duration
+
+// A WAL segment starts with the magic "SHWL" and a version byte followed by
+// records, each one
+//
+//	payload length uvarint | payload | crc32c u32
+//
+// where the payload is a record type byte followed by its fields: key, value
+// and deadline for a set, key and deadline for an expire, key for a remove and
+// nothing for a clear. Deadlines are unix nanoseconds as a uvarint, 0 for none.
+const (
+	// SyncAlways fsyncs every record before the write that produced it returns.
+	// It is the default.
+	SyncAlways SyncPolicy = 0
+
+	// SyncNever hands every record to the operating system right away but never
+	// fsyncs, so only a crash of the machine loses writes.
+	SyncNever SyncPolicy = -1
+
+	walMagic = "SHWL"
+
+	walVersion = 1
+
+	maxWALRecord = 1 << 30
+
+	walReadChunk = 1 << 20
+)
+
+const (
+	walSet byte = iota + 1
+
+	walRemove
+
+	walExpire
+
+	walClear
+)
+
+var (
+	ErrorNoWAL = errors.New("map has no write-ahead log, see OpenShardMap")
+
+	ErrorWALClosed = errors.New("write-ahead log is closed")
+
+	ErrorCorruptWAL = errors.New("corrupt write-ahead log")
+)
+
+// writeAheadLog appends every mutation of a map opened by OpenShardMap to the
+// current segment of its directory. Records are appended under the lock of the
+// shard that is written, so their order in the log is the order the writes
+// happened in.
+type writeAheadLog[K comparable, V any] struct {
+	mu sync.Mutex
+
+	dir string
+
+	policy SyncPolicy
+
+	keyCodec Codec[K]
+
+	valueCodec Codec[V]
+
+	file *os.File
+
+	writer *bufio.Writer
+
+	segment uint64
+
+	size int64
+
+	dirty bool
+
+	closed bool
+
+	err error
+
+	buffer []byte
+
+	// compactAt triggers a background compaction once the current segment
+	// grows past it. compacting ensures only one runs at a time.
+	compactAt int64
+
+	compact func() error
+
+	compacting atomic.Bool
+
+	compactMu sync.Mutex
+
+	done chan struct{}
+
+	stopped chan struct{}
+}
+
+// SyncEvery fsyncs the write-ahead log every interval. Writes are buffered in
+// between, so a crash loses at most interval worth of writes. An interval <= 0
+// is SyncAlways.
+func SyncEvery(interval time.Duration) SyncPolicy {
+
+	return SyncPolicy(max(interval, 0))
+
+}
+
+// OpenShardMap opens the durable map kept in dir, creating it if needed. It
+// loads the latest snapshot written by Compact, replays the write-ahead log on
+// top of it and then logs every write to the map from Set, Remove and RemoveAll
+// down to TTL changes and evictions. The map needs codecs, see WithCodecs; its
+// shard count may differ from the one the directory was written with.
+func OpenShardMap[K comparable, V any](dir string, numShards int, opts ...Option[K, V]) (*ShardMap[K, V], error) {
+
+	shardMap := NewShardMapOf(numShards, opts...)
+
+	if err := shardMap.openWAL(dir); err != nil {
+
+		shardMap.Close()
+
+		return nil, err
+
+	}
+
+	return shardMap, nil
+}
+
+func OpenShardSwissMap[K comparable, V any](dir string, numShards int, opts ...Option[K, V]) (*ShardSwissMap[K, V], error) {
+
+	shardMap, err := OpenShardMap(dir, numShards, append(opts[:len(opts):len(opts)], WithBackend(SwissBackend[K, V]))...)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	return &ShardSwissMap[K, V]{shardMap}, nil
+}
+
+// Compact snapshots the map into its directory and deletes the log segments and
+// snapshots the new snapshot supersedes. Writers are only blocked while the
+// snapshot is taken and the log switches to a new segment.
+func (shardMap *ShardMap[K, V]) Compact() error {
+
+	log := shardMap.options.log
+
+	if log == nil {
+
+		return ErrorNoWAL
+
+	}
+
+	log.compactMu.Lock()
+
+	defer log.compactMu.Unlock()
+
+	var segment uint64
+
+	var err error
+
+	snapshot, _ := shardMap.snapshot(-1, func() {
+
+		segment, err = log.rotate()
+
+	})
+
+	defer snapshot.Release()
+
+	if err != nil {
+
+		return err
+
+	}
+
+	if err = writeFileAtomic(snapshotPath(log.dir, segment), snapshot.SaveTo); err != nil {
+
+		return err
+
+	}
+
+	return log.removeBefore(segment)
+}
+
+// Sync flushes the write-ahead log to stable storage regardless of its
+// SyncPolicy and returns the first error the log ran into, if any.
+func (shardMap *ShardMap[K, V]) Sync() error {
+
+	log := shardMap.options.log
+
+	if log == nil {
+
+		return ErrorNoWAL
+
+	}
+
+	log.mu.Lock()
+
+	defer log.mu.Unlock()
+
+	log.sync()
+
+	return log.err
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func (shardMap *ShardMap[K, V]) openWAL(dir string) error {
+
+	options := shardMap.options
+
+	if options.keyCodec == nil || options.valueCodec == nil {
+
+		return ErrorNoCodec
+
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+
+		return err
+
+	}
+
+	snapshots, segments, err := listWAL(dir)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	var base uint64
+
+	if len(snapshots) > 0 {
+
+		base = snapshots[len(snapshots)-1]
+
+		if err = shardMap.LoadFile(snapshotPath(dir, base)); err != nil {
+
+			return fmt.Errorf("load snapshot: %w", err)
+
+		}
+
+	}
+
+	segments = slices.DeleteFunc(segments, func(segment uint64) bool { return segment < base })
+
+	next := base
+
+	for i, segment := range segments {
+
+		if err = shardMap.replay(segmentPath(dir, segment), i == len(segments)-1); err != nil {
+
+			return fmt.Errorf("replay segment %v: %w", segment, err)
+
+		}
+
+		next = segment + 1
+
+	}
+
+	log := &writeAheadLog[K, V]{
+
+		dir: dir,
+
+		policy: options.syncPolicy,
+
+		keyCodec: options.keyCodec,
+
+		valueCodec: options.valueCodec,
+
+		compactAt: options.compactAt,
+
+		compact: shardMap.Compact,
+	}
+
+	if err = log.open(next); err != nil {
+
+		return err
+
+	}
+
+	if log.policy > 0 {
+
+		log.done, log.stopped = make(chan struct{}), make(chan struct{})
+
+		go log.run()
+
+	}
+
+	// Nothing else can see the map yet, so its shards are attached without
+	// locking. Shards created by a later Resize pick the log up from options.
+	options.log = log
+
+	for _, shard := range shardMap.allShards() {
+
+		shard.log = log
+
+	}
+
+	return nil
+}
+
+// replay applies the records of a segment to the map. A torn record or one
+// failing its checksum at the end of the last segment, or a torn header, is
+// what a crash in the middle of an append leaves behind, so it is cut off;
+// anywhere else it is an error. Records that cannot be applied are always an
+// error.
+func (shardMap *ShardMap[K, V]) replay(path string, last bool) error {
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	header := make([]byte, len(walMagic)+1)
+
+	if _, err = io.ReadFull(reader, header); err != nil || string(header[:len(walMagic)]) != walMagic || header[len(walMagic)] != walVersion {
+
+		if last && err != nil {
+
+			return os.Remove(path)
+
+		}
+
+		return ErrorCorruptWAL
+
+	}
+
+	offset := int64(len(header))
+
+	for {
+
+		payload, n, err := readWALRecord(reader)
+
+		if err == io.EOF {
+
+			return nil
+		}
+
+		if err != nil {
+
+			if !last {
+
+				return err
+
+			}
+
+			return file.Truncate(offset)
+		}
+
+		// A record that passed its checksum was written whole, so failing to
+		// apply it is not a torn append and must not cost the records after it.
+		if err = shardMap.apply(payload); err != nil {
+
+			return err
+
+		}
+
+		offset += n
+
+	}
+}
+
+func readWALRecord(reader *bufio.Reader) (payload []byte, n int64, err error) {
+
+	length, err := binary.ReadUvarint(reader)
+
+	if err == io.EOF {
+
+		return nil, 0, io.EOF
+
+	}
+
+	if err != nil || length > maxWALRecord {
+
+		return nil, 0, ErrorCorruptWAL
+
+	}
+
+	// The record grows as it is read, so that a corrupt length fails on the end
+	// of the segment before its memory is committed.
+	record := bytes.NewBuffer(make([]byte, 0, min(length+4, walReadChunk)))
+
+	if _, err = io.CopyN(record, reader, int64(length)+4); err != nil {
+
+		return nil, 0, ErrorCorruptWAL
+
+	}
+
+	payload = record.Bytes()[:length]
+
+	if binary.LittleEndian.Uint32(record.Bytes()[length:]) != crc32.Checksum(payload, castagnoli) {
+
+		return nil, 0, ErrorCorruptWAL
+
+	}
+
+	return payload, int64(len(binary.AppendUvarint(nil, length)) + record.Len()), nil
+}
+
+func (shardMap *ShardMap[K, V]) apply(payload []byte) error {
+
+	keyCodec, valueCodec := shardMap.options.keyCodec, shardMap.options.valueCodec
+
+	if len(payload) == 0 {
+
+		return ErrorCorruptWAL
+
+	}
+
+	kind, payload := payload[0], payload[1:]
+
+	if kind == walClear {
+
+		shardMap.RemoveAll()
+
+		return nil
+	}
+
+	key, n, err := keyCodec.Decode(payload)
+
+	if err != nil {
+
+		return ErrorCorruptWAL
+
+	}
+
+	payload = payload[n:]
+
+	switch kind {
+
+	case walRemove:
+
+		shardMap.Remove(key)
+
+	case walSet:
+
+		value, n, err := valueCodec.Decode(payload)
+
+		if err != nil {
+
+			return ErrorCorruptWAL
+
+		}
+
+		deadline, m := binary.Uvarint(payload[n:])
+
+		if m <= 0 {
+
+			return ErrorCorruptWAL
+
+		}
+
+		if deadline == 0 {
+
+			shardMap.Set(key, value)
+
+		} else if int64(deadline) > nowNano() {
+
+			shardMap.setWithDeadline(key, value, int64(deadline))
+
+		} else {
+
+			shardMap.Remove(key)
+
+		}
+
+	case walExpire:
+
+		deadline, m := binary.Uvarint(payload)
+
+		if m <= 0 {
+
+			return ErrorCorruptWAL
+
+		}
+
+		shard := shardMap.lockShard(key)
+
+		if _, ok := shard.get(key); ok {
+
+			if deadline == 0 {
+
+				shard.own()
+
+				delete(shard.expiries, key)
+
+			} else if int64(deadline) > nowNano() {
+
+				shard.setDeadline(key, int64(deadline))
+
+			} else {
+
+				shard.delete(key)
+
+			}
+
+		}
+
+		shard.Unlock()
+
+	default:
+
+		return ErrorCorruptWAL
+
+	}
+
+	return nil
+}
+
+// set, remove, expire and clear are called with the lock of the shard they
+// describe held, or every shard lock for clear. They do nothing on a nil log.
+func (log *writeAheadLog[K, V]) set(key K, value V, deadline int64) {
+
+	if log == nil {
+
+		return
+
+	}
+
+	log.mu.Lock()
+
+	defer log.mu.Unlock()
+
+	buffer := append(log.buffer[:0], walSet)
+
+	buffer = log.keyCodec.Append(buffer, key)
+
+	buffer = log.valueCodec.Append(buffer, value)
+
+	log.append(binary.AppendUvarint(buffer, uint64(max(deadline, 0))))
+
+}
+
+func (log *writeAheadLog[K, V]) remove(key K) {
+
+	if log == nil {
+
+		return
+
+	}
+
+	log.mu.Lock()
+
+	defer log.mu.Unlock()
+
+	log.append(log.keyCodec.Append(append(log.buffer[:0], walRemove), key))
+
+}
+
+func (log *writeAheadLog[K, V]) expire(key K, deadline int64) {
+
+	if log == nil {
+
+		return
+
+	}
+
+	log.mu.Lock()
+
+	defer log.mu.Unlock()
+
+	buffer := log.keyCodec.Append(append(log.buffer[:0], walExpire), key)
+
+	log.append(binary.AppendUvarint(buffer, uint64(max(deadline, 0))))
+
+}
+
+func (log *writeAheadLog[K, V]) clear() {
+
+	if log == nil {
+
+		return
+
+	}
+
+	log.mu.Lock()
+
+	defer log.mu.Unlock()
+
+	log.append(append(log.buffer[:0], walClear))
+
+}
+
+// append frames payload as a record and writes it. It is called with mu held.
+// Errors are sticky: once the log fails it stops writing and Sync and Close
+// report the error.
+func (log *writeAheadLog[K, V]) append(payload []byte) {
+
+	log.buffer = payload
+
+	if log.err != nil {
+
+		return
+
+	}
+
+	if log.closed {
+
+		log.err = ErrorWALClosed
+
+		return
+
+	}
+
+	record := binary.AppendUvarint(nil, uint64(len(payload)))
+
+	record = append(record, payload...)
+
+	record = binary.LittleEndian.AppendUint32(record, crc32.Checksum(payload, castagnoli))
+
+	if _, log.err = log.writer.Write(record); log.err != nil {
+
+		return
+
+	}
+
+	log.size += int64(len(record))
+
+	log.dirty = true
+
+	switch {
+
+	case log.policy == SyncAlways:
+
+		log.sync()
+
+	case log.policy == SyncNever:
+
+		log.err = log.writer.Flush()
+
+	}
+
+	if log.compactAt > 0 && log.size >= log.compactAt && log.compacting.CompareAndSwap(false, true) {
+
+		go func() {
+
+			defer log.compacting.Store(false)
+
+			if err := log.compact(); err != nil && err != ErrorWALClosed {
+
+				log.mu.Lock()
+
+				log.err = firstError(log.err, err)
+
+				log.mu.Unlock()
+
+			}
+
+		}()
+
+	}
+}
+
+// sync flushes buffered records and fsyncs the segment. It is called with mu
+// held.
+func (log *writeAheadLog[K, V]) sync() {
+
+	if !log.dirty || log.err != nil || log.closed {
+
+		return
+
+	}
+
+	if log.err = log.writer.Flush(); log.err != nil {
+
+		return
+
+	}
+
+	log.err = log.file.Sync()
+
+	log.dirty = false
+
+}
+
+func (log *writeAheadLog[K, V]) run() {
+
+	defer close(log.stopped)
+
+	ticker := time.NewTicker(time.Duration(log.policy))
+
+	defer ticker.Stop()
+
+	for {
+
+		select {
+
+		case <-ticker.C:
+
+			log.mu.Lock()
+
+			log.sync()
+
+			log.mu.Unlock()
+
+		case <-log.done:
+
+			return
+
+		}
+
+	}
+}
+
+// open starts a new segment. It is called with mu held or before the log is
+// shared.
+func (log *writeAheadLog[K, V]) open(segment uint64) error {
+
+	file, err := os.OpenFile(segmentPath(log.dir, segment), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	if _, err = file.Write(append([]byte(walMagic), walVersion)); err == nil {
+
+		err = file.Sync()
+
+	}
+
+	if err == nil {
+
+		err = syncDir(log.dir)
+
+	}
+
+	if err != nil {
+
+		file.Close()
+
+		os.Remove(file.Name())
+
+		return err
+
+	}
+
+	log.file, log.writer, log.segment, log.size, log.dirty = file, bufio.NewWriter(file), segment, 0, false
+
+	return nil
+}
+
+// rotate closes the current segment and starts the next one, returning its
+// number. Compact calls it with every shard locked, so the new segment holds
+// exactly the writes that come after the snapshot.
+func (log *writeAheadLog[K, V]) rotate() (uint64, error) {
+
+	log.mu.Lock()
+
+	defer log.mu.Unlock()
+
+	if log.closed {
+
+		return 0, ErrorWALClosed
+
+	}
+
+	log.sync()
+
+	if log.err != nil {
+
+		return 0, log.err
+
+	}
+
+	previous := log.file
+
+	if err := log.open(log.segment + 1); err != nil {
+
+		return 0, err
+
+	}
+
+	return log.segment, previous.Close()
+}
+
+// removeBefore deletes the snapshots and segments older than segment.
+func (log *writeAheadLog[K, V]) removeBefore(segment uint64) error {
+
+	snapshots, segments, err := listWAL(log.dir)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	for _, snapshot := range snapshots {
+
+		if snapshot < segment {
+
+			err = firstError(err, os.Remove(snapshotPath(log.dir, snapshot)))
+
+		}
+
+	}
+
+	for _, old := range segments {
+
+		if old < segment {
+
+			err = firstError(err, os.Remove(segmentPath(log.dir, old)))
+
+		}
+
+	}
+
+	return firstError(err, syncDir(log.dir))
+}
+
+func (log *writeAheadLog[K, V]) close() error {
+
+	if log == nil {
+
+		return nil
+
+	}
+
+	if log.done != nil {
+
+		close(log.done)
+
+		<-log.stopped
+
+	}
+
+	log.compactMu.Lock()
+
+	defer log.compactMu.Unlock()
+
+	log.mu.Lock()
+
+	defer log.mu.Unlock()
+
+	log.sync()
+
+	log.closed = true
+
+	return firstError(log.err, log.file.Close())
+}
+
+// listWAL returns the snapshot and segment numbers found in dir in ascending
+// order.
+func listWAL(dir string) (snapshots, segments []uint64, err error) {
+
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+
+		return nil, nil, err
+
+	}
+
+	for _, entry := range entries {
+
+		var number uint64
+
+		if _, err := fmt.Sscanf(entry.Name(), "snapshot-%016x.snap", &number); err == nil && entry.Name() == filepath.Base(snapshotPath(dir, number)) {
+
+			snapshots = append(snapshots, number)
+
+		} else if _, err := fmt.Sscanf(entry.Name(), "wal-%016x.log", &number); err == nil && entry.Name() == filepath.Base(segmentPath(dir, number)) {
+
+			segments = append(segments, number)
+
+		}
+
+	}
+
+	slices.Sort(snapshots)
+
+	slices.Sort(segments)
+
+	return snapshots, segments, nil
+}
+
+func snapshotPath(dir string, segment uint64) string {
+
+	return filepath.Join(dir, fmt.Sprintf("snapshot-%016x.snap", segment))
+
+}
+
+func segmentPath(dir string, segment uint64) string {
+
+	return filepath.Join(dir, fmt.Sprintf("wal-%016x.log", segment))
+
+}
+
+func syncDir(dir string) error {
+
+	file, err := os.Open(dir)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	defer file.Close()
+
+	return file.Sync()
+}
@@ -0,0 +1,555 @@
+package src
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openStringInt(t *testing.T, dir string, numShards int, opts ...Option[string, int]) *ShardMap[string, int] {
+
+	shardMap, err := OpenShardMap[string, int](dir, numShards, append(stringIntOptions(), opts...)...)
+
+	assert.NoError(t, err)
+
+	return shardMap
+}
+
+func TestOpenShardMap(t *testing.T) {
+
+	policies := map[string]SyncPolicy{
+
+		"SyncAlways": SyncAlways,
+
+		"SyncEvery": SyncEvery(10 * time.Millisecond),
+
+		"SyncNever": SyncNever,
+	}
+
+	for name, policy := range policies {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			dir := t.TempDir()
+
+			shardMap := openStringInt(t, dir, 4, WithSyncPolicy[string, int](policy))
+
+			for i := 0; i < 100; i++ {
+
+				shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+			}
+
+			shardMap.RemoveAll()
+
+			for i := 0; i < 100; i++ {
+
+				shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+			}
+
+			shardMap.Remove("test0")
+
+			shardMap.Upsert("test1", func(old int, ok bool) int { return old + 100 })
+
+			shardMap.SetWithTTL("ttl", 1, time.Hour)
+
+			shardMap.SetWithTTL("persisted", 1, time.Hour)
+
+			shardMap.Persist("persisted")
+
+			shardMap.SetWithTTL("updated", 1, time.Hour)
+
+			shardMap.Upsert("updated", func(old int, ok bool) int { return old + 1 })
+
+			assertions.NoError(shardMap.Close())
+
+			recovered := openStringInt(t, dir, 16)
+
+			defer recovered.Close()
+
+			assertions.Equal(102, recovered.Len())
+
+			assertions.False(recovered.Contains("test0"))
+
+			value, _ := recovered.Get("test1")
+
+			assertions.Equal(101, value)
+
+			ttl, _ := recovered.TTL("ttl")
+
+			assertions.Greater(ttl, 59*time.Minute)
+
+			ttl, _ = recovered.TTL("persisted")
+
+			assertions.Equal(NoExpiration, ttl)
+
+			value, _ = recovered.Get("updated")
+
+			assertions.Equal(2, value)
+
+			ttl, _ = recovered.TTL("updated")
+
+			assertions.Greater(ttl, 59*time.Minute)
+
+		})
+
+	}
+
+	t.Run("Swiss", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		dir := t.TempDir()
+
+		// Spare capacity in the caller's options must not be written to.
+		opts := append(make([]Option[string, int], 0, 16), stringIntOptions()...)
+
+		shardMap, err := OpenShardSwissMap[string, int](dir, 4, opts...)
+
+		assertions.NoError(err)
+
+		assertions.Nil(opts[:len(opts)+1][len(opts)])
+
+		shardMap.Set("test", 1)
+
+		assertions.NoError(shardMap.Close())
+
+		recovered := openStringInt(t, dir, 4)
+
+		defer recovered.Close()
+
+		value, _ := recovered.Get("test")
+
+		assertions.Equal(1, value)
+
+	})
+
+	t.Run("NoCodec", func(t *testing.T) {
+
+		_, err := OpenShardMap[int, int](t.TempDir(), 4)
+
+		assert.ErrorIs(t, err, ErrorNoCodec)
+
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		advance := fakeClock(t)
+
+		dir := t.TempDir()
+
+		shardMap := openStringInt(t, dir, 4)
+
+		shardMap.SetWithTTL("test", 1, time.Second)
+
+		assertions.NoError(shardMap.Close())
+
+		advance(2 * time.Second)
+
+		recovered := openStringInt(t, dir, 4)
+
+		defer recovered.Close()
+
+		assertions.Equal(0, recovered.Len())
+
+	})
+
+	t.Run("OneRecordPerTTLWrite", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		dir := t.TempDir()
+
+		shardMap := openStringInt(t, dir, 4)
+
+		shardMap.SetWithTTL("set", 1, time.Hour)
+
+		shardMap.ComputeWithTTL("compute", time.Hour, func(old int, ok bool) (int, ComputeOp) {
+
+			return 2, UpdateOp
+
+		})
+
+		assertions.NoError(shardMap.Sync())
+
+		_, segments, _ := listWAL(dir)
+
+		file, err := os.Open(segmentPath(dir, segments[len(segments)-1]))
+
+		assertions.NoError(err)
+
+		defer file.Close()
+
+		reader := bufio.NewReader(file)
+
+		_, err = reader.Discard(len(walMagic) + 1)
+
+		assertions.NoError(err)
+
+		var kinds []byte
+
+		for {
+
+			payload, _, err := readWALRecord(reader)
+
+			if err != nil {
+
+				assertions.ErrorIs(err, io.EOF)
+
+				break
+			}
+
+			kinds = append(kinds, payload[0])
+
+		}
+
+		assertions.Equal([]byte{walSet, walSet}, kinds)
+
+		assertions.NoError(shardMap.Close())
+
+		recovered := openStringInt(t, dir, 4)
+
+		defer recovered.Close()
+
+		for _, key := range []string{"set", "compute"} {
+
+			ttl, ok := recovered.TTL(key)
+
+			assertions.True(ok)
+
+			assertions.Greater(ttl, 59*time.Minute)
+
+		}
+
+	})
+
+	t.Run("Resize", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		dir := t.TempDir()
+
+		shardMap := openStringInt(t, dir, 2)
+
+		for i := 0; i < 100; i++ {
+
+			shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+		}
+
+		assertions.NoError(shardMap.Resize(8))
+
+		shardMap.WaitResize()
+
+		for i := 0; i < 100; i++ {
+
+			shardMap.Set(fmt.Sprintf("new%v", i), i)
+
+		}
+
+		assertions.NoError(shardMap.Close())
+
+		recovered := openStringInt(t, dir, 4)
+
+		defer recovered.Close()
+
+		assertions.Equal(200, recovered.Len())
+
+	})
+}
+
+func TestWALCrash(t *testing.T) {
+
+	t.Run("TornTail", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		dir := t.TempDir()
+
+		// The map is never closed, as if the process crashed.
+		shardMap := openStringInt(t, dir, 4)
+
+		shardMap.Set("test1", 1)
+
+		shardMap.Set("test2", 2)
+
+		_, segments, _ := listWAL(dir)
+
+		path := segmentPath(dir, segments[len(segments)-1])
+
+		info, _ := os.Stat(path)
+
+		assertions.NoError(os.Truncate(path, info.Size()-2))
+
+		recovered := openStringInt(t, dir, 4)
+
+		assertions.True(recovered.Contains("test1"))
+
+		assertions.False(recovered.Contains("test2"))
+
+		recovered.Set("test3", 3)
+
+		assertions.NoError(recovered.Close())
+
+		recovered = openStringInt(t, dir, 4)
+
+		defer recovered.Close()
+
+		assertions.Equal(2, recovered.Len())
+
+	})
+
+	t.Run("CorruptSegment", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		dir := t.TempDir()
+
+		shardMap := openStringInt(t, dir, 4)
+
+		shardMap.Set("test", 1)
+
+		assertions.NoError(shardMap.Close())
+
+		// Reopening starts a second segment, so the first one is no longer the
+		// tail of the log.
+		shardMap = openStringInt(t, dir, 4)
+
+		shardMap.Set("test", 2)
+
+		assertions.NoError(shardMap.Close())
+
+		_, segments, _ := listWAL(dir)
+
+		data, _ := os.ReadFile(segmentPath(dir, segments[0]))
+
+		data[len(data)-1] ^= 0xff
+
+		assertions.NoError(os.WriteFile(segmentPath(dir, segments[0]), data, 0o644))
+
+		_, err := OpenShardMap[string, int](dir, 4, stringIntOptions()...)
+
+		assertions.ErrorIs(err, ErrorCorruptWAL)
+
+	})
+
+	t.Run("UnappliableTail", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		dir := t.TempDir()
+
+		shardMap := openStringInt(t, dir, 4)
+
+		shardMap.Set("test", 1)
+
+		assertions.NoError(shardMap.Close())
+
+		_, segments, _ := listWAL(dir)
+
+		path := segmentPath(dir, segments[len(segments)-1])
+
+		// An empty payload has a valid checksum but is not a record, so it is
+		// reported instead of being cut off as a torn append.
+		file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+
+		_, err := file.Write([]byte{0, 0, 0, 0, 0})
+
+		assertions.NoError(err)
+
+		assertions.NoError(file.Close())
+
+		before, _ := os.Stat(path)
+
+		_, err = OpenShardMap[string, int](dir, 4, stringIntOptions()...)
+
+		assertions.ErrorIs(err, ErrorCorruptWAL)
+
+		after, _ := os.Stat(path)
+
+		assertions.Equal(before.Size(), after.Size())
+
+	})
+
+	t.Run("RecordLength", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		var before, after runtime.MemStats
+
+		runtime.ReadMemStats(&before)
+
+		// A record claiming 1GiB must fail on the short segment rather than
+		// allocate its length up front.
+		_, _, err := readWALRecord(bufio.NewReader(bytes.NewReader(append(binary.AppendUvarint(nil, maxWALRecord), "short"...))))
+
+		runtime.ReadMemStats(&after)
+
+		assertions.ErrorIs(err, ErrorCorruptWAL)
+
+		assertions.Less(after.TotalAlloc-before.TotalAlloc, uint64(maxWALRecord/2))
+
+	})
+}
+
+func TestCompact(t *testing.T) {
+
+	t.Run("Manual", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		dir := t.TempDir()
+
+		shardMap := openStringInt(t, dir, 4)
+
+		for i := 0; i < 100; i++ {
+
+			shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+		}
+
+		assertions.NoError(shardMap.Compact())
+
+		shardMap.Remove("test0")
+
+		shardMap.Set("new", 1)
+
+		assertions.NoError(shardMap.Compact())
+
+		shardMap.Set("last", 1)
+
+		assertions.NoError(shardMap.Close())
+
+		snapshots, segments, _ := listWAL(dir)
+
+		assertions.Len(snapshots, 1)
+
+		assertions.Len(segments, 1)
+
+		assertions.Equal(snapshots[0], segments[0])
+
+		recovered := openStringInt(t, dir, 4)
+
+		defer recovered.Close()
+
+		assertions.Equal(101, recovered.Len())
+
+		assertions.False(recovered.Contains("test0"))
+
+		assertions.True(recovered.Contains("last"))
+
+	})
+
+	t.Run("Automatic", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		dir := t.TempDir()
+
+		shardMap := openStringInt(t, dir, 4, WithCompactAt[string, int](1024))
+
+		for i := 0; i < 1000; i++ {
+
+			shardMap.Set("test", i)
+
+		}
+
+		assertions.NoError(shardMap.Close())
+
+		snapshots, _, _ := listWAL(dir)
+
+		assertions.NotEmpty(snapshots)
+
+		recovered := openStringInt(t, dir, 4)
+
+		defer recovered.Close()
+
+		value, _ := recovered.Get("test")
+
+		assertions.Equal(999, value)
+
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		dir := t.TempDir()
+
+		shardMap := openStringInt(t, dir, 8, WithSyncPolicy[string, int](SyncNever))
+
+		var wg sync.WaitGroup
+
+		for worker := 0; worker < 4; worker++ {
+
+			wg.Add(1)
+
+			go func() {
+
+				defer wg.Done()
+
+				for i := 0; i < 500; i++ {
+
+					shardMap.Set(fmt.Sprintf("test%v", i%50), worker*1000+i)
+
+				}
+
+			}()
+
+		}
+
+		for i := 0; i < 5; i++ {
+
+			assertions.NoError(shardMap.Compact())
+
+		}
+
+		wg.Wait()
+
+		assertions.NoError(shardMap.Close())
+
+		recovered := openStringInt(t, dir, 8)
+
+		defer recovered.Close()
+
+		for key, value := range shardMap.All() {
+
+			recovered, _ := recovered.Get(key)
+
+			assertions.Equal(value, recovered)
+
+		}
+
+		assertions.Equal(shardMap.Len(), recovered.Len())
+
+	})
+
+	t.Run("NoWAL", func(t *testing.T) {
+
+		assert.ErrorIs(t, NewShardMap(4).Compact(), ErrorNoWAL)
+
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+
+		shardMap := openStringInt(t, t.TempDir(), 4)
+
+		shardMap.Close()
+
+		assert.ErrorIs(t, shardMap.Compact(), ErrorWALClosed)
+
+	})
+}
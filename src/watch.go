@@ -0,0 +1,419 @@
+package src
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type EventType uint8
+
+// OverflowPolicy decides what happens to an event for a subscriber whose
+// buffer is full.
+type OverflowPolicy uint8
+
+type WatchOption func(options *watchOptions)
+
+// Event describes a single change to a map. OldValue is only meaningful if
+// HasOld is set and Value only for EventSet. An EventClear has no key.
+type Event[K comparable, V any] struct {
+	Type EventType
+
+	Key K
+
+	OldValue V
+
+	HasOld bool
+
+	Value V
+}
+
+// Subscription delivers the events of a Watch, WatchFunc or Subscribe call on
+// Events until it is closed, by Close or by OverflowDisconnect.
+type Subscription[K comparable, V any] struct {
+	events chan Event[K, V]
+
+	overflow OverflowPolicy
+
+	key K
+
+	keyed bool
+
+	// match selects the keys of a WatchFunc subscription. It is nil for
+	// Subscribe, which matches every key.
+	match func(key K) bool
+
+	hub *watchHub[K, V]
+
+	// mu serializes deliveries from different shards with closing events.
+	mu sync.Mutex
+
+	closed bool
+
+	// done is closed first thing by Close, to release a delivery blocked by
+	// OverflowBlock that holds mu.
+	done chan struct{}
+
+	doneOnce sync.Once
+
+	dropped atomic.Uint64
+}
+
+type watchOptions struct {
+	buffer int
+
+	overflow OverflowPolicy
+}
+
+// watchHub routes the events published by the shards of a map to its
+// subscriptions. Events are published under the lock of the shard they
+// happen in, so every subscription sees the changes to a key in order.
+type watchHub[K comparable, V any] struct {
+	mu sync.RWMutex
+
+	subscriptions atomic.Int64
+
+	keys map[K][]*Subscription[K, V]
+
+	filtered []*Subscription[K, V]
+}
+
+const (
+	EventSet EventType = iota + 1
+
+	EventRemove
+
+	// EventExpire is published instead of EventRemove when the entry removed
+	// had already expired, as it has for RemoveExpired and the janitor.
+	EventExpire
+
+	EventClear
+)
+
+const (
+	// OverflowDrop discards the event and counts it in Dropped. It is the
+	// default.
+	OverflowDrop OverflowPolicy = iota
+
+	// OverflowBlock waits for the subscriber to make room. The write that
+	// produced the event holds its shard lock meanwhile, so a slow subscriber
+	// stalls every writer of the shard.
+	OverflowBlock
+
+	// OverflowDisconnect closes the subscription, which its subscriber sees as
+	// Events being closed.
+	OverflowDisconnect
+)
+
+const DefaultWatchBuffer = 64
+
+// WatchBuffer sets how many events a subscription buffers before its
+// OverflowPolicy applies. It defaults to DefaultWatchBuffer.
+func WatchBuffer(size int) WatchOption {
+
+	return func(options *watchOptions) {
+
+		options.buffer = size
+
+	}
+}
+
+func WatchOverflow(overflow OverflowPolicy) WatchOption {
+
+	return func(options *watchOptions) {
+
+		options.overflow = overflow
+
+	}
+}
+
+// Watch subscribes to the events of key, and to every EventClear.
+func (shardMap *ShardMap[K, V]) Watch(key K, opts ...WatchOption) *Subscription[K, V] {
+
+	subscription := newSubscription[K, V](opts)
+
+	subscription.key, subscription.keyed = key, true
+
+	return shardMap.options.watchers.add(subscription)
+}
+
+// WatchFunc subscribes to the events of every key match returns true for, and
+// to every EventClear. match runs under a shard lock and must not access the
+// map.
+func (shardMap *ShardMap[K, V]) WatchFunc(match func(key K) bool, opts ...WatchOption) *Subscription[K, V] {
+
+	subscription := newSubscription[K, V](opts)
+
+	subscription.match = match
+
+	return shardMap.options.watchers.add(subscription)
+}
+
+// Subscribe subscribes to every event of the map.
+func (shardMap *ShardMap[K, V]) Subscribe(opts ...WatchOption) *Subscription[K, V] {
+
+	return shardMap.options.watchers.add(newSubscription[K, V](opts))
+
+}
+
+// WatchPrefix subscribes to the events of every key starting with prefix.
+func WatchPrefix[V any](shardedMap ShardedMap[string, V], prefix string, opts ...WatchOption) *Subscription[string, V] {
+
+	return shardedMap.WatchFunc(func(key string) bool {
+
+		return strings.HasPrefix(key, prefix)
+
+	}, opts...)
+}
+
+func (subscription *Subscription[K, V]) Events() <-chan Event[K, V] {
+
+	return subscription.events
+
+}
+
+// Dropped returns the number of events the subscription lost to a full buffer.
+func (subscription *Subscription[K, V]) Dropped() uint64 {
+
+	return subscription.dropped.Load()
+
+}
+
+// Close unsubscribes and closes Events. Events still buffered can be drained
+// afterwards.
+func (subscription *Subscription[K, V]) Close() {
+
+	subscription.doneOnce.Do(func() {
+
+		close(subscription.done)
+
+	})
+
+	subscription.mu.Lock()
+
+	subscription.closeLocked()
+
+	subscription.mu.Unlock()
+
+	subscription.hub.remove(subscription)
+
+}
+
+//-------------------------------------Helper Functions----------------------------------------------------------//
+
+func newSubscription[K comparable, V any](opts []WatchOption) *Subscription[K, V] {
+
+	options := watchOptions{buffer: DefaultWatchBuffer}
+
+	for _, opt := range opts {
+
+		opt(&options)
+
+	}
+
+	return &Subscription[K, V]{
+
+		events: make(chan Event[K, V], max(options.buffer, 0)),
+
+		overflow: options.overflow,
+
+		done: make(chan struct{}),
+	}
+}
+
+func (subscription *Subscription[K, V]) deliver(event Event[K, V]) {
+
+	subscription.mu.Lock()
+
+	defer subscription.mu.Unlock()
+
+	if subscription.closed {
+
+		return
+
+	}
+
+	select {
+
+	case subscription.events <- event:
+
+		return
+
+	default:
+
+	}
+
+	switch subscription.overflow {
+
+	case OverflowBlock:
+
+		select {
+
+		case subscription.events <- event:
+
+		case <-subscription.done:
+
+		}
+
+	case OverflowDisconnect:
+
+		subscription.dropped.Add(1)
+
+		subscription.closeLocked()
+
+		subscription.hub.remove(subscription)
+
+	default:
+
+		subscription.dropped.Add(1)
+
+	}
+}
+
+func (subscription *Subscription[K, V]) closeLocked() {
+
+	if subscription.closed {
+
+		return
+
+	}
+
+	subscription.closed = true
+
+	subscription.doneOnce.Do(func() {
+
+		close(subscription.done)
+
+	})
+
+	close(subscription.events)
+
+}
+
+func newWatchHub[K comparable, V any]() *watchHub[K, V] {
+
+	return &watchHub[K, V]{keys: make(map[K][]*Subscription[K, V])}
+
+}
+
+// active is checked before an event is put together, which keeps the cost of
+// an unwatched map down to an atomic load per write.
+func (hub *watchHub[K, V]) active() bool {
+
+	return hub != nil && hub.subscriptions.Load() > 0
+
+}
+
+func (hub *watchHub[K, V]) publish(event Event[K, V]) {
+
+	if !hub.active() {
+
+		return
+
+	}
+
+	// The subscribers are picked under the hub lock but delivered to after it
+	// is released, so that one blocked by OverflowBlock does not hold up
+	// subscribing and closing for the whole map.
+	var buffer [8]*Subscription[K, V]
+
+	matched := buffer[:0]
+
+	hub.mu.RLock()
+
+	if event.Type == EventClear {
+
+		for _, subscriptions := range hub.keys {
+
+			matched = append(matched, subscriptions...)
+
+		}
+
+	} else {
+
+		matched = append(matched, hub.keys[event.Key]...)
+
+	}
+
+	for _, subscription := range hub.filtered {
+
+		if event.Type == EventClear || subscription.match == nil || subscription.match(event.Key) {
+
+			matched = append(matched, subscription)
+
+		}
+
+	}
+
+	hub.mu.RUnlock()
+
+	for _, subscription := range matched {
+
+		subscription.deliver(event)
+
+	}
+}
+
+func (hub *watchHub[K, V]) add(subscription *Subscription[K, V]) *Subscription[K, V] {
+
+	hub.mu.Lock()
+
+	defer hub.mu.Unlock()
+
+	subscription.hub = hub
+
+	if subscription.keyed {
+
+		hub.keys[subscription.key] = append(hub.keys[subscription.key], subscription)
+
+	} else {
+
+		hub.filtered = append(hub.filtered, subscription)
+
+	}
+
+	hub.subscriptions.Add(1)
+
+	return subscription
+}
+
+// remove is a no-op for a subscription that was already removed.
+func (hub *watchHub[K, V]) remove(subscription *Subscription[K, V]) {
+
+	hub.mu.Lock()
+
+	defer hub.mu.Unlock()
+
+	without := func(subscriptions []*Subscription[K, V]) []*Subscription[K, V] {
+
+		for i, candidate := range subscriptions {
+
+			if candidate == subscription {
+
+				hub.subscriptions.Add(-1)
+
+				return append(subscriptions[:i:i], subscriptions[i+1:]...)
+			}
+
+		}
+
+		return subscriptions
+	}
+
+	if !subscription.keyed {
+
+		hub.filtered = without(hub.filtered)
+
+		return
+	}
+
+	if subscriptions := without(hub.keys[subscription.key]); len(subscriptions) > 0 {
+
+		hub.keys[subscription.key] = subscriptions
+
+	} else {
+
+		delete(hub.keys, subscription.key)
+
+	}
+}
@@ -0,0 +1,320 @@
+package src
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+
+	for name, newMap := range shardedMaps {
+
+		t.Run(name, func(t *testing.T) {
+
+			assertions := assert.New(t)
+
+			shardedMap := newMap(4)
+
+			watch := shardedMap.Watch("test")
+
+			defer watch.Close()
+
+			shardedMap.Set("test", 1)
+
+			shardedMap.Set("other", 1)
+
+			shardedMap.Upsert("test", func(old int, ok bool) int { return old + 1 })
+
+			shardedMap.Remove("test")
+
+			shardedMap.Remove("test")
+
+			shardedMap.RemoveAll()
+
+			assertions.Equal([]Event[string, int]{
+
+				{Type: EventSet, Key: "test", Value: 1},
+
+				{Type: EventSet, Key: "test", OldValue: 1, HasOld: true, Value: 2},
+
+				{Type: EventRemove, Key: "test", OldValue: 2, HasOld: true},
+
+				{Type: EventClear},
+			}, drain(watch))
+
+		})
+
+	}
+
+	t.Run("Prefix", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(4)
+
+		watch := WatchPrefix[int](shardMap, "user:")
+
+		defer watch.Close()
+
+		for i := 0; i < 10; i++ {
+
+			shardMap.Set(fmt.Sprintf("user:%v", i), i)
+
+			shardMap.Set(fmt.Sprintf("group:%v", i), i)
+
+		}
+
+		events := drain(watch)
+
+		assertions.Len(events, 10)
+
+		for _, event := range events {
+
+			assertions.Contains(event.Key, "user:")
+
+		}
+
+	})
+
+	t.Run("Subscribe", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		advance := fakeClock(t)
+
+		shardMap := NewShardMap(4)
+
+		subscription := shardMap.Subscribe()
+
+		shardMap.SetWithTTL("test", 1, time.Second)
+
+		shardMap.Compute("other", func(old int, ok bool) (int, ComputeOp) { return 1, UpdateOp })
+
+		shardMap.Compute("other", func(old int, ok bool) (int, ComputeOp) { return 0, DeleteOp })
+
+		advance(2 * time.Second)
+
+		shardMap.RemoveExpired()
+
+		assertions.Equal([]EventType{EventSet, EventSet, EventRemove, EventExpire}, eventTypes(drain(subscription)))
+
+		subscription.Close()
+
+		shardMap.Set("test", 1)
+
+		_, open := <-subscription.Events()
+
+		assertions.False(open)
+
+		assertions.False(shardMap.options.watchers.active())
+
+	})
+
+	t.Run("Resize", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(2)
+
+		for i := 0; i < 100; i++ {
+
+			shardMap.Set(fmt.Sprintf("test%v", i), i)
+
+		}
+
+		subscription := shardMap.Subscribe()
+
+		defer subscription.Close()
+
+		assertions.NoError(shardMap.Resize(8))
+
+		shardMap.WaitResize()
+
+		assertions.Empty(drain(subscription))
+
+	})
+}
+
+func TestWatchOverflow(t *testing.T) {
+
+	t.Run("Drop", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(4)
+
+		subscription := shardMap.Subscribe(WatchBuffer(2))
+
+		defer subscription.Close()
+
+		for i := 0; i < 5; i++ {
+
+			shardMap.Set("test", i)
+
+		}
+
+		assertions.Len(drain(subscription), 2)
+
+		assertions.Equal(uint64(3), subscription.Dropped())
+
+	})
+
+	t.Run("Disconnect", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(4)
+
+		subscription := shardMap.Subscribe(WatchBuffer(2), WatchOverflow(OverflowDisconnect))
+
+		for i := 0; i < 5; i++ {
+
+			shardMap.Set("test", i)
+
+		}
+
+		events := 0
+
+		for range subscription.Events() {
+
+			events++
+
+		}
+
+		assertions.Equal(2, events)
+
+		assertions.Eventually(func() bool { return !shardMap.options.watchers.active() }, time.Second, time.Millisecond)
+
+	})
+
+	t.Run("Block", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(4)
+
+		subscription := shardMap.Subscribe(WatchBuffer(1), WatchOverflow(OverflowBlock))
+
+		done := make(chan struct{})
+
+		go func() {
+
+			defer close(done)
+
+			for i := 0; i < 100; i++ {
+
+				shardMap.Set("test", i)
+
+			}
+
+		}()
+
+		for i := 0; i < 100; i++ {
+
+			event := <-subscription.Events()
+
+			assertions.Equal(i, event.Value)
+
+		}
+
+		<-done
+
+		assertions.Equal(uint64(0), subscription.Dropped())
+
+		// Closing releases a writer blocked on a full buffer.
+		shardMap.Set("test", 1)
+
+		go shardMap.Set("test", 2)
+
+		time.Sleep(10 * time.Millisecond)
+
+		subscription.Close()
+
+		shardMap.Set("test", 3)
+
+	})
+
+	t.Run("BlockOutsideHub", func(t *testing.T) {
+
+		assertions := assert.New(t)
+
+		shardMap := NewShardMap(4)
+
+		blocked := shardMap.Watch("test", WatchBuffer(0), WatchOverflow(OverflowBlock))
+
+		go shardMap.Set("test", 1)
+
+		time.Sleep(10 * time.Millisecond)
+
+		// A writer waiting on a full subscriber must not keep others from
+		// subscribing, closing or being delivered to.
+		subscribed := make(chan struct{})
+
+		go func() {
+
+			defer close(subscribed)
+
+			other := shardMap.Watch("other")
+
+			shardMap.Set("other", 1)
+
+			assertions.Len(drain(other), 1)
+
+			other.Close()
+
+		}()
+
+		select {
+
+		case <-subscribed:
+
+		case <-time.After(time.Second):
+
+			assertions.Fail("subscribing waited for a blocked delivery")
+
+		}
+
+		assertions.Equal(1, (<-blocked.Events()).Value)
+
+		blocked.Close()
+
+	})
+}
+
+func drain[K comparable, V any](subscription *Subscription[K, V]) (events []Event[K, V]) {
+
+	for {
+
+		select {
+
+		case event, open := <-subscription.Events():
+
+			if !open {
+
+				return events
+
+			}
+
+			events = append(events, event)
+
+		default:
+
+			return events
+
+		}
+
+	}
+}
+
+func eventTypes[K comparable, V any](events []Event[K, V]) (types []EventType) {
+
+	for _, event := range events {
+
+		types = append(types, event.Type)
+
+	}
+
+	return types
+}